@@ -2,6 +2,9 @@ package main
 
 import (
 	"log"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
 	"task-board/internal/handler"
 	"task-board/internal/middleware"
 	"task-board/internal/repository"
@@ -9,9 +12,15 @@ import (
 	"task-board/internal/websocket"
 	"task-board/pkg/config"
 	"task-board/pkg/database"
+	"task-board/pkg/linkmeta"
+	"task-board/pkg/mailer"
+	"task-board/pkg/storage"
+	"time"
+
+	// Embedded zoneinfo, so TIMEZONE loads in images without one
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 func main() {
@@ -25,36 +34,293 @@ func main() {
 	}
 
 	// Initialize Redis
-	_, err = database.InitializeRedis(cfg)
+	rdb, err := database.InitializeRedis(cfg)
 	if err != nil {
 		log.Fatal("Failed to initialize Redis:", err)
 	}
 
+	// Load the order workflow (defaults to the documented production flow)
+	workflow := service.DefaultWorkflow()
+	if cfg.OrderWorkflowFile != "" {
+		workflow, err = service.LoadWorkflow(cfg.OrderWorkflowFile)
+		if err != nil {
+			log.Fatal("Failed to load order workflow:", err)
+		}
+	}
+
+	if cfg.ChecklistRequiredStates != "" {
+		var states []string
+		for _, state := range strings.Split(cfg.ChecklistRequiredStates, ",") {
+			if state = strings.TrimSpace(state); state != "" {
+				states = append(states, state)
+			}
+		}
+		if err := workflow.RequireChecklist(states); err != nil {
+			log.Fatal("Invalid checklist required states:", err)
+		}
+	}
+
+	location, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		log.Fatal("Invalid time zone:", err)
+	}
+	stalledThresholds, err := service.NewAlertThresholds(cfg.AlertStalledAfter, cfg.AlertStalledByState, workflow)
+	if err != nil {
+		log.Fatal("Invalid stalled order alert thresholds:", err)
+	}
+	deadlineThresholds, err := service.NewAlertThresholds(cfg.AlertDeadlineWarning, cfg.AlertDeadlineByState, workflow)
+	if err != nil {
+		log.Fatal("Invalid deadline alert thresholds:", err)
+	}
+
+	orderNumberFormat, err := service.NewOrderNumberFormat(cfg.OrderNumberPattern)
+	if err != nil {
+		log.Fatal("Invalid order number pattern:", err)
+	}
+
+	complexityHours, err := service.NewComplexityHours(cfg.PlanningComplexityHours)
+	if err != nil {
+		log.Fatal("Invalid planning complexity hours:", err)
+	}
+
+	linkPolicy, err := service.NewLinkPolicy(cfg.LinkSchemes, cfg.LinkAllowedHosts, cfg.LinkDeniedHosts)
+	if err != nil {
+		log.Fatal("Invalid link policy:", err)
+	}
+	// Page titles of new links are only fetched when enabled
+	var linkTitles service.TitleFetcher
+	if cfg.LinkFetchTitles {
+		linkTitles = linkmeta.NewFetcher(cfg.LinkFetchTimeout, linkPolicy.Check)
+	}
+
+	// Email notifications are only offered when an SMTP server is configured
+	var mailSender *mailer.Mailer
+	if cfg.SMTPHost != "" {
+		mailSender, err = mailer.New(mailer.Config{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+			TLS:      cfg.SMTPTLS,
+			Timeout:  cfg.SMTPTimeout,
+		})
+		if err != nil {
+			log.Fatal("Invalid SMTP configuration:", err)
+		}
+	}
+
+	files, err := storage.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize file storage:", err)
+	}
+
+	// Initialize WebSocket hub
+	hub := websocket.NewHub()
+	go hub.Run()
+
 	// Initialize repositories
 	boardRepo := repository.NewBoardRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	orderNumberRepo := repository.NewOrderNumberRepository(db)
+	materialRepo := repository.NewMaterialRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	preferenceRepo := repository.NewNotificationPreferenceRepository(db)
+	emailRepo := repository.NewEmailRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	sectorRepo := repository.NewSectorRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	linkRepo := repository.NewLinkRepository(db)
+	checklistRepo := repository.NewChecklistRepository(db)
+	alertRepo := repository.NewAlertRepository(db)
+	smartAlertRepo := repository.NewSmartAlertRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(rdb)
+	uow := repository.NewUnitOfWork(db)
 
 	// Initialize services
-	boardService := service.NewBoardService(boardRepo)
-	taskService := service.NewTaskService(taskRepo)
+	senders := map[string]service.ChannelSender{}
+	var emailService service.EmailService
+	if mailSender != nil {
+		emailService = service.NewEmailService(emailRepo, notificationRepo, preferenceRepo, userRepo, mailSender, service.EmailSettings{
+			MaxAttempts: cfg.EmailMaxAttempts,
+			DigestHour:  cfg.EmailDigestHour,
+			AppURL:      cfg.AppURL,
+		}, location)
+		senders[domain.CanalEmail] = emailService
+	}
+	notifier := service.NewNotifier(notificationRepo, preferenceRepo, userRepo, hub, senders, location)
+	webhookService := service.NewWebhookService(webhookRepo, userRepo, workflow, service.WebhookSettings{
+		Timeout:      cfg.WebhookTimeout,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		DisableAfter: cfg.WebhookDisableAfter,
+	})
+	// Services write their events to the outbox with each change; the
+	// dispatcher relays them to connected clients, webhooks and notifications.
+	// The names are stored with the events, don't rename them.
+	outbox := service.NewOutboxDispatcher(outboxRepo, map[string]service.EventSubscriber{
+		"websocket":      service.NewRealtimeSubscriber(hub, hub),
+		"webhooks":       webhookService,
+		"notificaciones": service.NewOrderNotifications(notifier, userRepo),
+	}, cfg.OutboxMaxAttempts)
+	boardService := service.NewBoardService(boardRepo, uow, outbox)
+	taskService := service.NewTaskService(taskRepo, uow, outbox)
+	orderService := service.NewOrderService(orderRepo, userRepo, orderNumberRepo, uow, workflow, orderNumberFormat, outbox, notifier, files, cfg.OrderClaimTimeout)
+	materialService := service.NewMaterialService(materialRepo, orderRepo, userRepo, uow, notifier)
+	stockService := service.NewStockService(stockRepo, userRepo, uow, notifier)
+	sectorService := service.NewSectorService(sectorRepo, orderRepo, userRepo, uow, outbox, complexityHours, location)
+	attachmentService := service.NewAttachmentService(attachmentRepo, orderRepo, userRepo, uow, files, cfg.AttachmentMaxSize)
+	commentService := service.NewCommentService(commentRepo, orderRepo, userRepo, notifier)
+	linkService := service.NewLinkService(linkRepo, orderRepo, userRepo, linkPolicy, linkTitles)
+	checklistService := service.NewChecklistService(checklistRepo, orderRepo, userRepo, uow, outbox)
+	alertService := service.NewAlertService(alertRepo, notifier, stalledThresholds, deadlineThresholds, location)
+	smartAlertService := service.NewSmartAlertService(smartAlertRepo, alertRepo, userRepo, uow, notifier, service.SmartAlertRules{
+		OperatorMaxOrders: cfg.SmartAlertOperatorOrders,
+		QueueWindow:       cfg.SmartAlertQueueWindow,
+		QueueGrowth:       cfg.SmartAlertQueueGrowth,
+		SlowdownFactor:    cfg.SmartAlertSlowdown,
+	}, location)
+	notificationService := service.NewNotificationService(notificationRepo, preferenceRepo, emailRepo, userRepo, notifier, hub)
 	
 	// Set board repository in task service
 	if taskSvc, ok := taskService.(interface{ SetBoardRepo(repository.BoardRepository) }); ok {
 		taskSvc.SetBoardRepo(boardRepo)
 	}
 
-	// Initialize WebSocket hub
-	hub := websocket.NewHub()
-	go hub.Run()
+	// Release "working on it" claims that have gone idle
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := orderService.ExpireClaims(); err != nil {
+				log.Printf("Failed to expire order claims: %v", err)
+			}
+		}
+	}()
 
-	// Initialize user repository and service
-	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo)
+	// Send stalled order and deadline alerts
+	go func() {
+		ticker := time.NewTicker(cfg.AlertScanInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := alertService.Scan(time.Now()); err != nil {
+				log.Printf("Failed to scan orders for alerts: %v", err)
+			}
+		}
+	}()
+
+	// Raise, update and clear smart alerts
+	go func() {
+		ticker := time.NewTicker(cfg.SmartAlertInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := smartAlertService.Evaluate(time.Now()); err != nil {
+				log.Printf("Failed to evaluate smart alerts: %v", err)
+			}
+		}
+	}()
+
+	// Relay outbox events as soon as they are committed, and retry the ones
+	// that failed on every tick
+	go func() {
+		ticker := time.NewTicker(cfg.OutboxDispatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-outbox.Woken():
+			}
+			if _, err := outbox.Dispatch(time.Now()); err != nil {
+				log.Printf("Failed to dispatch outbox events: %v", err)
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := outbox.Purge(time.Now().Add(-cfg.OutboxRetention)); err != nil {
+				log.Printf("Failed to purge outbox events: %v", err)
+			}
+		}
+	}()
+
+	// Deliver queued webhook events
+	go func() {
+		ticker := time.NewTicker(cfg.WebhookDispatchInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := webhookService.Dispatch(time.Now()); err != nil {
+				log.Printf("Failed to dispatch webhooks: %v", err)
+			}
+		}
+	}()
+
+	// Send queued emails and digests
+	if emailService != nil {
+		go func() {
+			ticker := time.NewTicker(cfg.EmailDispatchInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := emailService.Dispatch(time.Now()); err != nil {
+					log.Printf("Failed to dispatch emails: %v", err)
+				}
+			}
+		}()
+	}
+
+	// Generate attachment previews in the background; the sweep recovers
+	// uploads that didn't fit in the queue and files from before a restart
+	for i := 0; i < 2; i++ {
+		go attachmentService.RunPreviewWorker()
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if _, err := attachmentService.QueuePendingPreviews(); err != nil {
+				log.Printf("Failed to queue attachment previews: %v", err)
+			}
+		}
+	}()
+
+	// Initialize user service
+	userService := service.NewUserService(userRepo, refreshTokenRepo, sessionRepo, uow)
 	userHandler := handler.NewUserHandler(userService, cfg)
+	if err := userService.EnsureAdmin(cfg.AdminNombre, cfg.AdminPassword); err != nil {
+		log.Printf("Initial admin user not created: %v", err)
+	}
+
+	// Drop refresh tokens that can no longer be used
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := userService.PurgeExpiredSessions(); err != nil {
+				log.Printf("Failed to purge expired sessions: %v", err)
+			}
+		}
+	}()
 
 	// Initialize handlers
 	boardHandler := handler.NewBoardHandler(boardService)
 	taskHandler := handler.NewTaskHandler(taskService)
+	orderHandler := handler.NewOrderHandler(orderService)
+	materialHandler := handler.NewMaterialHandler(materialService)
+	stockHandler := handler.NewStockHandler(stockService)
+	sectorHandler := handler.NewSectorHandler(sectorService)
+	attachmentHandler := handler.NewAttachmentHandler(attachmentService)
+	commentHandler := handler.NewCommentHandler(commentService)
+	linkHandler := handler.NewLinkHandler(linkService)
+	checklistHandler := handler.NewChecklistHandler(checklistService)
+	smartAlertHandler := handler.NewSmartAlertHandler(smartAlertService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
 	wsHandler := handler.NewWebSocketHandler(hub)
 
 	// Setup router
@@ -77,10 +343,129 @@ func main() {
 		// Authentication routes (no middleware required)
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", userHandler.Register)
 			auth.POST("/login", userHandler.Login)
-			auth.GET("/profile", middleware.AuthMiddleware(cfg.JWTSecret), userHandler.GetProfile)
-			auth.PUT("/profile", middleware.AuthMiddleware(cfg.JWTSecret), userHandler.UpdateProfile)
+			auth.POST("/refresh", userHandler.Refresh)
+			auth.POST("/logout", userHandler.Logout)
+			auth.POST("/logout-all", middleware.AuthMiddleware(cfg.JWTSecret, userService), userHandler.LogoutAll)
+			auth.GET("/profile", middleware.AuthMiddleware(cfg.JWTSecret, userService), userHandler.GetProfile)
+			auth.PUT("/profile", middleware.AuthMiddleware(cfg.JWTSecret, userService), userHandler.UpdateProfile)
+		}
+
+		// Plot Center routes - require an authenticated user
+		protected := api.Group("", middleware.AuthMiddleware(cfg.JWTSecret, userService))
+
+		// User administration routes
+		users := protected.Group("/users", middleware.RequirePermission(authz.PermUserManage))
+		{
+			users.GET("", userHandler.GetUsers)
+			users.POST("", userHandler.CreateUser)
+			users.PUT("/:id", userHandler.UpdateUser)
+			users.DELETE("/:id", userHandler.DeleteUser)
+		}
+
+		// Work order routes
+		orders := protected.Group("/orders")
+		{
+			orders.GET("", middleware.RequirePermission(authz.PermOrderView), orderHandler.GetOrders)
+			orders.POST("", middleware.RequirePermission(authz.PermOrderCreate), orderHandler.CreateOrder)
+			orders.GET("/workflow", orderHandler.GetWorkflow)
+			orders.GET("/board", middleware.RequirePermission(authz.PermOrderView), orderHandler.GetBoard)
+			orders.POST("/numbers/reserve", middleware.RequirePermission(authz.PermOrderReserveNumbers), orderHandler.ReserveNumbers)
+			orders.GET("/:id", middleware.RequirePermission(authz.PermOrderView), orderHandler.GetOrder)
+			orders.PUT("/:id", orderHandler.UpdateOrder) // full or delivery-only edit, decided by the service
+			orders.DELETE("/:id", middleware.RequirePermission(authz.PermOrderDelete), orderHandler.DeleteOrder)
+			orders.PUT("/:id/state", middleware.RequirePermission(authz.PermOrderChangeState), orderHandler.ChangeState)
+			orders.POST("/:id/move", middleware.RequirePermission(authz.PermOrderChangeState), orderHandler.MoveOrder)
+			orders.GET("/:id/history", middleware.RequirePermission(authz.PermOrderView), orderHandler.GetHistory)
+			orders.POST("/:id/claim", middleware.RequirePermission(authz.PermOrderClaim), orderHandler.ClaimOrder)
+			orders.POST("/:id/release", middleware.RequirePermission(authz.PermOrderClaim), orderHandler.ReleaseOrder)
+			orders.GET("/:id/materials", middleware.RequirePermission(authz.PermOrderView), materialHandler.GetOrderMaterials)
+			orders.POST("/:id/materials", middleware.RequirePermission(authz.PermOrderEdit), materialHandler.AddOrderMaterial)
+			orders.PUT("/:id/materials/:lineId", middleware.RequirePermission(authz.PermOrderEdit), materialHandler.UpdateOrderMaterial)
+			orders.DELETE("/:id/materials/:lineId", middleware.RequirePermission(authz.PermOrderEdit), materialHandler.RemoveOrderMaterial)
+			orders.GET("/:id/attachments", middleware.RequirePermission(authz.PermOrderView), attachmentHandler.GetAttachments)
+			orders.POST("/:id/attachments", middleware.RequirePermission(authz.PermOrderAttach), attachmentHandler.UploadAttachment)
+			orders.GET("/:id/attachments/:attachmentId", middleware.RequirePermission(authz.PermOrderView), attachmentHandler.DownloadAttachment)
+			orders.GET("/:id/attachments/:attachmentId/preview", middleware.RequirePermission(authz.PermOrderView), attachmentHandler.GetPreview)
+			orders.DELETE("/:id/attachments/:attachmentId", middleware.RequirePermission(authz.PermOrderAttach), attachmentHandler.DeleteAttachment)
+			orders.GET("/:id/attachments/:attachmentId/versions", middleware.RequirePermission(authz.PermOrderView), attachmentHandler.GetVersions)
+			orders.POST("/:id/attachments/:attachmentId/versions", middleware.RequirePermission(authz.PermOrderAttach), attachmentHandler.UploadVersion)
+			orders.POST("/:id/attachments/:attachmentId/approval", middleware.RequirePermission(authz.PermOrderApproveProof), attachmentHandler.ApproveVersion)
+			orders.DELETE("/:id/attachments/:attachmentId/approval", middleware.RequirePermission(authz.PermOrderApproveProof), attachmentHandler.WithdrawApproval)
+			orders.GET("/:id/comments", middleware.RequirePermission(authz.PermOrderView), commentHandler.GetComments)
+			orders.POST("/:id/comments", middleware.RequirePermission(authz.PermOrderComment), commentHandler.CreateComment)
+			orders.PUT("/:id/comments/:commentId", middleware.RequirePermission(authz.PermOrderComment), commentHandler.UpdateComment)
+			orders.DELETE("/:id/comments/:commentId", middleware.RequirePermission(authz.PermOrderComment), commentHandler.DeleteComment)
+			orders.GET("/:id/links", middleware.RequirePermission(authz.PermOrderView), linkHandler.GetLinks)
+			orders.POST("/:id/links", middleware.RequirePermission(authz.PermOrderAttach), linkHandler.AddLink)
+			orders.DELETE("/:id/links/:linkId", middleware.RequirePermission(authz.PermOrderAttach), linkHandler.RemoveLink)
+			orders.GET("/:id/checklist", middleware.RequirePermission(authz.PermOrderView), checklistHandler.GetChecklist)
+			orders.POST("/:id/checklist", middleware.RequirePermission(authz.PermOrderChecklist), checklistHandler.AddTask)
+			orders.PUT("/:id/checklist/:taskId", middleware.RequirePermission(authz.PermOrderChecklist), checklistHandler.UpdateTask)
+			orders.POST("/:id/checklist/:taskId/move", middleware.RequirePermission(authz.PermOrderChecklist), checklistHandler.MoveTask)
+			orders.DELETE("/:id/checklist/:taskId", middleware.RequirePermission(authz.PermOrderEdit), checklistHandler.DeleteTask)
+			orders.GET("/:id/route", middleware.RequirePermission(authz.PermOrderView), sectorHandler.GetRoute)
+			orders.PUT("/:id/route", middleware.RequirePermission(authz.PermOrderEdit), sectorHandler.SetRoute)
+			orders.POST("/:id/route/advance", middleware.RequirePermission(authz.PermOrderChangeState), sectorHandler.AdvanceRoute)
+			orders.PUT("/:id/sector", middleware.RequirePermission(authz.PermOrderChangeState), sectorHandler.SetCurrentSector)
+		}
+
+		// Smart alert routes
+		alerts := protected.Group("/alerts")
+		{
+			alerts.GET("", middleware.RequirePermission(authz.PermAlertManage), smartAlertHandler.GetAlerts)
+			alerts.POST("/:id/resolve", middleware.RequirePermission(authz.PermAlertManage), smartAlertHandler.ResolveAlert)
+			alerts.POST("/:id/snooze", middleware.RequirePermission(authz.PermAlertManage), smartAlertHandler.SnoozeAlert)
+		}
+
+		// Webhook routes
+		webhooks := protected.Group("/webhooks", middleware.RequirePermission(authz.PermWebhookManage))
+		{
+			webhooks.GET("", webhookHandler.GetWebhooks)
+			webhooks.POST("", webhookHandler.CreateWebhook)
+			webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+			webhooks.POST("/:id/deliveries/:deliveryId/replay", webhookHandler.ReplayDelivery)
+		}
+
+		// Notification center routes
+		notifications := protected.Group("/notifications")
+		{
+			notifications.GET("", notificationHandler.GetNotifications)
+			notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
+			notifications.POST("/read-all", notificationHandler.MarkAllRead)
+			notifications.POST("/:id/read", notificationHandler.MarkRead)
+			notifications.GET("/changes", notificationHandler.GetChanges)
+			notifications.POST("/changes/seen", notificationHandler.MarkChangesSeen)
+			notifications.GET("/preferences", notificationHandler.GetPreferences)
+			notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
+			notifications.GET("/emails", notificationHandler.GetEmailDeliveries)
+		}
+
+		// Sector routes
+		sectors := protected.Group("/sectors")
+		{
+			sectors.GET("", middleware.RequirePermission(authz.PermOrderView), sectorHandler.GetSectors)
+			sectors.POST("", middleware.RequirePermission(authz.PermSectorManage), sectorHandler.CreateSector)
+			sectors.PUT("/order", middleware.RequirePermission(authz.PermSectorManage), sectorHandler.ReorderSectors)
+			sectors.GET("/workload", middleware.RequirePermission(authz.PermPlanningView), sectorHandler.GetWorkload)
+			sectors.GET("/:id", middleware.RequirePermission(authz.PermOrderView), sectorHandler.GetSector)
+			sectors.PUT("/:id", middleware.RequirePermission(authz.PermSectorManage), sectorHandler.UpdateSector)
+			sectors.DELETE("/:id", middleware.RequirePermission(authz.PermSectorManage), sectorHandler.DeleteSector)
+		}
+
+		// Materials catalog routes
+		materials := protected.Group("/materials")
+		{
+			materials.GET("", middleware.RequirePermission(authz.PermOrderView), materialHandler.GetMaterials)
+			materials.POST("", middleware.RequirePermission(authz.PermMaterialManage), materialHandler.CreateMaterial)
+			materials.GET("/:id", middleware.RequirePermission(authz.PermOrderView), materialHandler.GetMaterial)
+			materials.PUT("/:id", middleware.RequirePermission(authz.PermMaterialManage), materialHandler.UpdateMaterial)
+			materials.DELETE("/:id", middleware.RequirePermission(authz.PermMaterialManage), materialHandler.DeleteMaterial)
+			materials.GET("/:id/stock", middleware.RequirePermission(authz.PermOrderView), stockHandler.GetMovements)
+			materials.POST("/:id/stock/receipts", middleware.RequirePermission(authz.PermStockManage), stockHandler.ReceiveStock)
+			materials.POST("/:id/stock/adjustments", middleware.RequirePermission(authz.PermStockManage), stockHandler.AdjustStock)
 		}
 
 		// Board and Task routes - support both authenticated and anonymous users
@@ -88,23 +473,20 @@ func main() {
 		api.Use(func(c *gin.Context) {
 			// Check if Authorization header exists
 			authHeader := c.GetHeader("Authorization")
+			tokenString := ""
 			if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+				tokenString = authHeader[7:]
+			} else if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+				// Browsers can't set headers on WebSocket connections
+				tokenString = c.Query("token")
+			}
+			if tokenString != "" {
 				// Try JWT authentication
-				tokenString := authHeader[7:]
-				token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-					return []byte(cfg.JWTSecret), nil
-				})
-				
-				if err == nil && token.Valid {
+				if claims, err := middleware.ParseToken(tokenString, cfg.JWTSecret, userService); err == nil {
 					// JWT is valid, use it
-					claims, ok := token.Claims.(jwt.MapClaims)
-					if ok {
-						if userID, ok := claims["user_id"].(float64); ok {
-							c.Set("user_id", uint(userID))
-							c.Next()
-							return
-						}
-					}
+					middleware.SetClaims(c, claims)
+					c.Next()
+					return
 				}
 				// JWT invalid, fall through to anonymous
 			}
@@ -0,0 +1,113 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"task-board/internal/domain"
+)
+
+// ErrForbidden is returned when a role lacks the permission for an action
+var ErrForbidden = errors.New("forbidden")
+
+// Permission names a single action a role may be allowed to perform
+type Permission string
+
+const (
+	PermOrderView           Permission = "order:view"
+	PermOrderCreate         Permission = "order:create"
+	PermOrderEdit           Permission = "order:edit"
+	PermOrderEditDelivery   Permission = "order:edit_delivery"
+	PermOrderDelete         Permission = "order:delete"
+	PermOrderChangeState    Permission = "order:change_state"
+	PermOrderClaim          Permission = "order:claim"
+	PermOrderClaimOverride  Permission = "order:claim_override"
+	PermOrderReserveNumbers Permission = "order:reserve_numbers"
+	PermOrderAttach         Permission = "order:attach"
+	PermOrderApproveProof   Permission = "order:approve_proof"
+	PermOrderComment        Permission = "order:comment"
+	PermOrderChecklist      Permission = "order:checklist"
+	PermMaterialManage      Permission = "material:manage"
+	PermStockManage         Permission = "stock:manage"
+	PermSectorManage        Permission = "sector:manage"
+	PermPlanningView        Permission = "planning:view"
+	PermAlertManage         Permission = "alert:manage"
+	PermWebhookManage       Permission = "webhook:manage"
+	PermUserManage          Permission = "user:manage"
+)
+
+// policy is the declarative role -> permissions table
+var policy = map[string][]Permission{
+	domain.RolAdministracion: {
+		PermOrderView,
+		PermOrderCreate,
+		PermOrderEdit,
+		PermOrderEditDelivery,
+		PermOrderDelete,
+		PermOrderChangeState,
+		PermOrderClaim,
+		PermOrderClaimOverride,
+		PermOrderReserveNumbers,
+		PermOrderAttach,
+		PermOrderApproveProof,
+		PermOrderComment,
+		PermOrderChecklist,
+		PermMaterialManage,
+		PermStockManage,
+		PermSectorManage,
+		PermPlanningView,
+		PermAlertManage,
+		PermWebhookManage,
+		PermUserManage,
+	},
+	domain.RolTaller: {
+		PermOrderView,
+		PermOrderChangeState,
+		PermOrderClaim,
+		PermOrderAttach,
+		PermOrderComment,
+		PermOrderChecklist,
+	},
+	domain.RolMostrador: {
+		PermOrderView,
+		PermOrderCreate,
+		PermOrderEditDelivery,
+		PermOrderAttach,
+		PermOrderApproveProof,
+		PermOrderComment,
+		PermOrderChecklist,
+	},
+}
+
+// NormalizeRole maps legacy spellings (e.g. "administración") to the role constants
+func NormalizeRole(rol string) string {
+	rol = strings.ToLower(strings.TrimSpace(rol))
+	if rol == "administración" {
+		return domain.RolAdministracion
+	}
+	return rol
+}
+
+// IsValidRole reports whether the role appears in the policy table
+func IsValidRole(rol string) bool {
+	_, ok := policy[NormalizeRole(rol)]
+	return ok
+}
+
+// Can reports whether the role holds the permission
+func Can(rol string, perm Permission) bool {
+	for _, granted := range policy[NormalizeRole(rol)] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error wrapping ErrForbidden when the role lacks the permission
+func Check(rol string, perm Permission) error {
+	if !Can(rol, perm) {
+		return fmt.Errorf("%w: role %q lacks %s", ErrForbidden, rol, perm)
+	}
+	return nil
+}
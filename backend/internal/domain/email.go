@@ -0,0 +1,64 @@
+package domain
+
+import "time"
+
+// EmailPreference modes
+const (
+	ModoEmailInmediato = "inmediato"
+	ModoEmailHorario   = "horario"
+	ModoEmailDiario    = "diario"
+)
+
+// EmailDelivery kinds
+const (
+	EnvioInmediato = "inmediato"
+	EnvioResumen   = "resumen"
+)
+
+// EmailDelivery states
+const (
+	EnvioPendiente = "pendiente"
+	EnvioEnviado   = "enviado"
+	EnvioRebotado  = "rebotado" // the server refused it for good
+	EnvioFallido   = "fallido"  // gave up after the last retry
+)
+
+// EmailPreference is how a user gets the notifications they routed to email:
+// one mail each, or grouped in an hourly or daily digest. Users without a row
+// get them immediately.
+type EmailPreference struct {
+	IDUsuario     uint       `json:"-" gorm:"column:id_usuario;primaryKey;autoIncrement:false"`
+	Modo          string     `json:"modo" gorm:"type:varchar(10);not null"` // inmediato | horario | diario
+	UltimoResumen *time.Time `json:"ultimo_resumen"`
+}
+
+// TableName specifies the table name for EmailPreference
+func (EmailPreference) TableName() string {
+	return "preferencias_email"
+}
+
+// EmailDelivery is one email to send, and the log of trying to send it.
+// Pending deliveries are retried at ProximoIntento until they go out, bounce
+// or run out of attempts.
+type EmailDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	IDUsuario      uint       `json:"id_usuario" gorm:"column:id_usuario;not null;index"`
+	Destinatario   string     `json:"destinatario" gorm:"type:varchar(255);not null"`
+	Asunto         string     `json:"asunto" gorm:"type:varchar(255);not null"`
+	Cuerpo         string     `json:"-" gorm:"type:text;not null"`
+	Tipo           string     `json:"tipo" gorm:"type:varchar(10);not null"`                             // inmediato | resumen
+	Estado         string     `json:"estado" gorm:"type:varchar(10);not null;index:idx_envio_pendiente"` // pendiente | enviado | rebotado | fallido
+	Intentos       int        `json:"intentos" gorm:"not null;default:0"`
+	UltimoError    *string    `json:"ultimo_error" gorm:"type:text"`
+	ProximoIntento *time.Time `json:"proximo_intento" gorm:"index:idx_envio_pendiente"`
+	FechaCreacion  time.Time  `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
+	FechaEnvio     *time.Time `json:"fecha_envio"`
+
+	// Relations
+	Usuario *User `json:"usuario,omitempty" gorm:"foreignKey:IDUsuario"`
+}
+
+// TableName specifies the table name for EmailDelivery
+func (EmailDelivery) TableName() string {
+	return "envios_email"
+}
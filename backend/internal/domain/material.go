@@ -1,10 +1,27 @@
 package domain
 
+import "time"
+
+// Stock movement types
+const (
+	MovimientoIngreso    = "ingreso"
+	MovimientoAjuste     = "ajuste"
+	MovimientoReserva    = "reserva"
+	MovimientoLiberacion = "liberacion"
+	MovimientoConsumo    = "consumo"
+)
+
 // Material represents a material used in orders
 type Material struct {
-	ID          uint   `json:"id" gorm:"primaryKey"`
-	Codigo      *string `json:"codigo" gorm:"type:varchar(50)"`
-	Descripcion string `json:"descripcion" gorm:"type:varchar(255);not null"`
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	Codigo      *string `json:"codigo" gorm:"type:varchar(50);uniqueIndex"`
+	Descripcion string  `json:"descripcion" gorm:"type:varchar(255);not null"`
+
+	// Stock. StockReservado is held by orders in production; the rest of
+	// StockActual is available. Dropping below PuntoReposicion alerts administracion.
+	StockActual     float64  `json:"stock_actual" gorm:"type:decimal(12,3);not null;default:0"`
+	StockReservado  float64  `json:"stock_reservado" gorm:"type:decimal(12,3);not null;default:0"`
+	PuntoReposicion *float64 `json:"punto_reposicion" gorm:"type:decimal(12,3)"`
 
 	// Relations
 	Ordenes []OrderMaterial `json:"ordenes,omitempty" gorm:"foreignKey:IDMaterial"`
@@ -15,3 +32,34 @@ func (Material) TableName() string {
 	return "materiales"
 }
 
+// StockDisponible is the stock on hand that no order has reserved
+func (m *Material) StockDisponible() float64 {
+	return m.StockActual - m.StockReservado
+}
+
+// BelowReorderPoint reports whether the available stock is under the reorder point
+func (m *Material) BelowReorderPoint() bool {
+	return m.PuntoReposicion != nil && m.StockDisponible() < *m.PuntoReposicion
+}
+
+// StockMovement is one entry of a material's inventory ledger. CambioStock and
+// CambioReservado are the signed changes it made; the Resultante fields are the
+// material's totals right after it.
+type StockMovement struct {
+	ID                  uint      `json:"id" gorm:"primaryKey"`
+	IDMaterial          uint      `json:"id_material" gorm:"column:id_material;not null;index"`
+	IDOrden             *uint     `json:"id_orden" gorm:"column:id_orden;index"`
+	IDUsuario           *uint     `json:"id_usuario" gorm:"column:id_usuario"`
+	Tipo                string    `json:"tipo" gorm:"type:varchar(20);not null"`
+	CambioStock         float64   `json:"cambio_stock" gorm:"type:decimal(12,3);not null;default:0"`
+	CambioReservado     float64   `json:"cambio_reservado" gorm:"type:decimal(12,3);not null;default:0"`
+	StockResultante     float64   `json:"stock_resultante" gorm:"type:decimal(12,3);not null"`
+	ReservadoResultante float64   `json:"reservado_resultante" gorm:"type:decimal(12,3);not null"`
+	Motivo              *string   `json:"motivo" gorm:"type:varchar(255)"`
+	Timestamp           time.Time `json:"timestamp" gorm:"not null;index"`
+}
+
+// TableName specifies the table name for StockMovement
+func (StockMovement) TableName() string {
+	return "movimientos_stock"
+}
@@ -2,6 +2,39 @@ package domain
 
 import "time"
 
+// UserNotification types
+const (
+	NotificacionStockBajo = "stock_bajo"
+	NotificacionMencion   = "mencion"
+	NotificacionEstancada = "orden_estancada"
+	NotificacionPlazo     = "plazo"
+	NotificacionAlerta    = "alerta_inteligente"
+	NotificacionAsignacion = "asignacion"
+	NotificacionCambioEstado = "cambio_estado"
+)
+
+// NotificationPreference events a user can route to a channel
+const (
+	EventoMencion      = "mencion"
+	EventoAsignacion   = "asignacion"
+	EventoCambioEstado = "cambio_estado"
+	EventoAlertaPlazo  = "alerta_plazo"
+	EventoAlertaStock  = "alerta_stock"
+)
+
+// NotificationPreference channels
+const (
+	CanalApp     = "app"
+	CanalEmail   = "email"
+	CanalNinguno = "ninguno"
+)
+
+// AlertSent types
+const (
+	AlertaEstancada = "estancada"
+	AlertaPlazo     = "plazo"
+)
+
 // Notification represents a notification for a user
 type Notification struct {
 	ID            uint      `json:"id" gorm:"primaryKey"`
@@ -31,9 +64,12 @@ type UserNotification struct {
 	OrdenID     *uint     `json:"orden_id" gorm:"column:orden_id;index"`
 	IsRead      bool      `json:"is_read" gorm:"default:false"`
 	CreatedAt   time.Time `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
+	// Canal is email for notifications routed to email; only app ones show in the notification center
+	Canal        string `json:"canal" gorm:"type:varchar(10);not null;default:'app'"`
+	IDEnvioEmail *uint  `json:"id_envio_email,omitempty" gorm:"column:id_envio_email;index"` // the email that carried it
 
 	// Relations
-	User  User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	User  *User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Orden *Order `json:"orden,omitempty" gorm:"foreignKey:OrdenID"`
 }
 
@@ -59,11 +95,14 @@ func (NotificationViewed) TableName() string {
 	return "notificaciones_vistas"
 }
 
-// AlertSent represents sent alerts
+// AlertSent records an alert already sent, so the scheduler sends each one once.
+// Referencia tells apart alerts of the same type on the same order, such as
+// two separate stalls; it is NULL on legacy rows.
 type AlertSent struct {
 	ID         uint      `json:"id" gorm:"primaryKey"`
-	IDOrden    uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
-	TipoAlerta string    `json:"tipo_alerta" gorm:"type:enum('estancada','plazo');not null"`
+	IDOrden    uint      `json:"id_orden" gorm:"column:id_orden;not null;index;uniqueIndex:idx_alerta_unica,priority:1"`
+	TipoAlerta string    `json:"tipo_alerta" gorm:"type:varchar(20);not null;uniqueIndex:idx_alerta_unica,priority:2"` // estancada | plazo
+	Referencia *string   `json:"referencia" gorm:"type:varchar(100);uniqueIndex:idx_alerta_unica,priority:3"`
 	Timestamp  time.Time `json:"timestamp" gorm:"default:CURRENT_TIMESTAMP"`
 
 	// Relations
@@ -75,17 +114,39 @@ func (AlertSent) TableName() string {
 	return "alertas_enviadas"
 }
 
-// SmartAlert represents intelligent alerts
+// SmartAlert types
+const (
+	AlertaRetrasoPredicho    = "retraso_predicho"
+	AlertaSobrecargaOperario = "sobrecarga_operario"
+	AlertaCuelloBotella      = "cuello_botella"
+	AlertaEficienciaBaja     = "eficiencia_baja"
+)
+
+// SmartAlert priorities, lowest first
+const (
+	PrioridadAlertaBaja    = "baja"
+	PrioridadAlertaMedia   = "media"
+	PrioridadAlertaAlta    = "alta"
+	PrioridadAlertaCritica = "critica"
+)
+
+// SmartAlert represents intelligent alerts. Huella identifies what the alert
+// is about (an operator, a state, an order), so while it is unresolved the
+// rules engine updates it instead of raising a new one.
 type SmartAlert struct {
-	ID            uint       `json:"id" gorm:"primaryKey"`
-	TipoAlerta    string     `json:"tipo_alerta" gorm:"type:enum('retraso_predicho','sobrecarga_operario','cuello_botella','eficiencia_baja');not null"`
-	Prioridad     string     `json:"prioridad" gorm:"type:enum('baja','media','alta','critica');not null"`
-	Titulo        string     `json:"titulo" gorm:"type:varchar(255);not null"`
-	Descripcion   *string    `json:"descripcion" gorm:"type:text"`
-	DatosContexto *string    `json:"datos_contexto" gorm:"type:longtext"` // JSON stored as string
-	FechaCreacion time.Time  `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
-	FechaResuelto *time.Time `json:"fecha_resuelto"`
-	Resuelto      bool       `json:"resuelto" gorm:"default:false"`
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	TipoAlerta          string     `json:"tipo_alerta" gorm:"type:varchar(30);not null;index"`
+	Prioridad           string     `json:"prioridad" gorm:"type:varchar(10);not null"`
+	Titulo              string     `json:"titulo" gorm:"type:varchar(255);not null"`
+	Descripcion         *string    `json:"descripcion" gorm:"type:text"`
+	DatosContexto       *string    `json:"datos_contexto" gorm:"type:text"` // JSON stored as string
+	Huella              *string    `json:"huella" gorm:"type:varchar(150);index:idx_smart_alert_activa,unique,where:resuelto = false"`
+	FechaCreacion       time.Time  `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
+	FechaActualizacion  *time.Time `json:"fecha_actualizacion"` // last time the rules engine saw it still holding
+	SilenciadaHasta     *time.Time `json:"silenciada_hasta"`
+	FechaResuelto       *time.Time `json:"fecha_resuelto"`
+	IDUsuarioResolucion *uint      `json:"id_usuario_resolucion" gorm:"column:id_usuario_resolucion"` // nil when it cleared by itself
+	Resuelto            bool       `json:"resuelto" gorm:"default:false;index"`
 }
 
 // TableName specifies the table name for SmartAlert
@@ -93,3 +154,30 @@ func (SmartAlert) TableName() string {
 	return "smart_alerts"
 }
 
+
+// NotificationPreference is the channel a user picked for one event. Events
+// without a row go to the in-app notification center.
+type NotificationPreference struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	IDUsuario uint   `json:"id_usuario" gorm:"column:id_usuario;not null;uniqueIndex:idx_preferencia_evento,priority:1"`
+	Evento    string `json:"evento" gorm:"type:varchar(30);not null;uniqueIndex:idx_preferencia_evento,priority:2"`
+	Canal     string `json:"canal" gorm:"type:varchar(10);not null"` // app | email | ninguno
+}
+
+// TableName specifies the table name for NotificationPreference
+func (NotificationPreference) TableName() string {
+	return "preferencias_notificacion"
+}
+
+// QuietHours is the daily window, in the business time zone, during which a
+// user is not interrupted. Desde after Hasta wraps past midnight.
+type QuietHours struct {
+	IDUsuario uint   `json:"-" gorm:"column:id_usuario;primaryKey;autoIncrement:false"`
+	Desde     string `json:"desde" gorm:"type:varchar(5);not null"` // HH:MM
+	Hasta     string `json:"hasta" gorm:"type:varchar(5);not null"` // HH:MM
+}
+
+// TableName specifies the table name for QuietHours
+func (QuietHours) TableName() string {
+	return "horario_silencio"
+}
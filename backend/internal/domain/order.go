@@ -2,36 +2,86 @@ package domain
 
 import "time"
 
+// Order states (production flow)
+const (
+	EstadoPendiente        = "Pendiente"
+	EstadoDisenoGrafico    = "Diseño Gráfico"
+	EstadoDisenoEnProceso  = "Diseño en Proceso"
+	EstadoEnEspera         = "En Espera"
+	EstadoImprenta         = "Imprenta (Área de Impresión)"
+	EstadoTallerImprenta   = "Taller de Imprenta"
+	EstadoTallerGrafico    = "Taller Gráfico"
+	EstadoInstalaciones    = "Instalaciones"
+	EstadoMetalurgica      = "Metalúrgica"
+	EstadoFinalizadoTaller = "Finalizado en Taller"
+	EstadoAlmacenEntrega   = "Almacén de Entrega"
+	EstadoEntregado        = "Entregado o Instalado"
+	EstadoMostrador        = "Mostrador"
+)
+
+// Order priorities
+const (
+	PrioridadAlta   = "Alta"
+	PrioridadNormal = "Normal"
+	PrioridadBaja   = "Baja"
+)
+
+// Order complexity levels
+const (
+	ComplejidadBaja  = "Baja"
+	ComplejidadMedia = "Media"
+	ComplejidadAlta  = "Alta"
+)
+
+// Checklist task states (EstadoKanban), the columns of an order's mini-kanban
+const (
+	TareaPendiente  = "Pendiente"
+	TareaEnProceso  = "En Proceso"
+	TareaFinalizada = "Finalizado"
+)
+
+// Order sectors (legacy single-sector field)
+const (
+	SectorTallerGrafico = "Taller Gráfico"
+	SectorMostrador     = "Mostrador"
+)
+
 // Order represents a work order in the system
 type Order struct {
-	ID                      uint      `json:"id" gorm:"primaryKey"`
-	NumeroOP                string    `json:"numero_op" gorm:"column:numero_op;not null"`
-	Cliente                 string    `json:"cliente" gorm:"not null"`
-	Descripcion             string    `json:"descripcion" gorm:"type:text"`
-	FechaEntrega            time.Time `json:"fecha_entrega" gorm:"type:date;not null"`
-	Estado                  string    `json:"estado" gorm:"not null;default:'Pendiente'"`
-	Prioridad               string    `json:"prioridad" gorm:"not null;default:'Normal'"`
-	FechaCreacion           time.Time `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
-	FechaIngreso            time.Time `json:"fecha_ingreso" gorm:"default:CURRENT_TIMESTAMP"`
-	OperarioAsignado        string    `json:"operario_asignado" gorm:"type:varchar(100)"`
-	Complejidad             string    `json:"complejidad" gorm:"type:enum('Baja','Media','Alta');default:'Media'"`
-	Sector                  string    `json:"sector" gorm:"type:enum('Taller Gráfico','Mostrador');default:'Taller Gráfico'"`
-	HoraEstimadaEntrega     *string   `json:"hora_estimada_entrega" gorm:"type:time"`
-	HoraEntregaEfectiva     *string   `json:"hora_entrega_efectiva" gorm:"type:time"`
-	IDUsuarioCreador        *uint     `json:"id_usuario_creador" gorm:"column:id_usuario_creador"`
-	UsuarioTrabajandoID     *uint     `json:"usuario_trabajando_id" gorm:"column:usuario_trabajando_id"`
-	UsuarioTrabajandoNombre *string   `json:"usuario_trabajando_nombre" gorm:"type:varchar(100)"`
+	ID                      uint       `json:"id" gorm:"primaryKey"`
+	NumeroOP                string     `json:"numero_op" gorm:"column:numero_op;not null;uniqueIndex"`
+	Cliente                 string     `json:"cliente" gorm:"not null"`
+	Descripcion             string     `json:"descripcion" gorm:"type:text"`
+	FechaEntrega            time.Time  `json:"fecha_entrega" gorm:"type:date;not null"`
+	Estado                  string     `json:"estado" gorm:"not null;default:'Pendiente';index:idx_ordenes_columna,priority:1"`
+	Posicion                int        `json:"posicion" gorm:"not null;default:0;index:idx_ordenes_columna,priority:2"` // rank within the Estado column
+	Prioridad               string     `json:"prioridad" gorm:"not null;default:'Normal'"`
+	FechaCreacion           time.Time  `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
+	FechaIngreso            time.Time  `json:"fecha_ingreso" gorm:"default:CURRENT_TIMESTAMP"`
+	OperarioAsignado        string     `json:"operario_asignado" gorm:"type:varchar(100)"`
+	Complejidad             string     `json:"complejidad" gorm:"type:varchar(10);default:'Media'"`
+	Sector                  string     `json:"sector" gorm:"type:varchar(50);default:'Taller Gráfico'"`
+	HoraEstimadaEntrega     *string    `json:"hora_estimada_entrega" gorm:"type:time"`
+	HoraEntregaEfectiva     *string    `json:"hora_entrega_efectiva" gorm:"type:time"`
+	IDUsuarioCreador        *uint      `json:"id_usuario_creador" gorm:"column:id_usuario_creador"`
+	UsuarioTrabajandoID     *uint      `json:"usuario_trabajando_id" gorm:"column:usuario_trabajando_id"`
+	UsuarioTrabajandoNombre *string    `json:"usuario_trabajando_nombre" gorm:"type:varchar(100)"`
 	TimestampInicioTrabajo  *time.Time `json:"timestamp_inicio_trabajo" gorm:"column:timestamp_inicio_trabajo"`
+	IDSectorActual          *uint      `json:"id_sector_actual" gorm:"column:id_sector_actual;index"` // where the job physically is
 
 	// Relations
-	UsuarioCreador *User            `json:"usuario_creador,omitempty" gorm:"foreignKey:IDUsuarioCreador"`
-	Materiales     []OrderMaterial  `json:"materiales,omitempty" gorm:"foreignKey:IDOrden"`
-	Sectores       []OrderSector    `json:"sectores,omitempty" gorm:"foreignKey:IDOrden"`
-	Archivos       []Attachment     `json:"archivos,omitempty" gorm:"foreignKey:IDOrden"`
+	UsuarioCreador *User             `json:"usuario_creador,omitempty" gorm:"foreignKey:IDUsuarioCreador"`
+	SectorActual   *Sector           `json:"sector_actual,omitempty" gorm:"foreignKey:IDSectorActual"`
+	Materiales     []OrderMaterial   `json:"materiales,omitempty" gorm:"foreignKey:IDOrden"`
+	Sectores       []OrderSector     `json:"sectores,omitempty" gorm:"foreignKey:IDOrden"`
+	Archivos       []Attachment      `json:"archivos,omitempty" gorm:"foreignKey:IDOrden"`
 	Historial      []MovementHistory `json:"historial,omitempty" gorm:"foreignKey:IDOrden"`
-	Tareas         []OrderTask      `json:"tareas,omitempty" gorm:"foreignKey:IDOrden"`
-	Comentarios    []OrderComment   `json:"comentarios,omitempty" gorm:"foreignKey:IDOrden"`
-	Enlaces        []OrderLink      `json:"enlaces,omitempty" gorm:"foreignKey:IDOrden"`
+	Tareas         []OrderTask       `json:"tareas,omitempty" gorm:"foreignKey:IDOrden"`
+	Comentarios    []OrderComment    `json:"comentarios,omitempty" gorm:"foreignKey:IDOrden"`
+	Enlaces        []OrderLink       `json:"enlaces,omitempty" gorm:"foreignKey:IDOrden"`
+
+	// Checklist summarizes Tareas; nil when the order has none
+	Checklist *ChecklistProgress `json:"checklist,omitempty" gorm:"-"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -49,9 +99,13 @@ type OrderMaterial struct {
 	IDMaterial uint    `json:"id_material" gorm:"column:id_material;not null;index"`
 	Cantidad   float64 `json:"cantidad" gorm:"type:decimal(10,3);default:1.000"`
 
+	// Stock held and used up by this line; kept in step with the order's Estado
+	CantidadReservada float64 `json:"cantidad_reservada" gorm:"type:decimal(10,3);not null;default:0"`
+	CantidadConsumida float64 `json:"cantidad_consumida" gorm:"type:decimal(10,3);not null;default:0"`
+
 	// Relations
-	Orden    Order    `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
-	Material Material `json:"material,omitempty" gorm:"foreignKey:IDMaterial"`
+	Orden    *Order    `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Material *Material `json:"material,omitempty" gorm:"foreignKey:IDMaterial"`
 }
 
 // TableName specifies the table name for OrderMaterial
@@ -59,16 +113,20 @@ func (OrderMaterial) TableName() string {
 	return "orden_materiales"
 }
 
-// OrderSector represents the relationship between orders and sectors
+// OrderSector represents the relationship between orders and sectors. The
+// sectors of an order, by Paso, are the route the job takes through the shop.
 type OrderSector struct {
-	ID             uint      `json:"id" gorm:"primaryKey"`
-	IDOrden        uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
-	IDSector       uint      `json:"id_sector" gorm:"column:id_sector;not null;index"`
-	FechaAsignacion time.Time `json:"fecha_asignacion" gorm:"default:CURRENT_TIMESTAMP"`
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	IDOrden         uint       `json:"id_orden" gorm:"column:id_orden;not null;index"`
+	IDSector        uint       `json:"id_sector" gorm:"column:id_sector;not null;index"`
+	FechaAsignacion time.Time  `json:"fecha_asignacion" gorm:"default:CURRENT_TIMESTAMP"`
+	Paso            int        `json:"paso" gorm:"not null;default:0"`
+	FechaInicio     *time.Time `json:"fecha_inicio"` // the job arrived at the sector
+	FechaFin        *time.Time `json:"fecha_fin"`    // the job left the sector
 
 	// Relations
-	Orden  Order  `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
-	Sector Sector `json:"sector,omitempty" gorm:"foreignKey:IDSector"`
+	Orden  *Order  `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Sector *Sector `json:"sector,omitempty" gorm:"foreignKey:IDSector"`
 }
 
 // TableName specifies the table name for OrderSector
@@ -76,16 +134,46 @@ func (OrderSector) TableName() string {
 	return "orden_sectores"
 }
 
-// Attachment represents a file attached to an order
+// Preview states of an attachment. New and migrated files start pending.
+const (
+	PreviewPendiente    = "pendiente"
+	PreviewListo        = "listo"
+	PreviewNoDisponible = "no_disponible" // not an image or PDF, or too large to decode
+	PreviewError        = "error"
+)
+
+// Attachment represents a file attached to an order. NombreArchivo is the key
+// the contents are stored under; NombreOriginal is the name it was uploaded with.
+// Successive versions of a file (e.g. design proofs) share IDGrupo, the ID of
+// the first version; at most one version of a group is approved by the client.
 type Attachment struct {
 	ID             uint      `json:"id" gorm:"primaryKey"`
 	IDOrden        uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
 	NombreArchivo  string    `json:"nombre_archivo" gorm:"type:varchar(255);not null"`
 	NombreOriginal string    `json:"nombre_original" gorm:"type:varchar(255);not null"`
+	TipoMime       string    `json:"tipo_mime" gorm:"type:varchar(100)"` // sniffed from the contents
+	Tamano         int64     `json:"tamano"`                             // bytes; 0 for files migrated without it
+	SHA256         string    `json:"sha256" gorm:"column:sha256;type:char(64);index"`
+	IDUsuario      *uint     `json:"id_usuario" gorm:"column:id_usuario"`
+	EstadoPreview  string    `json:"estado_preview" gorm:"type:varchar(20);not null;default:'pendiente';index"`
 	FechaSubida    time.Time `json:"fecha_subida" gorm:"default:CURRENT_TIMESTAMP"`
 
+	IDGrupo             *uint      `json:"id_grupo" gorm:"column:id_grupo;index"` // nil on files from before versioning: their own group
+	Version             int        `json:"version" gorm:"not null;default:1"`
+	Aprobado            bool       `json:"aprobado" gorm:"not null;default:false"`
+	FechaAprobacion     *time.Time `json:"fecha_aprobacion"`
+	IDUsuarioAprobacion *uint      `json:"id_usuario_aprobacion" gorm:"column:id_usuario_aprobacion"`
+
 	// Relations
-	Orden Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Orden *Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+}
+
+// GroupID returns the ID shared by every version of the file
+func (a *Attachment) GroupID() uint {
+	if a.IDGrupo != nil {
+		return *a.IDGrupo
+	}
+	return a.ID
 }
 
 // TableName specifies the table name for Attachment
@@ -95,19 +183,19 @@ func (Attachment) TableName() string {
 
 // MovementHistory represents the history of state changes for an order
 type MovementHistory struct {
-	ID                          uint       `json:"id" gorm:"primaryKey"`
-	IDOrden                     uint       `json:"id_orden" gorm:"column:id_orden;not null;index"`
-	IDUsuario                   uint       `json:"id_usuario" gorm:"column:id_usuario;not null;index"`
-	NombreUsuario               string     `json:"nombre_usuario" gorm:"type:varchar(100);not null"`
-	EstadoAnterior              *string    `json:"estado_anterior" gorm:"type:varchar(50)"`
-	EstadoNuevo                 *string    `json:"estado_nuevo" gorm:"type:varchar(50)"`
-	DuracionEstadoAnteriorSeg   *int       `json:"duracion_estado_anterior_seg"`
-	Timestamp                   time.Time  `json:"timestamp" gorm:"default:CURRENT_TIMESTAMP"`
-	Comentario                  *string    `json:"comentario" gorm:"type:text"`
+	ID                        uint      `json:"id" gorm:"primaryKey"`
+	IDOrden                   uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
+	IDUsuario                 uint      `json:"id_usuario" gorm:"column:id_usuario;not null;index"`
+	NombreUsuario             string    `json:"nombre_usuario" gorm:"type:varchar(100);not null"`
+	EstadoAnterior            *string   `json:"estado_anterior" gorm:"type:varchar(50)"`
+	EstadoNuevo               *string   `json:"estado_nuevo" gorm:"type:varchar(50)"`
+	DuracionEstadoAnteriorSeg *int      `json:"duracion_estado_anterior_seg"`
+	Timestamp                 time.Time `json:"timestamp" gorm:"default:CURRENT_TIMESTAMP"`
+	Comentario                *string   `json:"comentario" gorm:"type:text"`
 
 	// Relations
-	Orden  Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
-	Usuario User `json:"usuario,omitempty" gorm:"foreignKey:IDUsuario"`
+	Orden   *Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Usuario *User  `json:"usuario,omitempty" gorm:"foreignKey:IDUsuario"`
 }
 
 // TableName specifies the table name for MovementHistory
@@ -117,13 +205,14 @@ func (MovementHistory) TableName() string {
 
 // OrderTask represents a task within an order
 type OrderTask struct {
-	ID              uint   `json:"id" gorm:"primaryKey"`
-	IDOrden         uint   `json:"id_orden" gorm:"column:id_orden;not null;index"`
+	ID               uint   `json:"id" gorm:"primaryKey"`
+	IDOrden          uint   `json:"id_orden" gorm:"column:id_orden;not null;index"`
 	DescripcionTarea string `json:"descripcion_tarea" gorm:"type:varchar(255);not null"`
-	EstadoKanban    string `json:"estado_kanban" gorm:"type:enum('Pendiente','En Proceso','Finalizado');default:'Pendiente'"`
+	EstadoKanban     string `json:"estado_kanban" gorm:"type:varchar(20);default:'Pendiente'"`
+	Posicion         int    `json:"posicion" gorm:"not null;default:0"` // rank within the EstadoKanban column
 
 	// Relations
-	Orden Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Orden *Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
 }
 
 // TableName specifies the table name for OrderTask
@@ -131,17 +220,33 @@ func (OrderTask) TableName() string {
 	return "tareas"
 }
 
+// ChecklistProgress counts an order's checklist tasks by state
+type ChecklistProgress struct {
+	Total       int `json:"total"`
+	Pendientes  int `json:"pendientes"`
+	EnProceso   int `json:"en_proceso"`
+	Finalizadas int `json:"finalizadas"`
+	Porcentaje  int `json:"porcentaje"` // finished share, rounded down
+}
+
+// Open returns how many tasks aren't finished yet
+func (p ChecklistProgress) Open() int {
+	return p.Total - p.Finalizadas
+}
+
 // OrderComment represents a comment on an order
 type OrderComment struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	IDOrden   uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
-	IDUsuario uint      `json:"id_usuario" gorm:"column:id_usuario;not null;index"`
-	Comentario string   `json:"comentario" gorm:"type:text;not null"`
-	Timestamp time.Time `json:"timestamp" gorm:"default:CURRENT_TIMESTAMP"`
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	IDOrden    uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
+	IDUsuario  uint      `json:"id_usuario" gorm:"column:id_usuario;not null;index"`
+	Comentario string    `json:"comentario" gorm:"type:text;not null"`
+	Timestamp  time.Time `json:"timestamp" gorm:"default:CURRENT_TIMESTAMP"`
+	// FechaEdicion is set once the author edits the comment
+	FechaEdicion *time.Time `json:"fecha_edicion"`
 
 	// Relations
-	Orden  Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
-	Usuario User `json:"usuario,omitempty" gorm:"foreignKey:IDUsuario"`
+	Orden   *Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Usuario *User  `json:"usuario,omitempty" gorm:"foreignKey:IDUsuario"`
 }
 
 // TableName specifies the table name for OrderComment
@@ -153,16 +258,17 @@ func (OrderComment) TableName() string {
 type OrderLink struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	IDOrden     uint      `json:"id_orden" gorm:"column:id_orden;not null;index"`
-	URL         string    `json:"url" gorm:"type:text;not null"`
+	URL         string    `json:"url" gorm:"type:text;not null"` // normalized
 	Descripcion *string   `json:"descripcion" gorm:"type:text"`
+	Titulo      *string   `json:"titulo" gorm:"type:varchar(255)"` // page title, when captured
+	IDUsuario   *uint     `json:"id_usuario" gorm:"column:id_usuario"`
 	Timestamp   time.Time `json:"timestamp" gorm:"default:CURRENT_TIMESTAMP"`
 
 	// Relations
-	Orden Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
+	Orden *Order `json:"orden,omitempty" gorm:"foreignKey:IDOrden"`
 }
 
 // TableName specifies the table name for OrderLink
 func (OrderLink) TableName() string {
 	return "enlaces_adjuntos"
 }
-
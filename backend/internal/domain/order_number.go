@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// OrderNumberSequence holds the last NumeroOP sequence value handed out for a scope
+// (the pattern with its dates filled in, e.g. "OP-2026-{SEQ:5}")
+type OrderNumberSequence struct {
+	Scope     string    `json:"scope" gorm:"type:varchar(100);primaryKey"`
+	LastValue int       `json:"last_value" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for OrderNumberSequence
+func (OrderNumberSequence) TableName() string {
+	return "secuencias_numero_op"
+}
+
+// ReservedOrderNumber is a NumeroOP that must never be generated, such as numbers
+// already used in the legacy system
+type ReservedOrderNumber struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	NumeroOP  string    `json:"numero_op" gorm:"column:numero_op;type:varchar(100);not null;uniqueIndex"`
+	Motivo    *string   `json:"motivo" gorm:"type:varchar(255)"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for ReservedOrderNumber
+func (ReservedOrderNumber) TableName() string {
+	return "numeros_op_reservados"
+}
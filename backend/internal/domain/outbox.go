@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// Aggregates outbox events belong to; events of one aggregate are relayed in order
+const (
+	AgregadoOrden   = "orden"
+	AgregadoTablero = "tablero"
+	AgregadoTarea   = "tarea"
+)
+
+// OutboxEvent states
+const (
+	OutboxPendiente = "pendiente"
+	OutboxPublicado = "publicado"
+	OutboxFallido   = "fallido" // gave up after the last retry
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change it
+// describes, then relayed to the subscribers by the outbox dispatcher. Entregado
+// lists the subscribers that already have it, so a retry only goes to the rest.
+type OutboxEvent struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	IDEvento         string     `json:"id_evento" gorm:"type:varchar(36);not null;uniqueIndex"` // stable across redeliveries
+	TipoAgregado     string     `json:"tipo_agregado" gorm:"type:varchar(20);not null;index:idx_outbox_agregado"`
+	IDAgregado       uint       `json:"id_agregado" gorm:"column:id_agregado;not null;index:idx_outbox_agregado"`
+	Evento           string     `json:"evento" gorm:"type:varchar(50);not null"`
+	Payload          string     `json:"payload" gorm:"type:text;not null"`
+	IDUsuarioDestino *uint      `json:"id_usuario_destino" gorm:"column:id_usuario_destino"`                // only this user's connections get it
+	Estado           string     `json:"estado" gorm:"type:varchar(10);not null;index:idx_outbox_pendiente"` // pendiente | publicado | fallido
+	Entregado        []string   `json:"entregado" gorm:"column:entregado_a;type:text;serializer:json"`
+	Intentos         int        `json:"intentos" gorm:"not null;default:0"`
+	UltimoError      *string    `json:"ultimo_error" gorm:"type:text"`
+	ProximoIntento   time.Time  `json:"proximo_intento" gorm:"not null;index:idx_outbox_pendiente"`
+	FechaCreacion    time.Time  `json:"fecha_creacion" gorm:"not null"`
+	FechaPublicacion *time.Time `json:"fecha_publicacion"`
+}
+
+// TableName specifies the table name for OutboxEvent
+func (OutboxEvent) TableName() string {
+	return "eventos_outbox"
+}
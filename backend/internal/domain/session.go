@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// RefreshToken is a single-use token that trades for a new access token. Every
+// refresh rotates it: the old row is revoked and points to its replacement, and
+// the new row joins the same family. A family is one login session and its ID
+// is carried by the access tokens as "sid".
+type RefreshToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID     string     `json:"family_id" gorm:"type:varchar(64);not null;index"`
+	TokenHash    string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+	UserAgent    *string    `json:"user_agent" gorm:"type:varchar(255)"`
+	IP           *string    `json:"ip" gorm:"type:varchar(64)"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for RefreshToken
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -7,12 +7,27 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles
+const (
+	RolAdministracion = "administracion"
+	RolTaller         = "taller"
+	RolMostrador      = "mostrador"
+)
+
+// RolAnonimo is the role of users created for anonymous sessions. It is not in
+// the permission policy, so every permission check denies it.
+const RolAnonimo = "anonimo"
+
+// AnonymousNamePrefix marks the Nombre of users created for anonymous sessions
+const AnonymousNamePrefix = "anon-"
+
 // User represents a user in the system
 type User struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	Nombre      string    `json:"nombre" gorm:"type:varchar(100);not null;uniqueIndex"`
 	PasswordHash string   `json:"-" gorm:"column:password_hash;type:varchar(255);not null"`
-	Rol         string    `json:"rol" gorm:"type:enum('administracion','taller','mostrador');not null"`
+	Rol         string    `json:"rol" gorm:"type:varchar(20);not null"`
+	Email       *string   `json:"email" gorm:"type:varchar(255)"` // where email notifications go
 	LastSeen    *time.Time `json:"last_seen" gorm:"column:last_seen"`
 
 	// Relations
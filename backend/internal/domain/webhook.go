@@ -0,0 +1,56 @@
+package domain
+
+import "time"
+
+// WebhookDelivery states
+const (
+	WebhookPendiente = "pendiente"
+	WebhookEnviado   = "enviado"
+	WebhookFallido   = "fallido" // gave up after the last retry
+)
+
+// Webhook is an outside system subscribed to board events. Eventos and Estados
+// filter what it gets; empty lists let everything through. Webhooks that keep
+// failing are deactivated until an administrator turns them back on.
+type Webhook struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	URL                 string     `json:"url" gorm:"type:varchar(500);not null"`
+	Descripcion         *string    `json:"descripcion" gorm:"type:varchar(255)"`
+	Secreto             string     `json:"-" gorm:"type:varchar(64);not null"` // HMAC key for the signature header
+	Eventos             []string   `json:"eventos" gorm:"type:text;serializer:json"`
+	Estados             []string   `json:"estados" gorm:"type:text;serializer:json"` // only events about orders in these states
+	Activo              bool       `json:"activo" gorm:"not null"`
+	FallosConsecutivos  int        `json:"fallos_consecutivos" gorm:"not null;default:0"`
+	FechaDesactivacion  *time.Time `json:"fecha_desactivacion"`
+	MotivoDesactivacion *string    `json:"motivo_desactivacion" gorm:"type:text"`
+	IDUsuarioCreador    uint       `json:"id_usuario_creador" gorm:"column:id_usuario_creador;not null"`
+	FechaCreacion       time.Time  `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for Webhook
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook. Payload is
+// the exact body that gets signed, so retries and replays send the same bytes.
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	IDWebhook      uint       `json:"id_webhook" gorm:"column:id_webhook;not null;index;uniqueIndex:idx_webhook_evento,where:reenvio_de IS NULL"`
+	IDEvento       string     `json:"id_evento" gorm:"type:varchar(36);not null;index;uniqueIndex:idx_webhook_evento,where:reenvio_de IS NULL"` // the same on replays, for receivers to dedupe
+	Evento         string     `json:"evento" gorm:"type:varchar(50);not null"`
+	Payload        string     `json:"payload" gorm:"type:text;not null"`
+	Estado         string     `json:"estado" gorm:"type:varchar(10);not null;index:idx_webhook_pendiente"` // pendiente | enviado | fallido
+	Intentos       int        `json:"intentos" gorm:"not null;default:0"`
+	UltimoCodigo   *int       `json:"ultimo_codigo"` // HTTP status of the last attempt
+	UltimoError    *string    `json:"ultimo_error" gorm:"type:text"`
+	ProximoIntento *time.Time `json:"proximo_intento" gorm:"index:idx_webhook_pendiente"`
+	ReenvioDe      *uint      `json:"reenvio_de" gorm:"column:reenvio_de"` // the delivery this replays
+	FechaCreacion  time.Time  `json:"fecha_creacion" gorm:"default:CURRENT_TIMESTAMP"`
+	FechaEnvio     *time.Time `json:"fecha_envio"`
+}
+
+// TableName specifies the table name for WebhookDelivery
+func (WebhookDelivery) TableName() string {
+	return "envios_webhook"
+}
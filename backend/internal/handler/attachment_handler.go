@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AttachmentHandler struct {
+	attachmentService service.AttachmentService
+}
+
+func NewAttachmentHandler(attachmentService service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+	}
+}
+
+func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	attachments, err := h.attachmentService.GetAttachments(uint(orderID), c.GetUint("user_id"), c.Query("all") == "true")
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	h.readUpload(c, func(filename string, content io.Reader) (*domain.Attachment, error) {
+		return h.attachmentService.UploadAttachment(uint(orderID), c.GetUint("user_id"), filename, content)
+	})
+}
+
+func (h *AttachmentHandler) UploadVersion(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	h.readUpload(c, func(filename string, content io.Reader) (*domain.Attachment, error) {
+		return h.attachmentService.UploadVersion(uint(orderID), uint(attachmentID), c.GetUint("user_id"), filename, content)
+	})
+}
+
+// readUpload passes the "file" part of a multipart body to upload as a stream,
+// so large print files never sit in memory, and writes the response
+func (h *AttachmentHandler) readUpload(c *gin.Context, upload func(filename string, content io.Reader) (*domain.Attachment, error)) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a multipart/form-data body"})
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		attachment, err := upload(part.FileName(), part)
+		part.Close()
+		if err != nil {
+			c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Attachment uploaded successfully",
+			"attachment": attachment,
+		})
+		return
+	}
+}
+
+func (h *AttachmentHandler) GetVersions(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	versions, err := h.attachmentService.GetVersions(uint(orderID), uint(attachmentID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+func (h *AttachmentHandler) ApproveVersion(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, err := h.attachmentService.ApproveVersion(uint(orderID), uint(attachmentID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Version approved successfully",
+		"attachment": attachment,
+	})
+}
+
+func (h *AttachmentHandler) WithdrawApproval(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	if err := h.attachmentService.WithdrawApproval(uint(orderID), uint(attachmentID), c.GetUint("user_id")); err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Approval withdrawn successfully"})
+}
+
+// DownloadAttachment streams the stored file. It is always sent as a download
+// with the sniffed type, so browsers never render uploaded content inline.
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, contents, err := h.attachmentService.OpenAttachment(uint(orderID), uint(attachmentID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+	defer contents.Close()
+
+	etag := ""
+	if attachment.SHA256 != "" {
+		etag = `"` + attachment.SHA256 + `"`
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	contentType := attachment.TipoMime
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// Files migrated from the old system don't know their size
+	size := attachment.Tamano
+	if size == 0 {
+		size = -1
+	}
+	headers := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.NombreOriginal}),
+		"X-Content-Type-Options": "nosniff",
+	}
+	if etag != "" {
+		headers["ETag"] = etag
+	}
+
+	c.DataFromReader(http.StatusOK, size, contentType, contents, headers)
+}
+
+// GetPreview serves the thumbnail (?size=thumb) or web-sized preview (default)
+// of an image or PDF attachment. While it is being generated the response is
+// 202 Accepted, so clients can retry.
+func (h *AttachmentHandler) GetPreview(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, preview, err := h.attachmentService.GetPreview(uint(orderID), uint(attachmentID), c.GetUint("user_id"), c.DefaultQuery("size", service.PreviewWeb))
+	if errors.Is(err, service.ErrPreviewPending) {
+		c.JSON(http.StatusAccepted, gin.H{"estado_preview": attachment.EstadoPreview})
+		return
+	}
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+	defer preview.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "image/jpeg", preview, map[string]string{
+		"Cache-Control":          "private, max-age=86400",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	if err := h.attachmentService.DeleteAttachment(uint(orderID), uint(attachmentID), c.GetUint("user_id")); err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+func attachmentErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrAttachmentNotAllowed), errors.Is(err, service.ErrAttachmentTypeMismatch):
+		return http.StatusUnsupportedMediaType
+	default:
+		return fallback
+	}
+}
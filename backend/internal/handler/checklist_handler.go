@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChecklistHandler struct {
+	checklistService service.ChecklistService
+}
+
+func NewChecklistHandler(checklistService service.ChecklistService) *ChecklistHandler {
+	return &ChecklistHandler{
+		checklistService: checklistService,
+	}
+}
+
+type TaskRequest struct {
+	DescripcionTarea string `json:"descripcion_tarea" binding:"required"`
+}
+
+type MoveTaskRequest struct {
+	EstadoKanban string `json:"estado_kanban" binding:"required"`
+	Posicion     *int   `json:"posicion"` // end of the column when omitted
+}
+
+func (h *ChecklistHandler) GetChecklist(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	checklist, err := h.checklistService.GetChecklist(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(checklistErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"checklist": checklist})
+}
+
+func (h *ChecklistHandler) AddTask(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.checklistService.AddTask(uint(orderID), c.GetUint("user_id"), req.DescripcionTarea)
+	if err != nil {
+		c.JSON(checklistErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Task added successfully",
+		"task":    task,
+	})
+}
+
+func (h *ChecklistHandler) UpdateTask(c *gin.Context) {
+	orderID, taskID, ok := parseTaskIDs(c)
+	if !ok {
+		return
+	}
+
+	var req TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.checklistService.UpdateTask(orderID, taskID, c.GetUint("user_id"), req.DescripcionTarea)
+	if err != nil {
+		c.JSON(checklistErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task updated successfully",
+		"task":    task,
+	})
+}
+
+func (h *ChecklistHandler) MoveTask(c *gin.Context) {
+	orderID, taskID, ok := parseTaskIDs(c)
+	if !ok {
+		return
+	}
+
+	var req MoveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	posicion := -1
+	if req.Posicion != nil {
+		if *req.Posicion < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "posicion must be zero or greater"})
+			return
+		}
+		posicion = *req.Posicion
+	}
+
+	task, err := h.checklistService.MoveTask(orderID, taskID, c.GetUint("user_id"), req.EstadoKanban, posicion)
+	if err != nil {
+		c.JSON(checklistErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task moved successfully",
+		"task":    task,
+	})
+}
+
+func (h *ChecklistHandler) DeleteTask(c *gin.Context) {
+	orderID, taskID, ok := parseTaskIDs(c)
+	if !ok {
+		return
+	}
+
+	if err := h.checklistService.DeleteTask(orderID, taskID, c.GetUint("user_id")); err != nil {
+		c.JSON(checklistErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+}
+
+// parseTaskIDs reads the order and task IDs from the path, answering 400 when
+// either is invalid
+func parseTaskIDs(c *gin.Context) (uint, uint, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return 0, 0, false
+	}
+	taskID, err := strconv.ParseUint(c.Param("taskId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, 0, false
+	}
+	return uint(orderID), uint(taskID), true
+}
+
+func checklistErrorStatus(err error, fallback int) int {
+	if errors.Is(err, authz.ErrForbidden) {
+		return http.StatusForbidden
+	}
+	return fallback
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LinkHandler struct {
+	linkService service.LinkService
+}
+
+func NewLinkHandler(linkService service.LinkService) *LinkHandler {
+	return &LinkHandler{
+		linkService: linkService,
+	}
+}
+
+type LinkRequest struct {
+	URL         string  `json:"url" binding:"required"`
+	Descripcion *string `json:"descripcion"`
+}
+
+func (h *LinkHandler) GetLinks(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	links, err := h.linkService.GetLinks(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(linkErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"links": links})
+}
+
+func (h *LinkHandler) AddLink(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req LinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, err := h.linkService.AddLink(uint(orderID), c.GetUint("user_id"), service.LinkInput{
+		URL:         req.URL,
+		Descripcion: req.Descripcion,
+	})
+	if err != nil {
+		c.JSON(linkErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Link added successfully",
+		"link":    link,
+	})
+}
+
+func (h *LinkHandler) RemoveLink(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	linkID, err := strconv.ParseUint(c.Param("linkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		return
+	}
+
+	if err := h.linkService.RemoveLink(uint(orderID), uint(linkID), c.GetUint("user_id")); err != nil {
+		c.JSON(linkErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Link removed successfully"})
+}
+
+func linkErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrLinkNotAllowed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrLinkExists):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
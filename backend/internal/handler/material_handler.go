@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/repository"
+	"task-board/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MaterialHandler struct {
+	materialService service.MaterialService
+}
+
+func NewMaterialHandler(materialService service.MaterialService) *MaterialHandler {
+	return &MaterialHandler{
+		materialService: materialService,
+	}
+}
+
+type MaterialRequest struct {
+	Codigo          *string  `json:"codigo"`
+	Descripcion     string   `json:"descripcion" binding:"required"`
+	PuntoReposicion *float64 `json:"punto_reposicion"`
+}
+
+type OrderMaterialRequest struct {
+	IDMaterial uint    `json:"id_material" binding:"required"`
+	Cantidad   float64 `json:"cantidad" binding:"required"`
+}
+
+type UpdateOrderMaterialRequest struct {
+	Cantidad float64 `json:"cantidad" binding:"required"`
+}
+
+func (r *MaterialRequest) toInput() service.MaterialInput {
+	return service.MaterialInput{
+		Codigo:          r.Codigo,
+		Descripcion:     r.Descripcion,
+		PuntoReposicion: r.PuntoReposicion,
+	}
+}
+
+func (h *MaterialHandler) GetMaterials(c *gin.Context) {
+	filter := repository.MaterialFilter{
+		Search:   c.Query("q"),
+		LowStock: c.Query("low_stock") == "true",
+	}
+
+	materials, err := h.materialService.GetMaterials(c.GetUint("user_id"), filter)
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"materials": materials})
+}
+
+func (h *MaterialHandler) GetMaterial(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+
+	material, err := h.materialService.GetMaterial(uint(materialID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"material": material})
+}
+
+func (h *MaterialHandler) CreateMaterial(c *gin.Context) {
+	var req MaterialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	material, err := h.materialService.CreateMaterial(c.GetUint("user_id"), req.toInput())
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Material created successfully",
+		"material": material,
+	})
+}
+
+func (h *MaterialHandler) UpdateMaterial(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+
+	var req MaterialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	material, err := h.materialService.UpdateMaterial(uint(materialID), c.GetUint("user_id"), req.toInput())
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material updated successfully",
+		"material": material,
+	})
+}
+
+func (h *MaterialHandler) DeleteMaterial(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+
+	if err := h.materialService.DeleteMaterial(uint(materialID), c.GetUint("user_id")); err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Material deleted successfully"})
+}
+
+func (h *MaterialHandler) GetOrderMaterials(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	lines, err := h.materialService.GetOrderMaterials(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"materiales": lines})
+}
+
+func (h *MaterialHandler) AddOrderMaterial(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req OrderMaterialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	line, err := h.materialService.AddOrderMaterial(uint(orderID), c.GetUint("user_id"), req.IDMaterial, req.Cantidad)
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Material added to order successfully",
+		"material": line,
+	})
+}
+
+func (h *MaterialHandler) UpdateOrderMaterial(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	lineID, err := strconv.ParseUint(c.Param("lineId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material line ID"})
+		return
+	}
+
+	var req UpdateOrderMaterialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	line, err := h.materialService.UpdateOrderMaterial(uint(orderID), uint(lineID), c.GetUint("user_id"), req.Cantidad)
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material line updated successfully",
+		"material": line,
+	})
+}
+
+func (h *MaterialHandler) RemoveOrderMaterial(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	lineID, err := strconv.ParseUint(c.Param("lineId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material line ID"})
+		return
+	}
+
+	if err := h.materialService.RemoveOrderMaterial(uint(orderID), uint(lineID), c.GetUint("user_id")); err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Material removed from order successfully"})
+}
+
+func materialErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrMaterialCodeTaken), errors.Is(err, service.ErrMaterialInUse), errors.Is(err, service.ErrMaterialOnOrder),
+		errors.Is(err, service.ErrNegativeStock):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
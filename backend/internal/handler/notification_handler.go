@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"task-board/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+type MarkAllReadRequest struct {
+	// HastaID limits the update to notifications up to this ID; 0 marks all
+	HastaID uint `json:"hasta_id"`
+}
+
+type MarkChangesSeenRequest struct {
+	IDs     []uint `json:"ids"`
+	HastaID uint   `json:"hasta_id"`
+}
+
+type UpdatePreferencesRequest struct {
+	// Preferencias maps each event to app, email or ninguno
+	Preferencias map[string]string `json:"preferencias"`
+	// Silencio set to null removes the quiet hours
+	Silencio *domain.QuietHours `json:"silencio"`
+	// ModoEmail is inmediato (default), horario or diario
+	ModoEmail string `json:"modo_email"`
+}
+
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	filter := repository.NotificationFilter{
+		UnreadOnly: c.Query("no_leidas") == "true",
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		beforeID, err := strconv.ParseUint(cursor, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		filter.BeforeID = uint(beforeID)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	page, err := h.notificationService.GetNotifications(c.GetUint("user_id"), filter)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	unread, err := h.notificationService.GetUnreadCount(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	notificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	unread, err := h.notificationService.MarkRead(uint(notificationID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Notification marked as read",
+		"unread_count": unread,
+	})
+}
+
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	var req MarkAllReadRequest
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	unread, err := h.notificationService.MarkAllRead(c.GetUint("user_id"), req.HastaID)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Notifications marked as read",
+		"unread_count": unread,
+	})
+}
+
+func (h *NotificationHandler) GetChanges(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	feed, err := h.notificationService.GetChanges(c.GetUint("user_id"), limit)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
+
+func (h *NotificationHandler) MarkChangesSeen(c *gin.Context) {
+	var req MarkChangesSeenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	unseen, err := h.notificationService.MarkChangesSeen(c.GetUint("user_id"), req.IDs, req.HastaID)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Changes marked as seen",
+		"unseen":  unseen,
+	})
+}
+
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	settings, err := h.notificationService.GetPreferences(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.notificationService.UpdatePreferences(c.GetUint("user_id"), service.PreferencesInput{
+		Preferencias: req.Preferencias,
+		Silencio:     req.Silencio,
+		ModoEmail:    req.ModoEmail,
+	})
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Preferences updated successfully",
+		"preferences": settings,
+	})
+}
+
+func (h *NotificationHandler) GetEmailDeliveries(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.notificationService.GetEmailDeliveries(c.GetUint("user_id"), limit)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func notificationErrorStatus(err error, fallback int) int {
+	if errors.Is(err, authz.ErrForbidden) {
+		return http.StatusForbidden
+	}
+	return fallback
+}
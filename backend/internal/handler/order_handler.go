@@ -0,0 +1,358 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"task-board/internal/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dateLayout is the format used for order dates (fecha_entrega)
+const dateLayout = "2006-01-02"
+
+type OrderHandler struct {
+	orderService service.OrderService
+}
+
+func NewOrderHandler(orderService service.OrderService) *OrderHandler {
+	return &OrderHandler{
+		orderService: orderService,
+	}
+}
+
+type OrderRequest struct {
+	NumeroOP            string  `json:"numero_op"` // generated when empty
+	Cliente             string  `json:"cliente" binding:"required"`
+	Descripcion         string  `json:"descripcion"`
+	FechaEntrega        string  `json:"fecha_entrega" binding:"required"`
+	Prioridad           string  `json:"prioridad"`
+	OperarioAsignado    string  `json:"operario_asignado"`
+	Complejidad         string  `json:"complejidad"`
+	Sector              string  `json:"sector"`
+	HoraEstimadaEntrega *string `json:"hora_estimada_entrega"`
+	HoraEntregaEfectiva *string `json:"hora_entrega_efectiva"`
+}
+
+type ChangeStateRequest struct {
+	Estado     string  `json:"estado" binding:"required"`
+	Comentario *string `json:"comentario"`
+}
+
+type MoveOrderRequest struct {
+	Estado     string  `json:"estado" binding:"required"`
+	Posicion   *int    `json:"posicion" binding:"required"`
+	Comentario *string `json:"comentario"`
+}
+
+type ReserveNumbersRequest struct {
+	Numeros []string `json:"numeros" binding:"required,min=1"`
+	Motivo  *string  `json:"motivo"`
+}
+
+func (r *OrderRequest) toInput() (service.OrderInput, error) {
+	fechaEntrega, err := time.Parse(dateLayout, r.FechaEntrega)
+	if err != nil {
+		return service.OrderInput{}, err
+	}
+
+	return service.OrderInput{
+		NumeroOP:            r.NumeroOP,
+		Cliente:             r.Cliente,
+		Descripcion:         r.Descripcion,
+		FechaEntrega:        fechaEntrega,
+		Prioridad:           r.Prioridad,
+		OperarioAsignado:    r.OperarioAsignado,
+		Complejidad:         r.Complejidad,
+		Sector:              r.Sector,
+		HoraEstimadaEntrega: r.HoraEstimadaEntrega,
+		HoraEntregaEfectiva: r.HoraEntregaEfectiva,
+	}, nil
+}
+
+func (h *OrderHandler) GetOrders(c *gin.Context) {
+	filter := repository.OrderFilter{
+		Estado:           c.Query("estado"),
+		Prioridad:        c.Query("prioridad"),
+		Sector:           c.Query("sector"),
+		Cliente:          c.Query("cliente"),
+		OperarioAsignado: c.Query("operario"),
+		Search:           c.Query("q"),
+	}
+	if sectorActual := c.Query("sector_actual"); sectorActual != "" {
+		sectorID, err := strconv.ParseUint(sectorActual, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sector_actual"})
+			return
+		}
+		id := uint(sectorID)
+		filter.IDSectorActual = &id
+	}
+
+	orders, err := h.orderService.GetOrders(c.GetUint("user_id"), filter)
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var req OrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input, err := req.toInput()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fecha_entrega format, expected YYYY-MM-DD"})
+		return
+	}
+
+	order, err := h.orderService.CreateOrder(userID, input)
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order created successfully",
+		"order":   order,
+	})
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrder(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+func (h *OrderHandler) UpdateOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req OrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input, err := req.toInput()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fecha_entrega format, expected YYYY-MM-DD"})
+		return
+	}
+
+	order, err := h.orderService.UpdateOrder(uint(orderID), userID, input)
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order updated successfully",
+		"order":   order,
+	})
+}
+
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	if err := h.orderService.DeleteOrder(uint(orderID), userID); err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
+}
+
+func (h *OrderHandler) ChangeState(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req ChangeStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.orderService.ChangeState(uint(orderID), userID, req.Estado, req.Comentario)
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order state updated successfully",
+		"order":   order,
+	})
+}
+
+func (h *OrderHandler) GetHistory(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	history, err := h.orderService.GetHistory(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+func (h *OrderHandler) GetWorkflow(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"workflow": h.orderService.GetWorkflow()})
+}
+
+func (h *OrderHandler) MoveOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req MoveOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.orderService.MoveOrder(uint(orderID), userID, req.Estado, *req.Posicion, req.Comentario)
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order moved successfully",
+		"order":   order,
+	})
+}
+
+func (h *OrderHandler) GetBoard(c *gin.Context) {
+	columns, err := h.orderService.GetBoard(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"columns": columns})
+}
+
+func (h *OrderHandler) ClaimOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.ClaimOrder(uint(orderID), userID, c.Query("override") == "true")
+	if err != nil {
+		h.respondClaimError(c, order, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order claimed successfully",
+		"order":   order,
+	})
+}
+
+func (h *OrderHandler) ReleaseOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.ReleaseOrder(uint(orderID), userID, c.Query("override") == "true")
+	if err != nil {
+		h.respondClaimError(c, order, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order released successfully",
+		"order":   order,
+	})
+}
+
+func (h *OrderHandler) respondClaimError(c *gin.Context, order *domain.Order, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderClaimed):
+		// Include the order so the client can show who holds the claim
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "order": order})
+	default:
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+	}
+}
+
+func (h *OrderHandler) ReserveNumbers(c *gin.Context) {
+	var req ReserveNumbersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reserved, err := h.orderService.ReserveNumbers(c.GetUint("user_id"), req.Numeros, req.Motivo)
+	if err != nil {
+		c.JSON(orderErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Order numbers reserved successfully",
+		"reserved": reserved,
+	})
+}
+
+// orderErrorStatus maps service errors to HTTP statuses, using fallback for the rest
+func orderErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrOrderClaimed), errors.Is(err, service.ErrChecklistOpen),
+		errors.Is(err, repository.ErrNumeroOPTaken):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
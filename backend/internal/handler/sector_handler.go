@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SectorHandler struct {
+	sectorService service.SectorService
+}
+
+func NewSectorHandler(sectorService service.SectorService) *SectorHandler {
+	return &SectorHandler{
+		sectorService: sectorService,
+	}
+}
+
+type SectorRequest struct {
+	Nombre            string   `json:"nombre" binding:"required"`
+	Color             string   `json:"color"`
+	Activo            *bool    `json:"activo"`
+	CapacidadHorasDia *float64 `json:"capacidad_horas_dia"`
+}
+
+type ReorderSectorsRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1"`
+}
+
+type RouteRequest struct {
+	SectorIDs []uint `json:"sector_ids" binding:"required"`
+}
+
+type CurrentSectorRequest struct {
+	IDSector *uint `json:"id_sector"` // null takes the order out of every sector
+}
+
+func (r *SectorRequest) toInput() service.SectorInput {
+	return service.SectorInput{
+		Nombre:            r.Nombre,
+		Color:             r.Color,
+		Activo:            r.Activo,
+		CapacidadHorasDia: r.CapacidadHorasDia,
+	}
+}
+
+func (h *SectorHandler) GetSectors(c *gin.Context) {
+	sectors, err := h.sectorService.GetSectors(c.GetUint("user_id"), c.Query("all") == "true")
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sectors": sectors})
+}
+
+func (h *SectorHandler) GetSector(c *gin.Context) {
+	sectorID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sector ID"})
+		return
+	}
+
+	sector, err := h.sectorService.GetSector(uint(sectorID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sector": sector})
+}
+
+func (h *SectorHandler) CreateSector(c *gin.Context) {
+	var req SectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sector, err := h.sectorService.CreateSector(c.GetUint("user_id"), req.toInput())
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Sector created successfully",
+		"sector":  sector,
+	})
+}
+
+func (h *SectorHandler) UpdateSector(c *gin.Context) {
+	sectorID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sector ID"})
+		return
+	}
+
+	var req SectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sector, err := h.sectorService.UpdateSector(uint(sectorID), c.GetUint("user_id"), req.toInput())
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sector updated successfully",
+		"sector":  sector,
+	})
+}
+
+func (h *SectorHandler) DeleteSector(c *gin.Context) {
+	sectorID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sector ID"})
+		return
+	}
+
+	if err := h.sectorService.DeleteSector(uint(sectorID), c.GetUint("user_id")); err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sector deleted successfully"})
+}
+
+func (h *SectorHandler) ReorderSectors(c *gin.Context) {
+	var req ReorderSectorsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sectors, err := h.sectorService.ReorderSectors(c.GetUint("user_id"), req.IDs)
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sectors": sectors})
+}
+
+func (h *SectorHandler) GetRoute(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	route, err := h.sectorService.GetRoute(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"route": route})
+}
+
+func (h *SectorHandler) SetRoute(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, err := h.sectorService.SetRoute(uint(orderID), c.GetUint("user_id"), req.SectorIDs)
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Route updated successfully",
+		"route":   route,
+	})
+}
+
+func (h *SectorHandler) AdvanceRoute(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.sectorService.AdvanceRoute(uint(orderID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+func (h *SectorHandler) SetCurrentSector(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req CurrentSectorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.sectorService.SetCurrentSector(uint(orderID), c.GetUint("user_id"), req.IDSector)
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+func (h *SectorHandler) GetWorkload(c *gin.Context) {
+	var desde time.Time
+	if raw := c.Query("desde"); raw != "" {
+		parsed, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "desde must be a date like 2024-01-31"})
+			return
+		}
+		desde = parsed
+	}
+	dias, _ := strconv.Atoi(c.Query("dias"))
+
+	report, err := h.sectorService.GetWorkload(c.GetUint("user_id"), desde, dias)
+	if err != nil {
+		c.JSON(sectorErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"workload": report})
+}
+
+func sectorErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrSectorNameTaken), errors.Is(err, service.ErrSectorInUse):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-board/internal/authz"
+	"task-board/internal/repository"
+	"task-board/internal/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SmartAlertHandler struct {
+	smartAlertService service.SmartAlertService
+}
+
+func NewSmartAlertHandler(smartAlertService service.SmartAlertService) *SmartAlertHandler {
+	return &SmartAlertHandler{
+		smartAlertService: smartAlertService,
+	}
+}
+
+type SnoozeAlertRequest struct {
+	// Horas is how long the alert stays silenced; 0 wakes it up
+	Horas *float64 `json:"horas" binding:"required"`
+}
+
+func (h *SmartAlertHandler) GetAlerts(c *gin.Context) {
+	filter := repository.SmartAlertFilter{
+		TipoAlerta:      c.Query("tipo"),
+		IncludeResolved: c.Query("resueltas") == "true",
+		IncludeSnoozed:  c.Query("silenciadas") == "true",
+	}
+
+	alerts, err := h.smartAlertService.GetAlerts(c.GetUint("user_id"), filter)
+	if err != nil {
+		c.JSON(smartAlertErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+func (h *SmartAlertHandler) ResolveAlert(c *gin.Context) {
+	alertID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	alert, err := h.smartAlertService.ResolveAlert(uint(alertID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(smartAlertErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Alert resolved successfully",
+		"alert":   alert,
+	})
+}
+
+func (h *SmartAlertHandler) SnoozeAlert(c *gin.Context) {
+	alertID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	var req SnoozeAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duration := time.Duration(*req.Horas * float64(time.Hour))
+	alert, err := h.smartAlertService.SnoozeAlert(uint(alertID), c.GetUint("user_id"), duration)
+	if err != nil {
+		c.JSON(smartAlertErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Alert snoozed successfully",
+		"alert":   alert,
+	})
+}
+
+func smartAlertErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAlertResolved):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
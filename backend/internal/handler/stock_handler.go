@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"task-board/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StockHandler struct {
+	stockService service.StockService
+}
+
+func NewStockHandler(stockService service.StockService) *StockHandler {
+	return &StockHandler{
+		stockService: stockService,
+	}
+}
+
+type StockReceiptRequest struct {
+	Cantidad float64 `json:"cantidad" binding:"required"`
+	Motivo   *string `json:"motivo"`
+}
+
+type StockAdjustmentRequest struct {
+	Cantidad float64 `json:"cantidad" binding:"required"` // signed change to the stock on hand
+	Motivo   string  `json:"motivo" binding:"required"`
+}
+
+func (h *StockHandler) GetMovements(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	movements, err := h.stockService.GetMovements(uint(materialID), c.GetUint("user_id"), limit)
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"movements": movements})
+}
+
+func (h *StockHandler) ReceiveStock(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+
+	var req StockReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	material, err := h.stockService.ReceiveStock(uint(materialID), c.GetUint("user_id"), req.Cantidad, req.Motivo)
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Stock received successfully",
+		"material": material,
+	})
+}
+
+func (h *StockHandler) AdjustStock(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+
+	var req StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	material, err := h.stockService.AdjustStock(uint(materialID), c.GetUint("user_id"), req.Cantidad, req.Motivo)
+	if err != nil {
+		c.JSON(materialErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Stock adjusted successfully",
+		"material": material,
+	})
+}
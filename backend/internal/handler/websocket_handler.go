@@ -17,5 +17,6 @@ func NewWebSocketHandler(hub *websocket.Hub) *WebSocketHandler {
 }
 
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
-	h.hub.HandleWebSocket(c.Writer, c.Request)
+	// Anonymous sessions have no rol, so they only get their own messages
+	h.hub.HandleWebSocket(c.Writer, c.Request, c.GetUint("user_id"), c.GetString("rol"))
 }
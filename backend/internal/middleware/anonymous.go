@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"task-board/internal/domain"
 
@@ -17,24 +16,27 @@ func AnonymousUserMiddleware(db *gorm.DB) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if len(anonymousUserID) > 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid X-Anonymous-User-Id header"})
+			c.Abort()
+			return
+		}
+
+		// Use UUID as unique identifier in nombre field
+		nombre := domain.AnonymousNamePrefix + anonymousUserID
 
-		// Use UUID as unique identifier in email field
-		userEmail := fmt.Sprintf("%s@anonymous.local", anonymousUserID)
-		
 		var user domain.User
-		result := db.Where("email = ?", userEmail).First(&user)
-		
+		result := db.Where("nombre = ?", nombre).First(&user)
+
 		if result.Error == gorm.ErrRecordNotFound {
-			// Create new anonymous user
-			username := fmt.Sprintf("User_%s", anonymousUserID[:8])
+			// Create new anonymous user. The password hash is not a valid bcrypt
+			// hash, so the account can never be used to log in.
 			user = domain.User{
-				Email:     userEmail,
-				Username:  username,
-				Password:  "anonymous", // Password not used for anonymous users
-				FirstName: "Anonymous",
-				LastName:  "User",
+				Nombre:       nombre,
+				PasswordHash: "anonymous",
+				Rol:          domain.RolAnonimo,
 			}
-			
+
 			if err := db.Create(&user).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create anonymous user"})
 				c.Abort()
@@ -50,4 +52,3 @@ func AnonymousUserMiddleware(db *gorm.DB) gin.HandlerFunc {
 		c.Next()
 	}
 }
-
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -8,7 +9,57 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// SessionChecker reports whether the login session an access token belongs to
+// has not been logged out or revoked
+type SessionChecker interface {
+	IsSessionActive(sessionID string) bool
+}
+
+// TokenClaims are the claims the API reads from an access token
+type TokenClaims struct {
+	UserID    uint
+	Rol       string
+	SessionID string
+}
+
+// ParseToken validates an access token and checks its session
+func ParseToken(tokenString, jwtSecret string, sessions SessionChecker) (*TokenClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, errors.New("Invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("Invalid token claims")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errors.New("Invalid user ID in token")
+	}
+
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" || !sessions.IsSessionActive(sessionID) {
+		return nil, errors.New("Session expired or revoked")
+	}
+
+	rol, _ := claims["rol"].(string)
+	return &TokenClaims{UserID: uint(userID), Rol: rol, SessionID: sessionID}, nil
+}
+
+// SetClaims stores the token claims in the request context
+func SetClaims(c *gin.Context, claims *TokenClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("session_id", claims.SessionID)
+	if claims.Rol != "" {
+		c.Set("rol", claims.Rol)
+	}
+}
+
+func AuthMiddleware(jwtSecret string, sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,31 +75,14 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
-			return
-		}
-
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		claims, err := ParseToken(tokenString, jwtSecret, sessions)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", uint(userID))
+		SetClaims(c, claims)
 		c.Next()
 	}
 }
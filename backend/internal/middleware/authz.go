@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"task-board/internal/authz"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through users whose JWT role is one of roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rol := authz.NormalizeRole(c.GetString("rol"))
+		for _, allowed := range roles {
+			if rol == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		c.Abort()
+	}
+}
+
+// RequirePermission only lets through users whose JWT role holds perm in the policy table
+func RequirePermission(perm authz.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authz.Can(c.GetString("rol"), perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -28,7 +28,7 @@ func CORS() gin.HandlerFunc {
 				}
 			}
 			// If no match found but we have a single allowed origin, use it
-			if c.Header("Access-Control-Allow-Origin") == "" && len(allowedOrigins) == 1 {
+			if c.Writer.Header().Get("Access-Control-Allow-Origin") == "" && len(allowedOrigins) == 1 {
 				c.Header("Access-Control-Allow-Origin", strings.TrimSpace(allowedOrigins[0]))
 				c.Header("Access-Control-Allow-Credentials", "true")
 			}
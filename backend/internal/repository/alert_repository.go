@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AlertCandidate is an open order with what the alert scheduler checks it against
+type AlertCandidate struct {
+	ID                  uint
+	NumeroOP            string
+	Cliente             string
+	Estado              string
+	Prioridad           string
+	OperarioAsignado    string
+	FechaEntrega        time.Time
+	HoraEstimadaEntrega *string
+	// EnEstadoDesde is when the order entered its state: the last history
+	// entry, or its arrival when it has none
+	EnEstadoDesde time.Time
+}
+
+type AlertRepository interface {
+	// ListCandidates returns the orders outside the given states
+	ListCandidates(excludedEstados []string) ([]AlertCandidate, error)
+	// Record stores the alert unless it was already sent, and reports whether it was new
+	Record(alert *domain.AlertSent) (bool, error)
+}
+
+type alertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+func (r *alertRepository) ListCandidates(excludedEstados []string) ([]AlertCandidate, error) {
+	var candidates []AlertCandidate
+	query := r.db.Table("ordenes_trabajo AS o").
+		Select(`o.id, o.numero_op, o.cliente, o.estado, o.prioridad, o.operario_asignado, o.fecha_entrega, o.hora_estimada_entrega,
+			COALESCE((SELECT MAX(h.timestamp) FROM historial_movimientos h WHERE h.id_orden = o.id), o.fecha_ingreso) AS en_estado_desde`)
+	if len(excludedEstados) > 0 {
+		query = query.Where("o.estado NOT IN ?", excludedEstados)
+	}
+	err := query.Order("o.id ASC").Scan(&candidates).Error
+	return candidates, err
+}
+
+func (r *alertRepository) Record(alert *domain.AlertSent) (bool, error) {
+	result := r.db.Omit("Orden").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(alert)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"fmt"
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// currentAttachmentVersion keeps, per file, the approved version or else the
+// latest one. It is a condition on archivos_adjuntos rows.
+const currentAttachmentVersion = `archivos_adjuntos.id IN (
+	SELECT DISTINCT ON (COALESCE(v.id_grupo, v.id)) v.id
+	FROM archivos_adjuntos v
+	WHERE v.id_orden = archivos_adjuntos.id_orden
+	ORDER BY COALESCE(v.id_grupo, v.id), v.aprobado DESC, v.version DESC, v.id DESC
+)`
+
+type AttachmentRepository interface {
+	Create(attachment *domain.Attachment) error
+	// GetByID returns the attachment only when it belongs to the order
+	GetByID(orderID, id uint) (*domain.Attachment, error)
+	ListByOrder(orderID uint) ([]domain.Attachment, error)
+	// ListCurrent returns one version per file: the approved one, else the latest
+	ListCurrent(orderID uint) ([]domain.Attachment, error)
+	Delete(id uint) error
+
+	// Versions of a file
+	ListVersions(groupID uint) ([]domain.Attachment, error)
+	// LockGroup serializes changes to a file's versions until the transaction ends
+	LockGroup(groupID uint) error
+	NextVersion(groupID uint) (int, error)
+	SetGroup(id, groupID uint) error
+	// Approve marks the version as approved and withdraws any other approval in its group
+	Approve(id, groupID, userID uint, now time.Time) error
+	ClearApproval(groupID uint) error
+
+	// ListPendingPreviews returns the oldest attachments still waiting for previews
+	ListPendingPreviews(limit int) ([]domain.Attachment, error)
+	// SetPreviewState reports false when the attachment no longer exists
+	SetPreviewState(id uint, estado string) (bool, error)
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) Create(attachment *domain.Attachment) error {
+	return r.db.Omit("Orden").Create(attachment).Error
+}
+
+func (r *attachmentRepository) GetByID(orderID, id uint) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	err := r.db.Where("id_orden = ?", orderID).First(&attachment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *attachmentRepository) ListByOrder(orderID uint) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	err := r.db.Where("id_orden = ?", orderID).
+		Order("fecha_subida ASC, id ASC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) ListCurrent(orderID uint) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	err := r.db.Where("id_orden = ?", orderID).
+		Where(currentAttachmentVersion).
+		Order("fecha_subida ASC, id ASC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.Attachment{}, id).Error
+}
+
+func (r *attachmentRepository) ListPendingPreviews(limit int) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	err := r.db.Where("estado_preview = ?", domain.PreviewPendiente).
+		Order("id ASC").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) SetPreviewState(id uint, estado string) (bool, error) {
+	result := r.db.Model(&domain.Attachment{}).Where("id = ?", id).Update("estado_preview", estado)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *attachmentRepository) ListVersions(groupID uint) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	err := r.db.Where("COALESCE(id_grupo, id) = ?", groupID).
+		Order("version DESC, id DESC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) LockGroup(groupID uint) error {
+	return r.db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("archivos_adjuntos:%d", groupID)).Error
+}
+
+func (r *attachmentRepository) NextVersion(groupID uint) (int, error) {
+	var latest int
+	err := r.db.Model(&domain.Attachment{}).
+		Where("COALESCE(id_grupo, id) = ?", groupID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error
+	return latest + 1, err
+}
+
+func (r *attachmentRepository) SetGroup(id, groupID uint) error {
+	return r.db.Model(&domain.Attachment{}).Where("id = ?", id).Update("id_grupo", groupID).Error
+}
+
+func (r *attachmentRepository) Approve(id, groupID, userID uint, now time.Time) error {
+	if err := r.ClearApproval(groupID); err != nil {
+		return err
+	}
+	return r.db.Model(&domain.Attachment{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"aprobado":              true,
+		"fecha_aprobacion":      now,
+		"id_usuario_aprobacion": userID,
+	}).Error
+}
+
+func (r *attachmentRepository) ClearApproval(groupID uint) error {
+	return r.db.Model(&domain.Attachment{}).
+		Where("COALESCE(id_grupo, id) = ? AND aprobado = ?", groupID, true).
+		Updates(map[string]interface{}{
+			"aprobado":              false,
+			"fecha_aprobacion":      nil,
+			"id_usuario_aprobacion": nil,
+		}).Error
+}
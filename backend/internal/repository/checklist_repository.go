@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type ChecklistRepository interface {
+	Create(task *domain.OrderTask) error
+	// GetByID returns the task only when it belongs to the order
+	GetByID(orderID, id uint) (*domain.OrderTask, error)
+	ListByOrder(orderID uint) ([]domain.OrderTask, error)
+	UpdateDescripcion(id uint, descripcion string) error
+	SetEstado(id uint, estado string) error
+	Delete(id uint) error
+
+	// GetColumn returns the IDs of the order's tasks in an EstadoKanban column, by position
+	GetColumn(orderID uint, estado string) ([]uint, error)
+	// SetPositions stores each task's index in ids as its position
+	SetPositions(ids []uint) error
+
+	Progress(orderID uint) (domain.ChecklistProgress, error)
+}
+
+type checklistRepository struct {
+	db *gorm.DB
+}
+
+func NewChecklistRepository(db *gorm.DB) ChecklistRepository {
+	return &checklistRepository{db: db}
+}
+
+func (r *checklistRepository) Create(task *domain.OrderTask) error {
+	return r.db.Omit("Orden").Create(task).Error
+}
+
+func (r *checklistRepository) GetByID(orderID, id uint) (*domain.OrderTask, error) {
+	var task domain.OrderTask
+	err := r.db.Where("id_orden = ?", orderID).First(&task, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (r *checklistRepository) ListByOrder(orderID uint) ([]domain.OrderTask, error) {
+	var tasks []domain.OrderTask
+	err := r.db.Where("id_orden = ?", orderID).
+		Order("posicion ASC, id ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *checklistRepository) UpdateDescripcion(id uint, descripcion string) error {
+	return r.db.Model(&domain.OrderTask{}).Where("id = ?", id).Update("descripcion_tarea", descripcion).Error
+}
+
+func (r *checklistRepository) SetEstado(id uint, estado string) error {
+	return r.db.Model(&domain.OrderTask{}).Where("id = ?", id).Update("estado_kanban", estado).Error
+}
+
+func (r *checklistRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.OrderTask{}, id).Error
+}
+
+func (r *checklistRepository) GetColumn(orderID uint, estado string) ([]uint, error) {
+	var ids []uint
+	query := r.db.Model(&domain.OrderTask{}).Where("id_orden = ?", orderID)
+	if estado == domain.TareaPendiente {
+		// Rows from before the checklist API may have no state
+		query = query.Where("estado_kanban = ? OR estado_kanban IS NULL", estado)
+	} else {
+		query = query.Where("estado_kanban = ?", estado)
+	}
+	err := query.Order("posicion ASC, id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *checklistRepository) SetPositions(ids []uint) error {
+	for i, id := range ids {
+		err := r.db.Model(&domain.OrderTask{}).
+			Where("id = ? AND posicion <> ?", id, i).
+			Update("posicion", i).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *checklistRepository) Progress(orderID uint) (domain.ChecklistProgress, error) {
+	progress, err := checklistProgress(r.db, []uint{orderID})
+	if err != nil {
+		return domain.ChecklistProgress{}, err
+	}
+	return progress[orderID], nil
+}
+
+// checklistProgress counts the checklist tasks of each order. Orders without
+// tasks are left out of the map.
+func checklistProgress(db *gorm.DB, orderIDs []uint) (map[uint]domain.ChecklistProgress, error) {
+	progress := make(map[uint]domain.ChecklistProgress)
+	if len(orderIDs) == 0 {
+		return progress, nil
+	}
+
+	var rows []struct {
+		IDOrden     uint
+		Total       int
+		EnProceso   int
+		Finalizadas int
+	}
+	err := db.Model(&domain.OrderTask{}).
+		Select(
+			"id_orden, COUNT(*) AS total, "+
+				"COUNT(*) FILTER (WHERE estado_kanban = ?) AS en_proceso, "+
+				"COUNT(*) FILTER (WHERE estado_kanban = ?) AS finalizadas",
+			domain.TareaEnProceso, domain.TareaFinalizada,
+		).
+		Where("id_orden IN ?", orderIDs).
+		Group("id_orden").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		progress[row.IDOrden] = domain.ChecklistProgress{
+			Total:       row.Total,
+			Pendientes:  row.Total - row.EnProceso - row.Finalizadas,
+			EnProceso:   row.EnProceso,
+			Finalizadas: row.Finalizadas,
+			Porcentaje:  row.Finalizadas * 100 / row.Total,
+		}
+	}
+	return progress, nil
+}
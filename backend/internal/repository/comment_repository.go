@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type CommentRepository interface {
+	Create(comment *domain.OrderComment) error
+	// GetByID returns the comment only when it belongs to the order
+	GetByID(orderID, id uint) (*domain.OrderComment, error)
+	ListByOrder(orderID uint) ([]domain.OrderComment, error)
+	Update(comment *domain.OrderComment) error
+	Delete(id uint) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+func (r *commentRepository) Create(comment *domain.OrderComment) error {
+	return r.db.Omit("Orden", "Usuario").Create(comment).Error
+}
+
+func (r *commentRepository) GetByID(orderID, id uint) (*domain.OrderComment, error) {
+	var comment domain.OrderComment
+	err := r.db.Preload("Usuario").Where("id_orden = ?", orderID).First(&comment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (r *commentRepository) ListByOrder(orderID uint) ([]domain.OrderComment, error) {
+	var comments []domain.OrderComment
+	err := r.db.Preload("Usuario").
+		Where("id_orden = ?", orderID).
+		Order("timestamp ASC, id ASC").
+		Find(&comments).Error
+	return comments, err
+}
+
+func (r *commentRepository) Update(comment *domain.OrderComment) error {
+	return r.db.Model(&domain.OrderComment{}).Where("id = ?", comment.ID).Updates(map[string]interface{}{
+		"comentario":    comment.Comentario,
+		"fecha_edicion": comment.FechaEdicion,
+	}).Error
+}
+
+func (r *commentRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.OrderComment{}, id).Error
+}
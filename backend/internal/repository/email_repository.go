@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"errors"
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrDigestTaken is returned when another dispatcher already put some of the
+// notifications in a digest
+var ErrDigestTaken = errors.New("notifications already in another digest")
+
+// DigestCandidate is a user on digest mode with notifications waiting
+type DigestCandidate struct {
+	IDUsuario uint
+	Modo      string
+	// Oldest is when the oldest waiting notification was created
+	Oldest time.Time
+}
+
+type EmailRepository interface {
+	// GetPreference returns nil when the user has none
+	GetPreference(userID uint) (*domain.EmailPreference, error)
+	// GetOrder loads only the order fields emails show
+	GetOrder(id uint) (*domain.Order, error)
+
+	// QueueImmediate stores the delivery and the notification it carries
+	QueueImmediate(delivery *domain.EmailDelivery, notification *domain.UserNotification) error
+	// ListDigestCandidates returns the users on the given modes with email
+	// notifications not yet in a digest
+	ListDigestCandidates(modes []string) ([]DigestCandidate, error)
+	// ListWaiting returns the user's email notifications not yet in a digest,
+	// with their order, grouped by order
+	ListWaiting(userID uint) ([]domain.UserNotification, error)
+	// QueueDigest stores the delivery and ties the notifications to it
+	QueueDigest(delivery *domain.EmailDelivery, notificationIDs []uint, now time.Time) error
+
+	// LeaseDue claims up to limit pending deliveries due at now by pushing
+	// their next attempt to leaseUntil, so concurrent dispatchers skip them
+	LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.EmailDelivery, error)
+	// UpdateAttempt saves the state, attempts, error and schedule of a delivery
+	UpdateAttempt(delivery *domain.EmailDelivery) error
+	ListDeliveries(userID uint, limit int) ([]domain.EmailDelivery, error)
+}
+
+type emailRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailRepository(db *gorm.DB) EmailRepository {
+	return &emailRepository{db: db}
+}
+
+func (r *emailRepository) GetPreference(userID uint) (*domain.EmailPreference, error) {
+	var preferences []domain.EmailPreference
+	if err := r.db.Where("id_usuario = ?", userID).Limit(1).Find(&preferences).Error; err != nil {
+		return nil, err
+	}
+	if len(preferences) == 0 {
+		return nil, nil
+	}
+	return &preferences[0], nil
+}
+
+func (r *emailRepository) GetOrder(id uint) (*domain.Order, error) {
+	var order domain.Order
+	if err := r.db.Select("id", "numero_op", "cliente").First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *emailRepository) QueueImmediate(delivery *domain.EmailDelivery, notification *domain.UserNotification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Usuario").Create(delivery).Error; err != nil {
+			return err
+		}
+		notification.IDEnvioEmail = &delivery.ID
+		return tx.Omit("User", "Orden").Create(notification).Error
+	})
+}
+
+func (r *emailRepository) ListDigestCandidates(modes []string) ([]DigestCandidate, error) {
+	var candidates []DigestCandidate
+	err := r.db.Table("preferencias_email AS p").
+		Select("p.id_usuario, p.modo, MIN(n.created_at) AS oldest").
+		Joins("JOIN user_notifications n ON n.user_id = p.id_usuario").
+		Where("p.modo IN ? AND n.canal = ? AND n.id_envio_email IS NULL", modes, domain.CanalEmail).
+		Group("p.id_usuario, p.modo").
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+func (r *emailRepository) ListWaiting(userID uint) ([]domain.UserNotification, error) {
+	var notifications []domain.UserNotification
+	err := r.db.Preload("Orden").
+		Where("user_id = ? AND canal = ? AND id_envio_email IS NULL", userID, domain.CanalEmail).
+		Order("orden_id NULLS LAST, id").
+		Find(&notifications).Error
+	return notifications, err
+}
+
+func (r *emailRepository) QueueDigest(delivery *domain.EmailDelivery, notificationIDs []uint, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Usuario").Create(delivery).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&domain.UserNotification{}).
+			Where("id IN ? AND id_envio_email IS NULL", notificationIDs).
+			Update("id_envio_email", delivery.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(notificationIDs)) {
+			return ErrDigestTaken
+		}
+		return tx.Model(&domain.EmailPreference{}).
+			Where("id_usuario = ?", delivery.IDUsuario).
+			Update("ultimo_resumen", now).Error
+	})
+}
+
+func (r *emailRepository) LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.EmailDelivery, error) {
+	var deliveries []domain.EmailDelivery
+	err := r.db.Raw(`UPDATE envios_email SET proximo_intento = ?
+		WHERE id IN (
+			SELECT id FROM envios_email
+			WHERE estado = ? AND proximo_intento <= ?
+			ORDER BY proximo_intento
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, leaseUntil, domain.EnvioPendiente, now, limit).
+		Scan(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *emailRepository) UpdateAttempt(delivery *domain.EmailDelivery) error {
+	return r.db.Model(delivery).
+		Select("estado", "intentos", "ultimo_error", "proximo_intento", "fecha_envio").
+		Updates(delivery).Error
+}
+
+func (r *emailRepository) ListDeliveries(userID uint, limit int) ([]domain.EmailDelivery, error) {
+	var deliveries []domain.EmailDelivery
+	err := r.db.Where("id_usuario = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type LinkRepository interface {
+	Create(link *domain.OrderLink) error
+	// GetByID returns the link only when it belongs to the order
+	GetByID(orderID, id uint) (*domain.OrderLink, error)
+	ListByOrder(orderID uint) ([]domain.OrderLink, error)
+	ExistsURL(orderID uint, url string) (bool, error)
+	Delete(id uint) error
+}
+
+type linkRepository struct {
+	db *gorm.DB
+}
+
+func NewLinkRepository(db *gorm.DB) LinkRepository {
+	return &linkRepository{db: db}
+}
+
+func (r *linkRepository) Create(link *domain.OrderLink) error {
+	return r.db.Omit("Orden").Create(link).Error
+}
+
+func (r *linkRepository) GetByID(orderID, id uint) (*domain.OrderLink, error) {
+	var link domain.OrderLink
+	err := r.db.Where("id_orden = ?", orderID).First(&link, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *linkRepository) ListByOrder(orderID uint) ([]domain.OrderLink, error) {
+	var links []domain.OrderLink
+	err := r.db.Where("id_orden = ?", orderID).
+		Order("timestamp ASC, id ASC").
+		Find(&links).Error
+	return links, err
+}
+
+func (r *linkRepository) ExistsURL(orderID uint, url string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.OrderLink{}).
+		Where("id_orden = ? AND url = ?", orderID, url).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *linkRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.OrderLink{}, id).Error
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaterialFilter narrows the catalog returned by MaterialRepository.List
+type MaterialFilter struct {
+	// Search matches the code or the description
+	Search string
+	// LowStock keeps only materials whose available stock is under their reorder point
+	LowStock bool
+}
+
+type MaterialRepository interface {
+	Create(material *domain.Material) error
+	GetByID(id uint) (*domain.Material, error)
+	// GetByIDForUpdate loads the material and locks its row until the
+	// surrounding transaction ends
+	GetByIDForUpdate(id uint) (*domain.Material, error)
+	GetByCodigo(codigo string) (*domain.Material, error)
+	// List returns the catalog ordered by description
+	List(filter MaterialFilter) ([]domain.Material, error)
+	Update(material *domain.Material) error
+	Delete(id uint) error
+	IsUsed(id uint) (bool, error)
+
+	// Bill of materials of an order
+	ListOrderMaterials(orderID uint) ([]domain.OrderMaterial, error)
+	GetOrderMaterial(orderID, lineID uint) (*domain.OrderMaterial, error)
+	FindOrderMaterial(orderID, materialID uint) (*domain.OrderMaterial, error)
+	CreateOrderMaterial(line *domain.OrderMaterial) error
+	UpdateOrderMaterial(line *domain.OrderMaterial) error
+	DeleteOrderMaterial(lineID uint) error
+}
+
+type materialRepository struct {
+	db *gorm.DB
+}
+
+func NewMaterialRepository(db *gorm.DB) MaterialRepository {
+	return &materialRepository{db: db}
+}
+
+func (r *materialRepository) Create(material *domain.Material) error {
+	return r.db.Create(material).Error
+}
+
+func (r *materialRepository) GetByID(id uint) (*domain.Material, error) {
+	var material domain.Material
+	err := r.db.First(&material, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &material, nil
+}
+
+func (r *materialRepository) GetByIDForUpdate(id uint) (*domain.Material, error) {
+	var material domain.Material
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&material, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &material, nil
+}
+
+func (r *materialRepository) GetByCodigo(codigo string) (*domain.Material, error) {
+	var material domain.Material
+	err := r.db.Where("codigo = ?", codigo).First(&material).Error
+	if err != nil {
+		return nil, err
+	}
+	return &material, nil
+}
+
+func (r *materialRepository) List(filter MaterialFilter) ([]domain.Material, error) {
+	var materials []domain.Material
+	query := r.db.Model(&domain.Material{})
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where("codigo ILIKE ? OR descripcion ILIKE ?", like, like)
+	}
+	if filter.LowStock {
+		query = query.Where("punto_reposicion IS NOT NULL AND stock_actual - stock_reservado < punto_reposicion")
+	}
+	err := query.Order("descripcion ASC, id ASC").Find(&materials).Error
+	return materials, err
+}
+
+// Update saves the catalog fields only; stock totals change through StockRepository
+func (r *materialRepository) Update(material *domain.Material) error {
+	return r.db.Model(material).Select("codigo", "descripcion", "punto_reposicion").Updates(material).Error
+}
+
+func (r *materialRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.Material{}, id).Error
+}
+
+func (r *materialRepository) IsUsed(id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.OrderMaterial{}).Where("id_material = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *materialRepository) ListOrderMaterials(orderID uint) ([]domain.OrderMaterial, error) {
+	var lines []domain.OrderMaterial
+	err := r.db.Preload("Material").Where("id_orden = ?", orderID).Order("id ASC").Find(&lines).Error
+	return lines, err
+}
+
+func (r *materialRepository) GetOrderMaterial(orderID, lineID uint) (*domain.OrderMaterial, error) {
+	var line domain.OrderMaterial
+	err := r.db.Preload("Material").Where("id_orden = ?", orderID).First(&line, lineID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// FindOrderMaterial returns the order's line for the material, or nil if it has none
+func (r *materialRepository) FindOrderMaterial(orderID, materialID uint) (*domain.OrderMaterial, error) {
+	var lines []domain.OrderMaterial
+	err := r.db.Where("id_orden = ? AND id_material = ?", orderID, materialID).Limit(1).Find(&lines).Error
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+	return &lines[0], nil
+}
+
+func (r *materialRepository) CreateOrderMaterial(line *domain.OrderMaterial) error {
+	return r.db.Omit("Orden", "Material").Create(line).Error
+}
+
+func (r *materialRepository) UpdateOrderMaterial(line *domain.OrderMaterial) error {
+	return r.db.Omit("Orden", "Material").Save(line).Error
+}
+
+func (r *materialRepository) DeleteOrderMaterial(lineID uint) error {
+	return r.db.Delete(&domain.OrderMaterial{}, lineID).Error
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPreferenceRepository interface {
+	ListByUser(userID uint) ([]domain.NotificationPreference, error)
+	// ListByUsers returns the preferences of several users for one event
+	ListByUsers(userIDs []uint, evento string) ([]domain.NotificationPreference, error)
+	// GetQuietHours returns nil when the user has none
+	GetQuietHours(userID uint) (*domain.QuietHours, error)
+	ListQuietHours(userIDs []uint) ([]domain.QuietHours, error)
+	// Save replaces the user's preferences and quiet hours, and sets how email
+	// reaches them; nil quiet hours removes them
+	Save(userID uint, preferences []domain.NotificationPreference, quiet *domain.QuietHours, modoEmail string) error
+}
+
+type notificationPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceRepository(db *gorm.DB) NotificationPreferenceRepository {
+	return &notificationPreferenceRepository{db: db}
+}
+
+func (r *notificationPreferenceRepository) ListByUser(userID uint) ([]domain.NotificationPreference, error) {
+	var preferences []domain.NotificationPreference
+	err := r.db.Where("id_usuario = ?", userID).Order("evento").Find(&preferences).Error
+	return preferences, err
+}
+
+func (r *notificationPreferenceRepository) ListByUsers(userIDs []uint, evento string) ([]domain.NotificationPreference, error) {
+	var preferences []domain.NotificationPreference
+	if len(userIDs) == 0 {
+		return preferences, nil
+	}
+	err := r.db.Where("id_usuario IN ? AND evento = ?", userIDs, evento).Find(&preferences).Error
+	return preferences, err
+}
+
+func (r *notificationPreferenceRepository) GetQuietHours(userID uint) (*domain.QuietHours, error) {
+	var quiet []domain.QuietHours
+	if err := r.db.Where("id_usuario = ?", userID).Limit(1).Find(&quiet).Error; err != nil {
+		return nil, err
+	}
+	if len(quiet) == 0 {
+		return nil, nil
+	}
+	return &quiet[0], nil
+}
+
+func (r *notificationPreferenceRepository) ListQuietHours(userIDs []uint) ([]domain.QuietHours, error) {
+	var quiet []domain.QuietHours
+	if len(userIDs) == 0 {
+		return quiet, nil
+	}
+	err := r.db.Where("id_usuario IN ?", userIDs).Find(&quiet).Error
+	return quiet, err
+}
+
+func (r *notificationPreferenceRepository) Save(userID uint, preferences []domain.NotificationPreference, quiet *domain.QuietHours, modoEmail string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id_usuario = ?", userID).Delete(&domain.NotificationPreference{}).Error; err != nil {
+			return err
+		}
+		for i := range preferences {
+			preferences[i].ID = 0
+			preferences[i].IDUsuario = userID
+		}
+		if len(preferences) > 0 {
+			if err := tx.Create(&preferences).Error; err != nil {
+				return err
+			}
+		}
+
+		// The row is kept on immediate mode too, so a digest still waiting
+		// after the switch goes out
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id_usuario"}},
+			DoUpdates: clause.AssignmentColumns([]string{"modo"}),
+		}).Create(&domain.EmailPreference{IDUsuario: userID, Modo: modoEmail}).Error
+		if err != nil {
+			return err
+		}
+
+		if quiet == nil {
+			return tx.Where("id_usuario = ?", userID).Delete(&domain.QuietHours{}).Error
+		}
+		quiet.IDUsuario = userID
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id_usuario"}},
+			DoUpdates: clause.AssignmentColumns([]string{"desde", "hasta"}),
+		}).Create(quiet).Error
+	})
+}
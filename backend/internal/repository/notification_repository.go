@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationFilter pages through a user's notifications, newest first
+type NotificationFilter struct {
+	// BeforeID is the cursor: only notifications older than it are returned
+	BeforeID   uint
+	Limit      int
+	UnreadOnly bool
+}
+
+// OrderChange is a history entry on one of the user's orders
+type OrderChange struct {
+	IDHistorial    uint      `json:"id_historial"`
+	IDOrden        uint      `json:"id_orden"`
+	NumeroOP       string    `json:"numero_op"`
+	Cliente        string    `json:"cliente"`
+	NombreUsuario  string    `json:"nombre_usuario"`
+	EstadoAnterior *string   `json:"estado_anterior"`
+	EstadoNuevo    *string   `json:"estado_nuevo"`
+	Comentario     *string   `json:"comentario"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+type NotificationRepository interface {
+	Create(notifications []domain.UserNotification) error
+	List(userID uint, filter NotificationFilter) ([]domain.UserNotification, error)
+	CountUnread(userID uint) (int64, error)
+	// MarkRead reports false when the notification isn't the user's or was already read
+	MarkRead(userID, id uint) (bool, error)
+	// MarkAllRead marks the user's notifications up to upToID as read; 0 marks every one
+	MarkAllRead(userID, upToID uint) (int64, error)
+
+	// ListChanges returns the unseen history entries after since on the
+	// user's orders, made by someone else, newest first
+	ListChanges(user *domain.User, since time.Time, limit int) ([]OrderChange, error)
+	CountChanges(user *domain.User, since time.Time) (int64, error)
+	// MarkChangesSeen records the entries as seen; with upToID every unseen
+	// change up to it is marked too
+	MarkChangesSeen(user *domain.User, since time.Time, ids []uint, upToID uint) (int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Create(notifications []domain.UserNotification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return r.db.Omit("User", "Orden").Create(&notifications).Error
+}
+
+func (r *notificationRepository) List(userID uint, filter NotificationFilter) ([]domain.UserNotification, error) {
+	var notifications []domain.UserNotification
+	query := r.db.Where("user_id = ? AND canal = ?", userID, domain.CanalApp)
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+	if filter.UnreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&notifications).Error
+	return notifications, err
+}
+
+func (r *notificationRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.UserNotification{}).
+		Where("user_id = ? AND canal = ? AND is_read = ?", userID, domain.CanalApp, false).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepository) MarkRead(userID, id uint) (bool, error) {
+	result := r.db.Model(&domain.UserNotification{}).
+		Where("id = ? AND user_id = ? AND canal = ? AND is_read = ?", id, userID, domain.CanalApp, false).
+		Update("is_read", true)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *notificationRepository) MarkAllRead(userID, upToID uint) (int64, error) {
+	query := r.db.Model(&domain.UserNotification{}).Where("user_id = ? AND canal = ? AND is_read = ?", userID, domain.CanalApp, false)
+	if upToID > 0 {
+		query = query.Where("id <= ?", upToID)
+	}
+	result := query.Update("is_read", true)
+	return result.RowsAffected, result.Error
+}
+
+// unseenChanges selects the history entries the user hasn't seen on the orders
+// they created, are assigned to, are working on or commented on
+func (r *notificationRepository) unseenChanges(user *domain.User, since time.Time) *gorm.DB {
+	return r.db.Table("historial_movimientos AS h").
+		Joins("JOIN ordenes_trabajo o ON o.id = h.id_orden").
+		Where("h.timestamp > ? AND h.id_usuario <> ?", since, user.ID).
+		Where(`o.id_usuario_creador = ? OR o.usuario_trabajando_id = ? OR o.operario_asignado = ?
+			OR EXISTS (SELECT 1 FROM comentarios_orden c WHERE c.id_orden = o.id AND c.id_usuario = ?)`,
+			user.ID, user.ID, user.Nombre, user.ID).
+		Where("NOT EXISTS (SELECT 1 FROM notificaciones_vistas v WHERE v.id_historial = h.id AND v.id_usuario = ?)", user.ID)
+}
+
+func (r *notificationRepository) ListChanges(user *domain.User, since time.Time, limit int) ([]OrderChange, error) {
+	var changes []OrderChange
+	err := r.unseenChanges(user, since).
+		Select(`h.id AS id_historial, h.id_orden, o.numero_op, o.cliente, h.nombre_usuario,
+			h.estado_anterior, h.estado_nuevo, h.comentario, h.timestamp`).
+		Order("h.id DESC").
+		Limit(limit).
+		Scan(&changes).Error
+	return changes, err
+}
+
+func (r *notificationRepository) CountChanges(user *domain.User, since time.Time) (int64, error) {
+	var count int64
+	err := r.unseenChanges(user, since).Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepository) MarkChangesSeen(user *domain.User, since time.Time, ids []uint, upToID uint) (int64, error) {
+	query := r.unseenChanges(user, since)
+	switch {
+	case upToID > 0 && len(ids) > 0:
+		query = query.Where("h.id <= ? OR h.id IN ?", upToID, ids)
+	case upToID > 0:
+		query = query.Where("h.id <= ?", upToID)
+	case len(ids) > 0:
+		query = query.Where("h.id IN ?", ids)
+	default:
+		return 0, nil
+	}
+
+	result := r.db.Exec(
+		"INSERT INTO notificaciones_vistas (id_usuario, id_historial, timestamp) ?",
+		query.Select("CAST(? AS bigint), h.id, CURRENT_TIMESTAMP", user.ID),
+	)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNumeroOPTaken is returned when a number is already used by an order or reserved
+var ErrNumeroOPTaken = errors.New("numero_op is already in use")
+
+type OrderNumberRepository interface {
+	// NextValue increments and returns the sequence for a scope. The sequence
+	// row stays locked until the surrounding transaction ends, so concurrent
+	// allocations wait for each other and a rollback returns the value.
+	NextValue(scope string) (int, error)
+	// IsTaken reports whether a number is used by an order or reserved
+	IsTaken(numeroOP string) (bool, error)
+	Reserve(numeros []string, motivo *string) (int64, error)
+}
+
+type orderNumberRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderNumberRepository(db *gorm.DB) OrderNumberRepository {
+	return &orderNumberRepository{db: db}
+}
+
+func (r *orderNumberRepository) NextValue(scope string) (int, error) {
+	var value int
+	err := r.db.Raw(`
+		INSERT INTO secuencias_numero_op (scope, last_value, updated_at)
+		VALUES (?, 1, NOW())
+		ON CONFLICT (scope) DO UPDATE
+		SET last_value = secuencias_numero_op.last_value + 1, updated_at = NOW()
+		RETURNING last_value`, scope).Scan(&value).Error
+	return value, err
+}
+
+func (r *orderNumberRepository) IsTaken(numeroOP string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.Order{}).Where("numero_op = ?", numeroOP).Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = r.db.Model(&domain.ReservedOrderNumber{}).Where("numero_op = ?", numeroOP).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *orderNumberRepository) Reserve(numeros []string, motivo *string) (int64, error) {
+	if len(numeros) == 0 {
+		return 0, nil
+	}
+
+	reserved := make([]domain.ReservedOrderNumber, len(numeros))
+	for i, numero := range numeros {
+		reserved[i] = domain.ReservedOrderNumber{NumeroOP: numero, Motivo: motivo}
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reserved)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderFilter narrows the list of work orders returned by OrderRepository.List
+type OrderFilter struct {
+	Estado           string
+	Prioridad        string
+	Sector           string
+	Cliente          string
+	OperarioAsignado string
+	Search           string
+	IDSectorActual   *uint
+}
+
+type OrderRepository interface {
+	Create(order *domain.Order) error
+	GetByID(id uint) (*domain.Order, error)
+	List(filter OrderFilter) ([]domain.Order, error)
+	Update(order *domain.Order) error
+	Delete(id uint) error
+
+	// GetByIDForUpdate loads the bare order row and locks it until the
+	// surrounding transaction ends
+	GetByIDForUpdate(id uint) (*domain.Order, error)
+	GetEstado(id uint) (string, error)
+	GetNumeroOP(id uint) (string, error)
+	UpdateEstado(id uint, estado string) error
+	SetSectorActual(id uint, sectorID *uint) error
+
+	// LockColumns serializes position changes in the given Estado columns
+	// until the surrounding transaction ends
+	LockColumns(estados ...string) error
+	GetColumn(estado string) ([]uint, error)
+	SetPositions(ids []uint) error
+	ListBoard() ([]domain.Order, error)
+	// ListOpen returns the orders outside the given states with their sector route
+	ListOpen(closedEstados []string) ([]domain.Order, error)
+
+	// Claim marks the order as being worked on by the user. Unless force is set
+	// it only succeeds when the order is unclaimed, already claimed by the same
+	// user, or the existing claim started before staleBefore.
+	Claim(id, userID uint, nombre string, now, staleBefore time.Time, force bool) (bool, error)
+	// Release clears the claim. Unless force is set it only succeeds for the
+	// user holding the claim or when the claim is stale.
+	Release(id, userID uint, staleBefore time.Time, force bool) (bool, error)
+	// ReleaseStale clears every claim started before staleBefore and returns
+	// the orders as they were before the release
+	ReleaseStale(staleBefore time.Time) ([]domain.Order, error)
+
+	CreateHistory(entry *domain.MovementHistory) error
+	GetLastHistory(orderID uint) (*domain.MovementHistory, error)
+	GetHistory(orderID uint) ([]domain.MovementHistory, error)
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{db: db}
+}
+
+func (r *orderRepository) Create(order *domain.Order) error {
+	return r.numeroOPConflict(r.db.Create(order).Error)
+}
+
+// numeroOPConflict turns a violation of the unique index on numero_op, which
+// concurrent requests for one number can get to, into ErrNumeroOPTaken
+func (r *orderRepository) numeroOPConflict(err error) error {
+	if translator, ok := r.db.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return ErrNumeroOPTaken
+	}
+	return err
+}
+
+func (r *orderRepository) GetByID(id uint) (*domain.Order, error) {
+	var order domain.Order
+	err := r.db.
+		Preload("UsuarioCreador").
+		Preload("SectorActual").
+		Preload("Materiales").
+		Preload("Materiales.Material").
+		Preload("Sectores", func(db *gorm.DB) *gorm.DB {
+			return db.Order("paso ASC, id ASC")
+		}).
+		Preload("Sectores.Sector").
+		Preload("Archivos", func(db *gorm.DB) *gorm.DB {
+			return db.Where(currentAttachmentVersion).Order("fecha_subida ASC, id ASC")
+		}).
+		Preload("Historial", func(db *gorm.DB) *gorm.DB {
+			return db.Order("timestamp ASC, id ASC")
+		}).
+		Preload("Tareas", func(db *gorm.DB) *gorm.DB {
+			return db.Order("posicion ASC, id ASC")
+		}).
+		Preload("Comentarios", func(db *gorm.DB) *gorm.DB {
+			return db.Order("timestamp ASC, id ASC")
+		}).
+		Preload("Comentarios.Usuario").
+		Preload("Enlaces").
+		First(&order, id).Error
+	if err != nil {
+		return nil, err
+	}
+	progress, err := checklistProgress(r.db, []uint{order.ID})
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := progress[order.ID]; ok {
+		order.Checklist = &p
+	}
+	return &order, nil
+}
+
+func (r *orderRepository) List(filter OrderFilter) ([]domain.Order, error) {
+	var orders []domain.Order
+	query := r.db.Model(&domain.Order{})
+
+	if filter.Estado != "" {
+		query = query.Where("estado = ?", filter.Estado)
+	}
+	if filter.Prioridad != "" {
+		query = query.Where("prioridad = ?", filter.Prioridad)
+	}
+	if filter.Sector != "" {
+		query = query.Where("sector = ?", filter.Sector)
+	}
+	if filter.Cliente != "" {
+		query = query.Where("cliente ILIKE ?", "%"+filter.Cliente+"%")
+	}
+	if filter.OperarioAsignado != "" {
+		query = query.Where("operario_asignado = ?", filter.OperarioAsignado)
+	}
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where("numero_op ILIKE ? OR cliente ILIKE ? OR descripcion ILIKE ?", like, like, like)
+	}
+	if filter.IDSectorActual != nil {
+		query = query.Where("id_sector_actual = ?", *filter.IDSectorActual)
+	}
+
+	err := query.
+		Preload("SectorActual").
+		Preload("Materiales").
+		Preload("Materiales.Material").
+		Preload("Sectores", func(db *gorm.DB) *gorm.DB {
+			return db.Order("paso ASC, id ASC")
+		}).
+		Preload("Sectores.Sector").
+		Order("fecha_entrega ASC, id ASC").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, r.attachChecklist(orders)
+}
+
+func (r *orderRepository) Update(order *domain.Order) error {
+	// Omit associations so a preloaded order doesn't rewrite its children, and
+	// the columns that only change through their own operations (moves,
+	// claims, routing) so a stale copy can't undo them
+	err := r.db.Omit(
+		"UsuarioCreador", "SectorActual", "Materiales", "Sectores", "Archivos", "Historial", "Tareas", "Comentarios", "Enlaces",
+		"Estado", "Posicion", "UsuarioTrabajandoID", "UsuarioTrabajandoNombre", "TimestampInicioTrabajo", "IDSectorActual",
+	).Save(order).Error
+	return r.numeroOPConflict(err)
+}
+
+func (r *orderRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Seen marks point at the history entries, so they go first
+		err := tx.Where("id_historial IN (?)", tx.Model(&domain.MovementHistory{}).Select("id").Where("id_orden = ?", id)).
+			Delete(&domain.NotificationViewed{}).Error
+		if err != nil {
+			return err
+		}
+
+		children := []interface{}{
+			&domain.OrderMaterial{},
+			&domain.OrderSector{},
+			&domain.Attachment{},
+			&domain.MovementHistory{},
+			&domain.OrderTask{},
+			&domain.OrderComment{},
+			&domain.OrderLink{},
+			&domain.AlertSent{},
+		}
+		for _, child := range children {
+			if err := tx.Where("id_orden = ?", id).Delete(child).Error; err != nil {
+				return err
+			}
+		}
+		// Notifications outlive the order they point to
+		if err := tx.Model(&domain.UserNotification{}).Where("orden_id = ?", id).Update("orden_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Order{}, id).Error
+	})
+}
+
+func (r *orderRepository) GetByIDForUpdate(id uint) (*domain.Order, error) {
+	var order domain.Order
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *orderRepository) GetEstado(id uint) (string, error) {
+	var order domain.Order
+	err := r.db.Select("id", "estado").First(&order, id).Error
+	return order.Estado, err
+}
+
+func (r *orderRepository) GetNumeroOP(id uint) (string, error) {
+	var order domain.Order
+	err := r.db.Select("id", "numero_op").First(&order, id).Error
+	return order.NumeroOP, err
+}
+
+func (r *orderRepository) UpdateEstado(id uint, estado string) error {
+	return r.db.Model(&domain.Order{}).Where("id = ?", id).Update("estado", estado).Error
+}
+
+func (r *orderRepository) SetSectorActual(id uint, sectorID *uint) error {
+	return r.db.Model(&domain.Order{}).Where("id = ?", id).Update("id_sector_actual", sectorID).Error
+}
+
+func (r *orderRepository) LockColumns(estados ...string) error {
+	// Take the locks in a stable order so two moves between the same
+	// columns can't deadlock each other
+	keys := make([]string, 0, len(estados))
+	seen := make(map[string]bool)
+	for _, estado := range estados {
+		if !seen[estado] {
+			seen[estado] = true
+			keys = append(keys, estado)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, estado := range keys {
+		if err := r.db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "ordenes_trabajo:"+estado).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetColumn returns the IDs of the orders in an Estado column, by position
+func (r *orderRepository) GetColumn(estado string) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&domain.Order{}).
+		Where("estado = ?", estado).
+		Order("posicion ASC, id ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// SetPositions stores each order's index in ids as its position
+func (r *orderRepository) SetPositions(ids []uint) error {
+	for i, id := range ids {
+		err := r.db.Model(&domain.Order{}).
+			Where("id = ? AND posicion <> ?", id, i).
+			Update("posicion", i).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *orderRepository) ListBoard() ([]domain.Order, error) {
+	var orders []domain.Order
+	err := r.db.
+		Preload("SectorActual").
+		Preload("Materiales").
+		Preload("Materiales.Material").
+		Order("posicion ASC, id ASC").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, r.attachChecklist(orders)
+}
+
+// attachChecklist fills in the checklist progress shown on order cards
+func (r *orderRepository) attachChecklist(orders []domain.Order) error {
+	ids := make([]uint, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+	progress, err := checklistProgress(r.db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		if p, ok := progress[orders[i].ID]; ok {
+			orders[i].Checklist = &p
+		}
+	}
+	return nil
+}
+
+func (r *orderRepository) ListOpen(closedEstados []string) ([]domain.Order, error) {
+	var orders []domain.Order
+	query := r.db.Model(&domain.Order{})
+	if len(closedEstados) > 0 {
+		query = query.Where("estado NOT IN ?", closedEstados)
+	}
+	err := query.
+		Preload("Sectores", func(db *gorm.DB) *gorm.DB {
+			return db.Order("paso ASC, id ASC")
+		}).
+		Order("fecha_entrega ASC, id ASC").
+		Find(&orders).Error
+	return orders, err
+}
+
+func (r *orderRepository) Claim(id, userID uint, nombre string, now, staleBefore time.Time, force bool) (bool, error) {
+	query := r.db.Model(&domain.Order{}).Where("id = ?", id)
+	if !force {
+		query = query.Where(
+			"usuario_trabajando_id IS NULL OR usuario_trabajando_id = ? OR timestamp_inicio_trabajo IS NULL OR timestamp_inicio_trabajo < ?",
+			userID, staleBefore,
+		)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"usuario_trabajando_id":     userID,
+		"usuario_trabajando_nombre": nombre,
+		"timestamp_inicio_trabajo":  now,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *orderRepository) Release(id, userID uint, staleBefore time.Time, force bool) (bool, error) {
+	query := r.db.Model(&domain.Order{}).Where("id = ? AND usuario_trabajando_id IS NOT NULL", id)
+	if !force {
+		query = query.Where("usuario_trabajando_id = ? OR timestamp_inicio_trabajo IS NULL OR timestamp_inicio_trabajo < ?", userID, staleBefore)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"usuario_trabajando_id":     nil,
+		"usuario_trabajando_nombre": nil,
+		"timestamp_inicio_trabajo":  nil,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *orderRepository) ReleaseStale(staleBefore time.Time) ([]domain.Order, error) {
+	var orders []domain.Order
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("usuario_trabajando_id IS NOT NULL AND timestamp_inicio_trabajo < ?", staleBefore).
+			Find(&orders).Error
+		if err != nil || len(orders) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(orders))
+		for i, order := range orders {
+			ids[i] = order.ID
+		}
+		return tx.Model(&domain.Order{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"usuario_trabajando_id":     nil,
+			"usuario_trabajando_nombre": nil,
+			"timestamp_inicio_trabajo":  nil,
+		}).Error
+	})
+	return orders, err
+}
+
+func (r *orderRepository) CreateHistory(entry *domain.MovementHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// GetLastHistory returns the most recent history entry of an order, or nil if it has none
+func (r *orderRepository) GetLastHistory(orderID uint) (*domain.MovementHistory, error) {
+	var entries []domain.MovementHistory
+	err := r.db.Where("id_orden = ?", orderID).Order("timestamp DESC, id DESC").Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (r *orderRepository) GetHistory(orderID uint) ([]domain.MovementHistory, error) {
+	var history []domain.MovementHistory
+	err := r.db.Where("id_orden = ?", orderID).Order("timestamp ASC, id ASC").Find(&history).Error
+	return history, err
+}
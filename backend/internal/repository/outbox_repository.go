@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository interface {
+	// Create adds an event; it belongs in the transaction of the change it describes
+	Create(event *domain.OutboxEvent) error
+	// LeaseDue claims the pending events that are due, pushing their next attempt
+	// to leaseUntil so other dispatchers skip them. Only the oldest pending event
+	// of each aggregate is returned, so an aggregate's events go out in order.
+	LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.OutboxEvent, error)
+	UpdateAttempt(event *domain.OutboxEvent) error
+	// PurgePublished deletes the events published before the given time
+	PurgePublished(before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Create(event *domain.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *outboxRepository) LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.OutboxEvent, error) {
+	var events []domain.OutboxEvent
+	err := r.db.Raw(`UPDATE eventos_outbox SET proximo_intento = ?
+		WHERE id IN (
+			SELECT e.id FROM eventos_outbox e
+			WHERE e.estado = ? AND e.proximo_intento <= ?
+				AND NOT EXISTS (
+					SELECT 1 FROM eventos_outbox p
+					WHERE p.tipo_agregado = e.tipo_agregado AND p.id_agregado = e.id_agregado
+						AND p.estado = ? AND p.id < e.id
+				)
+			ORDER BY e.id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, leaseUntil, domain.OutboxPendiente, now, domain.OutboxPendiente, limit).
+		Scan(&events).Error
+	// RETURNING doesn't keep the order of the subquery
+	slices.SortFunc(events, func(a, b domain.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+	return events, err
+}
+
+func (r *outboxRepository) UpdateAttempt(event *domain.OutboxEvent) error {
+	return r.db.Model(event).
+		Select("estado", "entregado_a", "intentos", "ultimo_error", "proximo_intento", "fecha_publicacion").
+		Updates(event).Error
+}
+
+func (r *outboxRepository) PurgePublished(before time.Time) (int64, error) {
+	result := r.db.Where("estado = ? AND fecha_publicacion < ?", domain.OutboxPublicado, before).
+		Delete(&domain.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *domain.RefreshToken) error
+	GetByHash(hash string) (*domain.RefreshToken, error)
+	// Rotate revokes the token and links it to its replacement. It reports false
+	// when the token had already been revoked, e.g. by a concurrent refresh.
+	Rotate(id, replacedByID uint, now time.Time) (bool, error)
+	RevokeFamily(familyID string, now time.Time) error
+	// RevokeUser revokes every live token of the user and returns the families
+	// that were still active
+	RevokeUser(userID uint, now time.Time) ([]string, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token *domain.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *refreshTokenRepository) GetByHash(hash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) Rotate(id, replacedByID uint, now time.Time) (bool, error) {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": replacedByID,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string, now time.Time) error {
+	return r.db.Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func (r *refreshTokenRepository) RevokeUser(userID uint, now time.Time) ([]string, error) {
+	var families []string
+	err := r.db.Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Distinct().
+		Pluck("family_id", &families).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+	return families, err
+}
+
+func (r *refreshTokenRepository) DeleteExpired(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&domain.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type SectorRepository interface {
+	Create(sector *domain.Sector) error
+	GetByID(id uint) (*domain.Sector, error)
+	GetByNombre(nombre string) (*domain.Sector, error)
+	// List returns the sectors in display order
+	List(includeInactive bool) ([]domain.Sector, error)
+	Update(sector *domain.Sector) error
+	Delete(id uint) error
+	IsUsed(id uint) (bool, error)
+	// SetDisplayOrder stores each sector's index in ids as its display order
+	SetDisplayOrder(ids []uint) error
+
+	// Route of an order through the sectors
+	ListRoute(orderID uint) ([]domain.OrderSector, error)
+	CreateStep(step *domain.OrderSector) error
+	UpdateStep(step *domain.OrderSector) error
+	DeleteStep(id uint) error
+}
+
+type sectorRepository struct {
+	db *gorm.DB
+}
+
+func NewSectorRepository(db *gorm.DB) SectorRepository {
+	return &sectorRepository{db: db}
+}
+
+func (r *sectorRepository) Create(sector *domain.Sector) error {
+	return r.db.Create(sector).Error
+}
+
+func (r *sectorRepository) GetByID(id uint) (*domain.Sector, error) {
+	var sector domain.Sector
+	err := r.db.First(&sector, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sector, nil
+}
+
+func (r *sectorRepository) GetByNombre(nombre string) (*domain.Sector, error) {
+	var sector domain.Sector
+	err := r.db.Where("nombre = ?", nombre).First(&sector).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sector, nil
+}
+
+func (r *sectorRepository) List(includeInactive bool) ([]domain.Sector, error) {
+	var sectors []domain.Sector
+	query := r.db.Model(&domain.Sector{})
+	if !includeInactive {
+		query = query.Where("activo = ?", true)
+	}
+	err := query.Order("orden_visualizacion ASC, id ASC").Find(&sectors).Error
+	return sectors, err
+}
+
+func (r *sectorRepository) Update(sector *domain.Sector) error {
+	return r.db.Omit("Ordenes").Save(sector).Error
+}
+
+func (r *sectorRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.Sector{}, id).Error
+}
+
+func (r *sectorRepository) IsUsed(id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.OrderSector{}).Where("id_sector = ?", id).Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = r.db.Model(&domain.Order{}).Where("id_sector_actual = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *sectorRepository) SetDisplayOrder(ids []uint) error {
+	for i, id := range ids {
+		err := r.db.Model(&domain.Sector{}).Where("id = ?", id).Update("orden_visualizacion", i).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sectorRepository) ListRoute(orderID uint) ([]domain.OrderSector, error) {
+	var steps []domain.OrderSector
+	err := r.db.Preload("Sector").
+		Where("id_orden = ?", orderID).
+		Order("paso ASC, id ASC").
+		Find(&steps).Error
+	return steps, err
+}
+
+func (r *sectorRepository) CreateStep(step *domain.OrderSector) error {
+	return r.db.Omit("Orden", "Sector").Create(step).Error
+}
+
+func (r *sectorRepository) UpdateStep(step *domain.OrderSector) error {
+	return r.db.Omit("Orden", "Sector").Save(step).Error
+}
+
+func (r *sectorRepository) DeleteStep(id uint) error {
+	return r.db.Delete(&domain.OrderSector{}, id).Error
+}
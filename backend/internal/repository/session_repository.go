@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionRepository keeps the IDs of revoked login sessions in Redis for as
+// long as an access token of that session could still be valid
+type SessionRepository interface {
+	Revoke(sessionIDs []string, ttl time.Duration) error
+	IsRevoked(sessionID string) (bool, error)
+}
+
+type sessionRepository struct {
+	rdb *redis.Client
+}
+
+func NewSessionRepository(rdb *redis.Client) SessionRepository {
+	return &sessionRepository{rdb: rdb}
+}
+
+func sessionKey(sessionID string) string {
+	return "session:revoked:" + sessionID
+}
+
+func (r *sessionRepository) Revoke(sessionIDs []string, ttl time.Duration) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	pipe := r.rdb.Pipeline()
+	for _, id := range sessionIDs {
+		pipe.Set(ctx, sessionKey(id), 1, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *sessionRepository) IsRevoked(sessionID string) (bool, error) {
+	n, err := r.rdb.Exists(context.Background(), sessionKey(sessionID)).Result()
+	return n > 0, err
+}
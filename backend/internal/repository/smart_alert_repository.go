@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SmartAlertFilter narrows the list returned by SmartAlertRepository.List
+type SmartAlertFilter struct {
+	TipoAlerta      string
+	IncludeResolved bool
+	// IncludeSnoozed also lists unresolved alerts silenced past Now
+	IncludeSnoozed bool
+	Now            time.Time
+}
+
+// StateFlow counts the orders that entered and left a state
+type StateFlow struct {
+	Estado   string
+	Entradas int
+	Salidas  int
+}
+
+// StateDuration summarizes how long orders stayed in a state before leaving it
+type StateDuration struct {
+	Estado     string
+	Muestras   int
+	MedianaSeg float64
+	P75Seg     float64
+}
+
+type SmartAlertRepository interface {
+	List(filter SmartAlertFilter) ([]domain.SmartAlert, error)
+	GetByID(id uint) (*domain.SmartAlert, error)
+	// ListActive returns every unresolved alert, snoozed or not
+	ListActive() ([]domain.SmartAlert, error)
+	Create(alert *domain.SmartAlert) error
+	// Refresh stores what the rules engine found on an active alert
+	Refresh(alert *domain.SmartAlert) error
+	Resolve(id uint, userID *uint, now time.Time) (bool, error)
+	Snooze(id uint, until *time.Time) (bool, error)
+
+	// LockEvaluation serializes rules engine runs until the transaction ends
+	LockEvaluation() error
+
+	// StateFlow counts the state changes after since, by state
+	StateFlow(since time.Time) ([]StateFlow, error)
+	// StateDurations summarizes the time spent in each state by the orders
+	// that left it in [from, to)
+	StateDurations(from, to time.Time) ([]StateDuration, error)
+}
+
+type smartAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewSmartAlertRepository(db *gorm.DB) SmartAlertRepository {
+	return &smartAlertRepository{db: db}
+}
+
+func (r *smartAlertRepository) List(filter SmartAlertFilter) ([]domain.SmartAlert, error) {
+	var alerts []domain.SmartAlert
+	query := r.db.Model(&domain.SmartAlert{})
+	if filter.TipoAlerta != "" {
+		query = query.Where("tipo_alerta = ?", filter.TipoAlerta)
+	}
+	if !filter.IncludeResolved {
+		query = query.Where("resuelto = ?", false)
+	}
+	if !filter.IncludeSnoozed {
+		query = query.Where("silenciada_hasta IS NULL OR silenciada_hasta <= ?", filter.Now)
+	}
+
+	err := query.
+		Order(`CASE prioridad WHEN 'critica' THEN 0 WHEN 'alta' THEN 1 WHEN 'media' THEN 2 ELSE 3 END`).
+		Order("fecha_creacion DESC, id DESC").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+func (r *smartAlertRepository) GetByID(id uint) (*domain.SmartAlert, error) {
+	var alert domain.SmartAlert
+	if err := r.db.First(&alert, id).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *smartAlertRepository) ListActive() ([]domain.SmartAlert, error) {
+	var alerts []domain.SmartAlert
+	err := r.db.Where("resuelto = ?", false).Order("id ASC").Find(&alerts).Error
+	return alerts, err
+}
+
+func (r *smartAlertRepository) Create(alert *domain.SmartAlert) error {
+	return r.db.Create(alert).Error
+}
+
+func (r *smartAlertRepository) Refresh(alert *domain.SmartAlert) error {
+	return r.db.Model(&domain.SmartAlert{}).Where("id = ?", alert.ID).Updates(map[string]interface{}{
+		"prioridad":           alert.Prioridad,
+		"titulo":              alert.Titulo,
+		"descripcion":         alert.Descripcion,
+		"datos_contexto":      alert.DatosContexto,
+		"fecha_actualizacion": alert.FechaActualizacion,
+	}).Error
+}
+
+func (r *smartAlertRepository) Resolve(id uint, userID *uint, now time.Time) (bool, error) {
+	result := r.db.Model(&domain.SmartAlert{}).
+		Where("id = ? AND resuelto = ?", id, false).
+		Updates(map[string]interface{}{
+			"resuelto":              true,
+			"fecha_resuelto":        now,
+			"id_usuario_resolucion": userID,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *smartAlertRepository) Snooze(id uint, until *time.Time) (bool, error) {
+	result := r.db.Model(&domain.SmartAlert{}).
+		Where("id = ? AND resuelto = ?", id, false).
+		Update("silenciada_hasta", until)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *smartAlertRepository) LockEvaluation() error {
+	return r.db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "smart_alerts").Error
+}
+
+func (r *smartAlertRepository) StateFlow(since time.Time) ([]StateFlow, error) {
+	var flows []StateFlow
+	err := r.db.Raw(`
+		SELECT estado, SUM(entradas) AS entradas, SUM(salidas) AS salidas FROM (
+			SELECT estado_nuevo AS estado, 1 AS entradas, 0 AS salidas
+			FROM historial_movimientos WHERE timestamp >= ? AND estado_nuevo IS NOT NULL
+			UNION ALL
+			SELECT estado_anterior, 0, 1
+			FROM historial_movimientos WHERE timestamp >= ? AND estado_anterior IS NOT NULL
+		) moves
+		GROUP BY estado`, since, since).
+		Scan(&flows).Error
+	return flows, err
+}
+
+func (r *smartAlertRepository) StateDurations(from, to time.Time) ([]StateDuration, error) {
+	var durations []StateDuration
+	err := r.db.Raw(`
+		SELECT estado_anterior AS estado,
+			COUNT(*) AS muestras,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY duracion_estado_anterior_seg) AS mediana_seg,
+			percentile_cont(0.75) WITHIN GROUP (ORDER BY duracion_estado_anterior_seg) AS p75_seg
+		FROM historial_movimientos
+		WHERE timestamp >= ? AND timestamp < ?
+			AND estado_anterior IS NOT NULL AND duracion_estado_anterior_seg IS NOT NULL
+		GROUP BY estado_anterior`, from, to).
+		Scan(&durations).Error
+	return durations, err
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StockRepository interface {
+	// ApplyChange adds the signed changes to a material's stock and reserved
+	// totals in a single statement and returns the material as it is afterwards
+	ApplyChange(materialID uint, cambioStock, cambioReservado float64) (*domain.Material, error)
+	// LockMaterials locks the materials' rows in id order until the
+	// surrounding transaction ends
+	LockMaterials(ids []uint) error
+	CreateMovement(movement *domain.StockMovement) error
+	// ListMovements returns the newest movements of a material first
+	ListMovements(materialID uint, limit int) ([]domain.StockMovement, error)
+	SetLineStock(lineID uint, reservada, consumida float64) error
+}
+
+type stockRepository struct {
+	db *gorm.DB
+}
+
+func NewStockRepository(db *gorm.DB) StockRepository {
+	return &stockRepository{db: db}
+}
+
+func (r *stockRepository) ApplyChange(materialID uint, cambioStock, cambioReservado float64) (*domain.Material, error) {
+	var materials []domain.Material
+	err := r.db.Raw(`
+		UPDATE materiales
+		SET stock_actual = stock_actual + ?, stock_reservado = stock_reservado + ?
+		WHERE id = ?
+		RETURNING *`, cambioStock, cambioReservado, materialID).Scan(&materials).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(materials) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &materials[0], nil
+}
+
+func (r *stockRepository) LockMaterials(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var locked []uint
+	return r.db.Model(&domain.Material{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Pluck("id", &locked).Error
+}
+
+func (r *stockRepository) CreateMovement(movement *domain.StockMovement) error {
+	return r.db.Create(movement).Error
+}
+
+func (r *stockRepository) ListMovements(materialID uint, limit int) ([]domain.StockMovement, error) {
+	var movements []domain.StockMovement
+	err := r.db.Where("id_material = ?", materialID).
+		Order("timestamp DESC, id DESC").
+		Limit(limit).
+		Find(&movements).Error
+	return movements, err
+}
+
+func (r *stockRepository) SetLineStock(lineID uint, reservada, consumida float64) error {
+	return r.db.Model(&domain.OrderMaterial{}).Where("id = ?", lineID).Updates(map[string]interface{}{
+		"cantidad_reservada": reservada,
+		"cantidad_consumida": consumida,
+	}).Error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import "gorm.io/gorm"
+
+// Repositories groups the repositories that take part in a unit of work.
+// Every repository in it shares the same database transaction.
+type Repositories struct {
+	Orders        OrderRepository
+	OrderNumbers  OrderNumberRepository
+	RefreshTokens RefreshTokenRepository
+	Materials     MaterialRepository
+	Stock         StockRepository
+	Sectors       SectorRepository
+	Attachments   AttachmentRepository
+	Checklist     ChecklistRepository
+	SmartAlerts   SmartAlertRepository
+	Boards        BoardRepository
+	Tasks         TaskRepository
+	Outbox        OutboxRepository
+}
+
+// UnitOfWork runs a function against repositories bound to a single transaction.
+// The transaction is committed when fn returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	Do(fn func(repos *Repositories) error) error
+}
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Do(fn func(repos *Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Repositories{
+			Orders:        NewOrderRepository(tx),
+			OrderNumbers:  NewOrderNumberRepository(tx),
+			RefreshTokens: NewRefreshTokenRepository(tx),
+			Materials:     NewMaterialRepository(tx),
+			Stock:         NewStockRepository(tx),
+			Sectors:       NewSectorRepository(tx),
+			Attachments:   NewAttachmentRepository(tx),
+			Checklist:     NewChecklistRepository(tx),
+			SmartAlerts:   NewSmartAlertRepository(tx),
+			Boards:        NewBoardRepository(tx),
+			Tasks:         NewTaskRepository(tx),
+			Outbox:        NewOutboxRepository(tx),
+		})
+	})
+}
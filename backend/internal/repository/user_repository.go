@@ -6,11 +6,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// registeredUser leaves out the users created for anonymous sessions. It is a
+// condition on usuarios rows.
+const registeredUser = "usuarios.rol <> '" + domain.RolAnonimo + "'"
+
 type UserRepository interface {
 	Create(user *domain.User) error
 	GetByID(id uint) (*domain.User, error)
-	GetByEmail(email string) (*domain.User, error)
-	GetByUsername(username string) (*domain.User, error)
+	GetByNombre(nombre string) (*domain.User, error)
+	// List and Count leave out the users of anonymous sessions
+	List() ([]domain.User, error)
+	ListByRol(rol string) ([]domain.User, error)
+	Count() (int64, error)
 	Update(user *domain.User) error
 	Delete(id uint) error
 }
@@ -29,29 +36,38 @@ func (r *userRepository) Create(user *domain.User) error {
 
 func (r *userRepository) GetByID(id uint) (*domain.User, error) {
 	var user domain.User
-	err := r.db.Preload("Boards").First(&user, id).Error
+	err := r.db.First(&user, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
+func (r *userRepository) GetByNombre(nombre string) (*domain.User, error) {
 	var user domain.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("nombre = ?", nombre).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
-	var user domain.User
-	err := r.db.Where("username = ?", username).First(&user).Error
-	if err != nil {
-		return nil, err
-	}
-	return &user, nil
+func (r *userRepository) List() ([]domain.User, error) {
+	var users []domain.User
+	err := r.db.Where(registeredUser).Order("nombre ASC").Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) ListByRol(rol string) ([]domain.User, error) {
+	var users []domain.User
+	err := r.db.Where("rol = ?", rol).Order("nombre ASC").Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.User{}).Where(registeredUser).Count(&count).Error
+	return count, err
 }
 
 func (r *userRepository) Update(user *domain.User) error {
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"task-board/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	List() ([]domain.Webhook, error)
+	ListActive() ([]domain.Webhook, error)
+	GetByID(id uint) (*domain.Webhook, error)
+	Create(webhook *domain.Webhook) error
+	Update(webhook *domain.Webhook) error
+	// Delete removes the webhook and its delivery log
+	Delete(id uint) error
+
+	// RecordSuccess clears the webhook's failure streak
+	RecordSuccess(id uint) error
+	// RecordFailure adds to the failure streak and deactivates the webhook
+	// when it reaches disableAfter; it reports whether that happened now
+	RecordFailure(id uint, disableAfter int, reason string, now time.Time) (bool, error)
+
+	// CreateDeliveries skips events a webhook already has, so a redelivered
+	// event isn't queued twice
+	CreateDeliveries(deliveries []domain.WebhookDelivery) error
+	GetDelivery(webhookID, deliveryID uint) (*domain.WebhookDelivery, error)
+	ListDeliveries(webhookID uint, limit int) ([]domain.WebhookDelivery, error)
+	// LeaseDue claims up to limit pending deliveries of active webhooks due at
+	// now by pushing their next attempt to leaseUntil, so concurrent
+	// dispatchers skip them
+	LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error)
+	// UpdateAttempt saves the outcome and schedule of a delivery
+	UpdateAttempt(delivery *domain.WebhookDelivery) error
+	// ReleaseLease makes leased deliveries that were not attempted due again at
+	// the given time
+	ReleaseLease(ids []uint, at time.Time) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) List() ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	err := r.db.Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) ListActive() ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	err := r.db.Where("activo = ?", true).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) GetByID(id uint) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	if err := r.db.First(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *webhookRepository) Create(webhook *domain.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *webhookRepository) Update(webhook *domain.Webhook) error {
+	return r.db.Model(webhook).
+		Select("url", "descripcion", "eventos", "estados", "activo", "fallos_consecutivos", "fecha_desactivacion", "motivo_desactivacion").
+		Updates(webhook).Error
+}
+
+func (r *webhookRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id_webhook = ?", id).Delete(&domain.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Webhook{}, id).Error
+	})
+}
+
+func (r *webhookRepository) RecordSuccess(id uint) error {
+	return r.db.Model(&domain.Webhook{}).
+		Where("id = ? AND fallos_consecutivos <> 0", id).
+		Update("fallos_consecutivos", 0).Error
+}
+
+func (r *webhookRepository) RecordFailure(id uint, disableAfter int, reason string, now time.Time) (bool, error) {
+	var disabled []bool
+	// The row lock makes concurrent failures count one after the other
+	err := r.db.Raw(`WITH old AS (SELECT id, activo FROM webhooks WHERE id = ? FOR UPDATE)
+		UPDATE webhooks w SET
+			fallos_consecutivos = w.fallos_consecutivos + 1,
+			activo = w.activo AND w.fallos_consecutivos + 1 < ?,
+			fecha_desactivacion = CASE WHEN w.activo AND w.fallos_consecutivos + 1 >= ? THEN ? ELSE w.fecha_desactivacion END,
+			motivo_desactivacion = CASE WHEN w.activo AND w.fallos_consecutivos + 1 >= ? THEN ? ELSE w.motivo_desactivacion END
+		FROM old
+		WHERE w.id = old.id
+		RETURNING old.activo AND NOT w.activo`,
+		id, disableAfter, disableAfter, now, disableAfter, reason).
+		Scan(&disabled).Error
+	return len(disabled) > 0 && disabled[0], err
+}
+
+func (r *webhookRepository) CreateDeliveries(deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+func (r *webhookRepository) GetDelivery(webhookID, deliveryID uint) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	if err := r.db.Where("id_webhook = ?", webhookID).First(&delivery, deliveryID).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) ListDeliveries(webhookID uint, limit int) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	err := r.db.Where("id_webhook = ?", webhookID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *webhookRepository) LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	err := r.db.Raw(`UPDATE envios_webhook SET proximo_intento = ?
+		WHERE id IN (
+			SELECT d.id FROM envios_webhook d
+			JOIN webhooks w ON w.id = d.id_webhook
+			WHERE d.estado = ? AND d.proximo_intento <= ? AND w.activo
+			ORDER BY d.proximo_intento, d.id
+			LIMIT ?
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING *`, leaseUntil, domain.WebhookPendiente, now, limit).
+		Scan(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *webhookRepository) UpdateAttempt(delivery *domain.WebhookDelivery) error {
+	return r.db.Model(delivery).
+		Select("estado", "intentos", "ultimo_codigo", "ultimo_error", "proximo_intento", "fecha_envio").
+		Updates(delivery).Error
+}
+
+func (r *webhookRepository) ReleaseLease(ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&domain.WebhookDelivery{}).
+		Where("id IN ? AND estado = ?", ids, domain.WebhookPendiente).
+		Update("proximo_intento", at).Error
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// alertClosedEstados are the states no alert is sent for
+var alertClosedEstados = []string{domain.EstadoEntregado}
+
+// AlertThresholds holds a duration per order state, with a default for the
+// states not listed. A zero duration turns the alert off for that state.
+type AlertThresholds struct {
+	def     time.Duration
+	byState map[string]time.Duration
+}
+
+// NewAlertThresholds parses a default such as "3d" and per-state overrides
+// such as "En Espera=7d,Mostrador=off". Durations take Go units plus "d"
+// for days. Every state must exist in the workflow.
+func NewAlertThresholds(def, spec string, workflow *Workflow) (*AlertThresholds, error) {
+	defDuration, err := parseAlertDuration(def)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds := &AlertThresholds{def: defDuration, byState: map[string]time.Duration{}}
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		estado, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid alert threshold entry %q", part)
+		}
+		estado = strings.TrimSpace(estado)
+		if !workflow.HasState(estado) {
+			return nil, fmt.Errorf("unknown workflow state %q", estado)
+		}
+		d, err := parseAlertDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert threshold for %s: %w", estado, err)
+		}
+		thresholds.byState[estado] = d
+	}
+	return thresholds, nil
+}
+
+// For returns the threshold of a state and whether the alert applies to it
+func (t *AlertThresholds) For(estado string) (time.Duration, bool) {
+	d, ok := t.byState[estado]
+	if !ok {
+		d = t.def
+	}
+	return d, d > 0
+}
+
+// parseAlertDuration reads "off", "0", Go durations and whole or fractional days ("3d", "1.5d")
+func parseAlertDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "off" || value == "0" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+type AlertService interface {
+	// Scan checks every open order and sends the stalled and deadline alerts
+	// that are due and weren't sent before. It returns how many were sent.
+	Scan(now time.Time) (int, error)
+}
+
+type alertService struct {
+	alertRepo repository.AlertRepository
+	notifier  Notifier
+	stalled   *AlertThresholds
+	deadline  *AlertThresholds
+	location  *time.Location
+}
+
+// NewAlertService takes how long an order may stay in a state before it is
+// stalled, how long before its delivery the deadline warning goes out, and the
+// shop's time zone, in which delivery dates and times are written
+func NewAlertService(alertRepo repository.AlertRepository, notifier Notifier, stalled, deadline *AlertThresholds, location *time.Location) AlertService {
+	return &alertService{
+		alertRepo: alertRepo,
+		notifier:  notifier,
+		stalled:   stalled,
+		deadline:  deadline,
+		location:  location,
+	}
+}
+
+// pendingAlert is an alert that is due, before checking whether it was sent
+type pendingAlert struct {
+	tipo       string
+	referencia string
+	roles      []string
+	notice     domain.UserNotification
+}
+
+func (s *alertService) Scan(now time.Time) (int, error) {
+	candidates, err := s.alertRepo.ListCandidates(alertClosedEstados)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, candidate := range candidates {
+		for _, alert := range s.dueAlerts(candidate, now) {
+			referencia := alert.referencia
+			isNew, err := s.alertRepo.Record(&domain.AlertSent{
+				IDOrden:    candidate.ID,
+				TipoAlerta: alert.tipo,
+				Referencia: &referencia,
+				Timestamp:  now,
+			})
+			if err != nil {
+				return sent, err
+			}
+			if !isNew {
+				continue
+			}
+
+			// Recorded first, so a failed delivery is logged rather than repeated
+			orderID := candidate.ID
+			alert.notice.OrdenID = &orderID
+			for _, rol := range alert.roles {
+				if err := s.notifier.NotifyRole(rol, alert.notice); err != nil {
+					log.Printf("Failed to send %s alert for order %d: %v", alert.tipo, candidate.ID, err)
+				}
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// dueAlerts returns the alerts the order qualifies for right now. The
+// referencia identifies the episode: the same stall or the same deadline
+// never alerts twice, but a new stall or a changed deadline does.
+func (s *alertService) dueAlerts(candidate repository.AlertCandidate, now time.Time) []pendingAlert {
+	var alerts []pendingAlert
+
+	if threshold, ok := s.stalled.For(candidate.Estado); ok {
+		if stalledFor := now.Sub(candidate.EnEstadoDesde); stalledFor >= threshold {
+			description := fmt.Sprintf("%s. Sin movimientos desde el %s.",
+				candidate.Cliente, candidate.EnEstadoDesde.In(s.location).Format("02/01 15:04"))
+			alerts = append(alerts, pendingAlert{
+				tipo:       domain.AlertaEstancada,
+				referencia: fmt.Sprintf("%s@%d", candidate.Estado, candidate.EnEstadoDesde.Unix()),
+				roles:      []string{domain.RolAdministracion},
+				notice: domain.UserNotification{
+					Title:       fmt.Sprintf("%s lleva %s en %s", candidate.NumeroOP, formatDays(stalledFor), candidate.Estado),
+					Description: &description,
+					Type:        domain.NotificacionEstancada,
+				},
+			})
+		}
+	}
+
+	if warning, ok := s.deadline.For(candidate.Estado); ok {
+		due, label := deliveryDue(candidate, s.location)
+		stage, title := "", ""
+		switch {
+		case !now.Before(due):
+			stage = "vencida"
+			title = fmt.Sprintf("%s está vencida desde el %s", candidate.NumeroOP, label)
+		case due.Sub(now) <= warning:
+			stage = "proxima"
+			title = fmt.Sprintf("%s vence el %s", candidate.NumeroOP, label)
+		}
+		if stage != "" {
+			description := fmt.Sprintf("%s. Estado: %s.", candidate.Cliente, candidate.Estado)
+			alerts = append(alerts, pendingAlert{
+				tipo:       domain.AlertaPlazo,
+				referencia: fmt.Sprintf("%s@%d", stage, due.Unix()),
+				roles:      []string{domain.RolAdministracion, domain.RolMostrador},
+				notice: domain.UserNotification{
+					Title:       title,
+					Description: &description,
+					Type:        domain.NotificacionPlazo,
+				},
+			})
+		}
+	}
+
+	return alerts
+}
+
+// deliveryDue is the delivery moment in the shop's time zone, and how to show
+// it: FechaEntrega at HoraEstimadaEntrega, or the end of that day when no time
+// was promised
+func deliveryDue(candidate repository.AlertCandidate, location *time.Location) (time.Time, string) {
+	y, m, d := candidate.FechaEntrega.UTC().Date()
+	if candidate.HoraEstimadaEntrega != nil {
+		for _, layout := range []string{"15:04:05", "15:04"} {
+			if t, err := time.Parse(layout, *candidate.HoraEstimadaEntrega); err == nil {
+				due := time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, location)
+				return due, due.Format("02/01 15:04")
+			}
+		}
+	}
+	return time.Date(y, m, d+1, 0, 0, 0, 0, location), time.Date(y, m, d, 0, 0, 0, 0, location).Format("02/01")
+}
+
+// formatDays describes a duration in whole days, or hours when under a day
+func formatDays(d time.Duration) string {
+	if days := int(d.Hours() / 24); days >= 1 {
+		if days == 1 {
+			return "1 día"
+		}
+		return fmt.Sprintf("%d días", days)
+	}
+	hours := int(d.Hours())
+	if hours == 1 {
+		return "1 hora"
+	}
+	return fmt.Sprintf("%d horas", hours)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/pkg/imaging"
+)
+
+var (
+	// ErrPreviewPending is returned while an attachment's previews are being generated
+	ErrPreviewPending = errors.New("preview is not ready yet")
+	// ErrPreviewUnavailable is returned for attachments that have no preview
+	ErrPreviewUnavailable = errors.New("attachment has no preview")
+)
+
+// Preview sizes, by the longest side in pixels
+const (
+	PreviewThumb = "thumb"
+	PreviewWeb   = "web"
+
+	thumbSide = 320
+	webSide   = 1600
+)
+
+const (
+	// previewQueueSize is how many uploads wait for a worker before the
+	// periodic sweep has to pick them up
+	previewQueueSize = 64
+	// previewSweepBatch bounds the pending attachments queued by one sweep
+	previewSweepBatch = 50
+	// pdfHeadLen is how much of a PDF is searched for the first page size
+	pdfHeadLen = 64 << 10
+)
+
+// previewKey is where a preview is stored, next to the original
+func previewKey(key, size string) string {
+	return key + "." + size + ".jpg"
+}
+
+// storedKeys lists the original and every preview of an attachment
+func storedKeys(attachment domain.Attachment) []string {
+	return []string{
+		attachment.NombreArchivo,
+		previewKey(attachment.NombreArchivo, PreviewThumb),
+		previewKey(attachment.NombreArchivo, PreviewWeb),
+	}
+}
+
+func (s *attachmentService) GetPreview(orderID, attachmentID, userID uint, size string) (*domain.Attachment, io.ReadCloser, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, nil, err
+	}
+	if size != PreviewThumb && size != PreviewWeb {
+		return nil, nil, errors.New("size must be thumb or web")
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(orderID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch attachment.EstadoPreview {
+	case domain.PreviewListo:
+	case domain.PreviewPendiente:
+		return attachment, nil, ErrPreviewPending
+	default:
+		return attachment, nil, ErrPreviewUnavailable
+	}
+
+	contents, err := s.files.Get(previewKey(attachment.NombreArchivo, size))
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, contents, nil
+}
+
+// queuePreview hands the attachment to a worker without blocking. When every
+// worker is busy it stays pending for the next sweep.
+func (s *attachmentService) queuePreview(attachment domain.Attachment) {
+	s.queuedMu.Lock()
+	defer s.queuedMu.Unlock()
+	if s.queued[attachment.ID] {
+		return
+	}
+	select {
+	case s.previews <- attachment:
+		s.queued[attachment.ID] = true
+	default:
+	}
+}
+
+func (s *attachmentService) QueuePendingPreviews() (int, error) {
+	attachments, err := s.attachmentRepo.ListPendingPreviews(previewSweepBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, attachment := range attachments {
+		s.queuePreview(attachment)
+	}
+	return len(attachments), nil
+}
+
+func (s *attachmentService) RunPreviewWorker() {
+	for attachment := range s.previews {
+		estado := s.generatePreviews(attachment)
+
+		exists, err := s.attachmentRepo.SetPreviewState(attachment.ID, estado)
+		if err != nil {
+			log.Printf("Failed to store preview state of attachment %d: %v", attachment.ID, err)
+		} else if !exists {
+			// Deleted while its previews were being made
+			deleteStoredFiles(s.files, []domain.Attachment{attachment})
+		}
+
+		s.queuedMu.Lock()
+		delete(s.queued, attachment.ID)
+		s.queuedMu.Unlock()
+	}
+}
+
+// generatePreviews stores the thumbnail and web-sized preview of an attachment
+// and returns its new preview state. Images are scaled down; PDFs get a
+// placeholder shaped like their first page.
+func (s *attachmentService) generatePreviews(attachment domain.Attachment) string {
+	contents, err := s.files.Get(attachment.NombreArchivo)
+	if err != nil {
+		log.Printf("Failed to open attachment %d for previews: %v", attachment.ID, err)
+		return domain.PreviewError
+	}
+	defer contents.Close()
+
+	// Decoding needs to seek, so the original is spooled locally
+	spool, err := os.CreateTemp("", "preview-*")
+	if err != nil {
+		log.Printf("Failed to spool attachment %d for previews: %v", attachment.ID, err)
+		return domain.PreviewError
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	if _, err := io.Copy(spool, io.LimitReader(contents, s.maxSize)); err != nil {
+		log.Printf("Failed to read attachment %d for previews: %v", attachment.ID, err)
+		return domain.PreviewError
+	}
+
+	head := make([]byte, pdfHeadLen)
+	n, err := spool.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		log.Printf("Failed to read attachment %d for previews: %v", attachment.ID, err)
+		return domain.PreviewError
+	}
+	head = head[:n]
+
+	var web, thumb image.Image
+	switch http.DetectContentType(head) {
+	case "image/jpeg", "image/png", "image/gif":
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return domain.PreviewError
+		}
+		img, err := imaging.Decode(spool)
+		if errors.Is(err, imaging.ErrTooLarge) {
+			return domain.PreviewNoDisponible
+		}
+		if err != nil {
+			log.Printf("Failed to decode attachment %d: %v", attachment.ID, err)
+			return domain.PreviewError
+		}
+		scaled := imaging.Fit(img, webSide)
+		web, thumb = scaled, imaging.Fit(scaled, thumbSide)
+	case "application/pdf":
+		ratio := imaging.PDFPageRatio(head)
+		web, thumb = imaging.PDFPlaceholder(ratio, webSide), imaging.PDFPlaceholder(ratio, thumbSide)
+	default:
+		return domain.PreviewNoDisponible
+	}
+
+	for size, img := range map[string]image.Image{PreviewWeb: web, PreviewThumb: thumb} {
+		data, err := imaging.EncodeJPEG(img)
+		if err == nil {
+			err = s.files.Put(previewKey(attachment.NombreArchivo, size), bytes.NewReader(data), int64(len(data)), "image/jpeg")
+		}
+		if err != nil {
+			log.Printf("Failed to store %s preview of attachment %d: %v", size, attachment.ID, err)
+			return domain.PreviewError
+		}
+	}
+	return domain.PreviewListo
+}
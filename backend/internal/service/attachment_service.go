@@ -0,0 +1,454 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"task-board/pkg/storage"
+	"time"
+)
+
+var (
+	// ErrAttachmentEmpty is returned when the uploaded file has no contents
+	ErrAttachmentEmpty = errors.New("file is empty")
+	// ErrAttachmentTooLarge is returned when the upload exceeds the size limit
+	ErrAttachmentTooLarge = errors.New("file exceeds the maximum upload size")
+	// ErrAttachmentNotAllowed is returned for executables and scripts, whatever their name
+	ErrAttachmentNotAllowed = errors.New("file type is not allowed")
+	// ErrAttachmentTypeMismatch is returned when the contents don't match the file extension
+	ErrAttachmentTypeMismatch = errors.New("file contents do not match its extension")
+)
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+// blockedExtensions can run code on the machine that opens them
+var blockedExtensions = map[string]bool{
+	".exe": true, ".com": true, ".bat": true, ".cmd": true, ".msi": true, ".scr": true,
+	".dll": true, ".ps1": true, ".vbs": true, ".js": true, ".jar": true, ".sh": true,
+	".apk": true, ".app": true, ".lnk": true, ".reg": true,
+}
+
+// executableMagic are the leading bytes of native executables and scripts
+var executableMagic = [][]byte{
+	[]byte("MZ"),             // Windows PE / DOS
+	[]byte("\x7fELF"),        // Linux
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+	{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32-bit, little endian
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little endian
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal / Java class
+	[]byte("#!"),             // scripts with an interpreter line
+}
+
+// sniffedExtensions are the extensions whose contents must sniff as the given type
+var sniffedExtensions = map[string]string{
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+}
+
+type AttachmentService interface {
+	// GetAttachments lists the current version of every file (the approved
+	// one, else the latest), or every version with allVersions
+	GetAttachments(orderID, userID uint, allVersions bool) ([]domain.Attachment, error)
+	// UploadAttachment stores content under a generated key. The content is
+	// spooled to a temporary file to be measured, sniffed and hashed first.
+	UploadAttachment(orderID, userID uint, filename string, content io.Reader) (*domain.Attachment, error)
+	// UploadVersion adds a new version to the file the attachment belongs to
+	UploadVersion(orderID, attachmentID, userID uint, filename string, content io.Reader) (*domain.Attachment, error)
+	// GetVersions lists every version of the attachment's file, newest first
+	GetVersions(orderID, attachmentID, userID uint) ([]domain.Attachment, error)
+	// ApproveVersion records the client's approval of this version of the file
+	ApproveVersion(orderID, attachmentID, userID uint) (*domain.Attachment, error)
+	// WithdrawApproval leaves the file without an approved version
+	WithdrawApproval(orderID, attachmentID, userID uint) error
+	// OpenAttachment returns the attachment and its contents; the caller closes them
+	OpenAttachment(orderID, attachmentID, userID uint) (*domain.Attachment, io.ReadCloser, error)
+	// DeleteAttachment is allowed to the uploader and to users who can edit orders
+	DeleteAttachment(orderID, attachmentID, userID uint) error
+
+	// GetPreview opens the thumbnail or web-sized preview of an image or PDF
+	GetPreview(orderID, attachmentID, userID uint, size string) (*domain.Attachment, io.ReadCloser, error)
+	// RunPreviewWorker generates queued previews until the process exits
+	RunPreviewWorker()
+	// QueuePendingPreviews hands attachments still waiting for previews to the workers
+	QueuePendingPreviews() (int, error)
+}
+
+type attachmentService struct {
+	attachmentRepo repository.AttachmentRepository
+	orderRepo      repository.OrderRepository
+	userRepo       repository.UserRepository
+	uow            repository.UnitOfWork
+	files          storage.Storage
+	maxSize        int64
+
+	previews chan domain.Attachment
+	queuedMu sync.Mutex
+	queued   map[uint]bool
+}
+
+func NewAttachmentService(attachmentRepo repository.AttachmentRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, uow repository.UnitOfWork, files storage.Storage, maxSize int64) AttachmentService {
+	return &attachmentService{
+		attachmentRepo: attachmentRepo,
+		orderRepo:      orderRepo,
+		userRepo:       userRepo,
+		uow:            uow,
+		files:          files,
+		maxSize:        maxSize,
+		previews:       make(chan domain.Attachment, previewQueueSize),
+		queued:         make(map[uint]bool),
+	}
+}
+
+func (s *attachmentService) GetAttachments(orderID, userID uint, allVersions bool) ([]domain.Attachment, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+	if allVersions {
+		return s.attachmentRepo.ListByOrder(orderID)
+	}
+	return s.attachmentRepo.ListCurrent(orderID)
+}
+
+func (s *attachmentService) UploadAttachment(orderID, userID uint, filename string, content io.Reader) (*domain.Attachment, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderAttach)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.store(orderID, user.ID, filename, content)
+	if err != nil {
+		return nil, err
+	}
+	attachment.Version = 1
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Attachments.Create(attachment); err != nil {
+			return err
+		}
+		// The first version starts the group
+		attachment.IDGrupo = &attachment.ID
+		return repos.Attachments.SetGroup(attachment.ID, attachment.ID)
+	})
+	if err != nil {
+		s.discard(attachment)
+		return nil, err
+	}
+
+	s.queuePreview(*attachment)
+	return attachment, nil
+}
+
+func (s *attachmentService) UploadVersion(orderID, attachmentID, userID uint, filename string, content io.Reader) (*domain.Attachment, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderAttach)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.attachmentRepo.GetByID(orderID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	groupID := previous.GroupID()
+
+	attachment, err := s.store(orderID, user.ID, filename, content)
+	if err != nil {
+		return nil, err
+	}
+	attachment.IDGrupo = &groupID
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Attachments.LockGroup(groupID); err != nil {
+			return err
+		}
+		version, err := repos.Attachments.NextVersion(groupID)
+		if err != nil {
+			return err
+		}
+		attachment.Version = version
+		return repos.Attachments.Create(attachment)
+	})
+	if err != nil {
+		s.discard(attachment)
+		return nil, err
+	}
+
+	s.queuePreview(*attachment)
+	return attachment, nil
+}
+
+func (s *attachmentService) GetVersions(orderID, attachmentID, userID uint) ([]domain.Attachment, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	attachment, err := s.attachmentRepo.GetByID(orderID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.attachmentRepo.ListVersions(attachment.GroupID())
+}
+
+func (s *attachmentService) ApproveVersion(orderID, attachmentID, userID uint) (*domain.Attachment, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderApproveProof); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		attachment, err := repos.Attachments.GetByID(orderID, attachmentID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Attachments.LockGroup(attachment.GroupID()); err != nil {
+			return err
+		}
+		return repos.Attachments.Approve(attachment.ID, attachment.GroupID(), userID, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.attachmentRepo.GetByID(orderID, attachmentID)
+}
+
+func (s *attachmentService) WithdrawApproval(orderID, attachmentID, userID uint) error {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderApproveProof); err != nil {
+		return err
+	}
+
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		attachment, err := repos.Attachments.GetByID(orderID, attachmentID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Attachments.LockGroup(attachment.GroupID()); err != nil {
+			return err
+		}
+		return repos.Attachments.ClearApproval(attachment.GroupID())
+	})
+}
+
+// store checks and saves uploaded content, returning the attachment to record.
+// The caller removes the stored file with discard if recording it fails.
+func (s *attachmentService) store(orderID, userID uint, filename string, content io.Reader) (*domain.Attachment, error) {
+	nombre := cleanFilename(filename)
+	if nombre == "" {
+		return nil, errors.New("file name is required")
+	}
+	ext := strings.ToLower(filepath.Ext(nombre))
+	if blockedExtensions[ext] {
+		return nil, ErrAttachmentNotAllowed
+	}
+
+	spool, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(content, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	if size > s.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := spool.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	tipoMime, err := detectAttachmentType(head[:n], ext)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := attachmentKey(orderID, ext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.files.Put(key, spool, size, tipoMime); err != nil {
+		return nil, err
+	}
+
+	return &domain.Attachment{
+		IDOrden:        orderID,
+		NombreArchivo:  key,
+		NombreOriginal: nombre,
+		TipoMime:       tipoMime,
+		Tamano:         size,
+		SHA256:         hex.EncodeToString(hash.Sum(nil)),
+		IDUsuario:      &userID,
+		EstadoPreview:  domain.PreviewPendiente,
+		FechaSubida:    time.Now(),
+	}, nil
+}
+
+// discard removes the stored file of an upload that couldn't be recorded
+func (s *attachmentService) discard(attachment *domain.Attachment) {
+	if err := s.files.Delete(attachment.NombreArchivo); err != nil {
+		log.Printf("Failed to remove orphaned attachment %s: %v", attachment.NombreArchivo, err)
+	}
+}
+
+func (s *attachmentService) OpenAttachment(orderID, attachmentID, userID uint) (*domain.Attachment, io.ReadCloser, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, nil, err
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(orderID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := s.files.Get(attachment.NombreArchivo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, contents, nil
+}
+
+func (s *attachmentService) DeleteAttachment(orderID, attachmentID, userID uint) error {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderAttach)
+	if err != nil {
+		return err
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(orderID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if attachment.IDUsuario == nil || *attachment.IDUsuario != user.ID {
+		if err := authz.Check(user.Rol, authz.PermOrderEdit); err != nil {
+			return err
+		}
+	}
+
+	if err := s.attachmentRepo.Delete(attachment.ID); err != nil {
+		return err
+	}
+	deleteStoredFiles(s.files, []domain.Attachment{*attachment})
+	return nil
+}
+
+// deleteStoredFiles removes the contents and previews of attachments whose rows
+// are gone. Failures only leave orphaned files behind, so they are logged.
+func deleteStoredFiles(files storage.Storage, attachments []domain.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range storedKeys(attachment) {
+			if err := files.Delete(key); err != nil {
+				log.Printf("Failed to delete stored file %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// detectAttachmentType sniffs the file's type from its first bytes. Executables
+// are refused whatever their name, and files named as a PDF or image must
+// actually be one.
+func detectAttachmentType(head []byte, ext string) (string, error) {
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(head, magic) {
+			return "", ErrAttachmentNotAllowed
+		}
+	}
+
+	sniffed := http.DetectContentType(head)
+	if expected, ok := sniffedExtensions[ext]; ok {
+		if !strings.HasPrefix(sniffed, expected) {
+			return "", ErrAttachmentTypeMismatch
+		}
+		return expected, nil
+	}
+
+	// Generic results say little; the extension is more precise for formats
+	// the sniffer doesn't know (e.g. .ai, .psd, .cdr)
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byExt := mime.TypeByExtension(ext); byExt != "" && !strings.HasPrefix(byExt, "text/html") {
+			return byExt, nil
+		}
+	}
+	return sniffed, nil
+}
+
+// cleanFilename keeps only the base name of an uploaded file, whatever path
+// separator the client used
+func cleanFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name))
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 32 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+// attachmentKey generates an unguessable storage key for a new attachment
+func attachmentKey(orderID uint, ext string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	if !validKeyExtension(ext) {
+		ext = ""
+	}
+	return fmt.Sprintf("orders/%d/%s%s", orderID, hex.EncodeToString(random), ext), nil
+}
+
+// validKeyExtension allows only short alphanumeric extensions into storage keys
+func validKeyExtension(ext string) bool {
+	if len(ext) < 2 || len(ext) > 10 {
+		return false
+	}
+	for _, c := range ext[1:] {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
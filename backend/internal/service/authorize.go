@@ -0,0 +1,19 @@
+package service
+
+import (
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+)
+
+// authorizeUser loads the acting user and checks the permission against their role
+func authorizeUser(users repository.UserRepository, userID uint, perm authz.Permission) (*domain.User, error) {
+	user, err := users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Check(user.Rol, perm); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -6,6 +6,14 @@ import (
 	"task-board/internal/repository"
 )
 
+// BoardEvent is published to the board's owner when it is created, edited or deleted
+type BoardEvent struct {
+	BoardID     uint   `json:"board_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	OwnerID     uint   `json:"owner_id"`
+}
+
 type BoardService interface {
 	CreateBoard(ownerID uint, title, description string) (*domain.Board, error)
 	GetBoards(ownerID uint) ([]domain.Board, error)
@@ -16,11 +24,15 @@ type BoardService interface {
 
 type boardService struct {
 	boardRepo repository.BoardRepository
+	uow       repository.UnitOfWork
+	events    EventWaker
 }
 
-func NewBoardService(boardRepo repository.BoardRepository) BoardService {
+func NewBoardService(boardRepo repository.BoardRepository, uow repository.UnitOfWork, events EventWaker) BoardService {
 	return &boardService{
 		boardRepo: boardRepo,
+		uow:       uow,
+		events:    events,
 	}
 }
 
@@ -31,9 +43,16 @@ func (s *boardService) CreateBoard(ownerID uint, title, description string) (*do
 		OwnerID:     ownerID,
 	}
 
-	if err := s.boardRepo.Create(board); err != nil {
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Boards.Create(board); err != nil {
+			return err
+		}
+		return recordBoardEvent(repos, EventBoardCreated, board)
+	})
+	if err != nil {
 		return nil, err
 	}
+	wake(s.events)
 
 	return board, nil
 }
@@ -65,18 +84,46 @@ func (s *boardService) UpdateBoard(boardID, userID uint, title, description stri
 	board.Title = title
 	board.Description = description
 
-	if err := s.boardRepo.Update(board); err != nil {
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Boards.Update(board); err != nil {
+			return err
+		}
+		return recordBoardEvent(repos, EventBoardUpdated, board)
+	})
+	if err != nil {
 		return nil, err
 	}
+	wake(s.events)
 
 	return board, nil
 }
 
 func (s *boardService) DeleteBoard(boardID, userID uint) error {
-	_, err := s.GetBoard(boardID, userID)
+	board, err := s.GetBoard(boardID, userID)
 	if err != nil {
 		return err
 	}
 
-	return s.boardRepo.Delete(boardID)
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Boards.Delete(boardID); err != nil {
+			return err
+		}
+		return recordBoardEvent(repos, EventBoardDeleted, board)
+	})
+	if err != nil {
+		return err
+	}
+	wake(s.events)
+	return nil
+}
+
+// recordBoardEvent publishes the board change to its owner, in the unit of
+// work that made it
+func recordBoardEvent(repos *repository.Repositories, eventType string, board *domain.Board) error {
+	return recordEventFor(repos, domain.AgregadoTablero, board.ID, board.OwnerID, eventType, BoardEvent{
+		BoardID:     board.ID,
+		Title:       board.Title,
+		Description: board.Description,
+		OwnerID:     board.OwnerID,
+	})
 }
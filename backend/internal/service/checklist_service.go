@@ -0,0 +1,262 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"unicode/utf8"
+)
+
+// maxTaskLength matches the descripcion_tarea column
+const maxTaskLength = 255
+
+// Checklist is an order's tasks, by position, and how far along they are
+type Checklist struct {
+	Tareas   []domain.OrderTask       `json:"tareas"`
+	Progreso domain.ChecklistProgress `json:"progreso"`
+}
+
+// ChecklistChangeEvent is published whenever an order's checklist changes, so
+// boards can refresh the progress on its card
+type ChecklistChangeEvent struct {
+	OrderID   uint                     `json:"order_id"`
+	NumeroOP  string                   `json:"numero_op"`
+	Checklist domain.ChecklistProgress `json:"checklist"`
+	ByUserID  uint                     `json:"by_user_id"`
+}
+
+type ChecklistService interface {
+	GetChecklist(orderID, userID uint) (*Checklist, error)
+	// AddTask appends a task to the Pendiente column
+	AddTask(orderID, userID uint, descripcion string) (*domain.OrderTask, error)
+	UpdateTask(orderID, taskID, userID uint, descripcion string) (*domain.OrderTask, error)
+	// MoveTask places a task at a position of an EstadoKanban column. A
+	// negative position appends it to the end of the column.
+	MoveTask(orderID, taskID, userID uint, estado string, posicion int) (*domain.OrderTask, error)
+	// DeleteTask also needs order:edit, so open tasks can't be deleted to get
+	// past a state that requires a finished checklist
+	DeleteTask(orderID, taskID, userID uint) error
+}
+
+type checklistService struct {
+	checklistRepo repository.ChecklistRepository
+	orderRepo     repository.OrderRepository
+	userRepo      repository.UserRepository
+	uow           repository.UnitOfWork
+	events        EventWaker
+}
+
+func NewChecklistService(checklistRepo repository.ChecklistRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, uow repository.UnitOfWork, events EventWaker) ChecklistService {
+	return &checklistService{
+		checklistRepo: checklistRepo,
+		orderRepo:     orderRepo,
+		userRepo:      userRepo,
+		uow:           uow,
+		events:        events,
+	}
+}
+
+func (s *checklistService) GetChecklist(orderID, userID uint) (*Checklist, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.checklistRepo.ListByOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := s.checklistRepo.Progress(orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &Checklist{Tareas: tasks, Progreso: progress}, nil
+}
+
+func (s *checklistService) AddTask(orderID, userID uint, descripcion string) (*domain.OrderTask, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderChecklist); err != nil {
+		return nil, err
+	}
+	descripcion, err := validateTask(descripcion)
+	if err != nil {
+		return nil, err
+	}
+
+	var task *domain.OrderTask
+	err = s.change(orderID, userID, func(repos *repository.Repositories) error {
+		column, err := repos.Checklist.GetColumn(orderID, domain.TareaPendiente)
+		if err != nil {
+			return err
+		}
+		task = &domain.OrderTask{
+			IDOrden:          orderID,
+			DescripcionTarea: descripcion,
+			EstadoKanban:     domain.TareaPendiente,
+			Posicion:         len(column),
+		}
+		return repos.Checklist.Create(task)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wake(s.events)
+	return task, nil
+}
+
+func (s *checklistService) UpdateTask(orderID, taskID, userID uint, descripcion string) (*domain.OrderTask, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderChecklist); err != nil {
+		return nil, err
+	}
+	descripcion, err := validateTask(descripcion)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.checklistRepo.GetByID(orderID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checklistRepo.UpdateDescripcion(task.ID, descripcion); err != nil {
+		return nil, err
+	}
+	return s.checklistRepo.GetByID(orderID, taskID)
+}
+
+func (s *checklistService) MoveTask(orderID, taskID, userID uint, estado string, posicion int) (*domain.OrderTask, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderChecklist); err != nil {
+		return nil, err
+	}
+	switch estado {
+	case domain.TareaPendiente, domain.TareaEnProceso, domain.TareaFinalizada:
+	default:
+		return nil, fmt.Errorf("estado_kanban must be %s, %s or %s", domain.TareaPendiente, domain.TareaEnProceso, domain.TareaFinalizada)
+	}
+
+	err := s.change(orderID, userID, func(repos *repository.Repositories) error {
+		task, err := repos.Checklist.GetByID(orderID, taskID)
+		if err != nil {
+			return err
+		}
+		source := task.EstadoKanban
+		if source == "" {
+			source = domain.TareaPendiente
+		}
+
+		if source != estado {
+			if err := repos.Checklist.SetEstado(task.ID, estado); err != nil {
+				return err
+			}
+			sourceIDs, err := repos.Checklist.GetColumn(orderID, source)
+			if err != nil {
+				return err
+			}
+			if err := repos.Checklist.SetPositions(sourceIDs); err != nil {
+				return err
+			}
+		}
+
+		targetIDs, err := repos.Checklist.GetColumn(orderID, estado)
+		if err != nil {
+			return err
+		}
+		return repos.Checklist.SetPositions(insertAt(targetIDs, task.ID, posicion))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wake(s.events)
+	return s.checklistRepo.GetByID(orderID, taskID)
+}
+
+func (s *checklistService) DeleteTask(orderID, taskID, userID uint) error {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderChecklist)
+	if err != nil {
+		return err
+	}
+	if err := authz.Check(user.Rol, authz.PermOrderEdit); err != nil {
+		return err
+	}
+
+	err = s.change(orderID, userID, func(repos *repository.Repositories) error {
+		task, err := repos.Checklist.GetByID(orderID, taskID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Checklist.Delete(task.ID); err != nil {
+			return err
+		}
+		estado := task.EstadoKanban
+		if estado == "" {
+			estado = domain.TareaPendiente
+		}
+		ids, err := repos.Checklist.GetColumn(orderID, estado)
+		if err != nil {
+			return err
+		}
+		return repos.Checklist.SetPositions(ids)
+	})
+	if err != nil {
+		return err
+	}
+
+	wake(s.events)
+	return nil
+}
+
+// change runs fn with the order row locked, the same lock state changes take,
+// so a move can't pass the checklist gate while tasks are being reopened. The
+// checklist change event goes out with it.
+func (s *checklistService) change(orderID, userID uint, fn func(repos *repository.Repositories) error) error {
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := repos.Orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		if err := fn(repos); err != nil {
+			return err
+		}
+
+		progress, err := repos.Checklist.Progress(orderID)
+		if err != nil {
+			return err
+		}
+		return recordEvent(repos, domain.AgregadoOrden, orderID, EventOrderChecklistChanged, ChecklistChangeEvent{
+			OrderID:   orderID,
+			NumeroOP:  order.NumeroOP,
+			Checklist: progress,
+			ByUserID:  userID,
+		})
+	})
+}
+
+// insertAt moves id to posicion within ids; out of range positions append it
+func insertAt(ids []uint, id uint, posicion int) []uint {
+	rest := make([]uint, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			rest = append(rest, other)
+		}
+	}
+	if posicion < 0 || posicion > len(rest) {
+		posicion = len(rest)
+	}
+	return append(rest[:posicion], append([]uint{id}, rest[posicion:]...)...)
+}
+
+func validateTask(descripcion string) (string, error) {
+	descripcion = strings.TrimSpace(descripcion)
+	if descripcion == "" {
+		return "", errors.New("descripcion_tarea is required")
+	}
+	if utf8.RuneCountInString(descripcion) > maxTaskLength {
+		return "", fmt.Errorf("descripcion_tarea must be at most %d characters", maxTaskLength)
+	}
+	return descripcion, nil
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxCommentLength bounds a comment, in characters
+	maxCommentLength = 5000
+	// mentionExcerptLength is how much of the comment a mention notification quotes
+	mentionExcerptLength = 200
+)
+
+type CommentService interface {
+	GetComments(orderID, userID uint) ([]domain.OrderComment, error)
+	// CreateComment stores the comment and notifies every user it @mentions
+	CreateComment(orderID, userID uint, comentario string) (*domain.OrderComment, error)
+	// UpdateComment lets the author edit a comment; only users mentioned for
+	// the first time are notified
+	UpdateComment(orderID, commentID, userID uint, comentario string) (*domain.OrderComment, error)
+	// DeleteComment is allowed to the author and to users who can edit orders
+	DeleteComment(orderID, commentID, userID uint) error
+}
+
+type commentService struct {
+	commentRepo repository.CommentRepository
+	orderRepo   repository.OrderRepository
+	userRepo    repository.UserRepository
+	notifier    Notifier
+}
+
+func NewCommentService(commentRepo repository.CommentRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, notifier Notifier) CommentService {
+	return &commentService{
+		commentRepo: commentRepo,
+		orderRepo:   orderRepo,
+		userRepo:    userRepo,
+		notifier:    notifier,
+	}
+}
+
+func (s *commentService) GetComments(orderID, userID uint) ([]domain.OrderComment, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+	return s.commentRepo.ListByOrder(orderID)
+}
+
+func (s *commentService) CreateComment(orderID, userID uint, comentario string) (*domain.OrderComment, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderComment)
+	if err != nil {
+		return nil, err
+	}
+	comentario, err = validateComment(comentario)
+	if err != nil {
+		return nil, err
+	}
+	numeroOP, err := s.orderRepo.GetNumeroOP(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &domain.OrderComment{
+		IDOrden:    orderID,
+		IDUsuario:  user.ID,
+		Comentario: comentario,
+		Timestamp:  time.Now(),
+	}
+	if err := s.commentRepo.Create(comment); err != nil {
+		return nil, err
+	}
+
+	s.notifyMentions(user, orderID, numeroOP, comentario, "")
+	return s.commentRepo.GetByID(orderID, comment.ID)
+}
+
+func (s *commentService) UpdateComment(orderID, commentID, userID uint, comentario string) (*domain.OrderComment, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderComment)
+	if err != nil {
+		return nil, err
+	}
+	comentario, err = validateComment(comentario)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := s.commentRepo.GetByID(orderID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.IDUsuario != user.ID {
+		return nil, fmt.Errorf("%w: only the author can edit a comment", authz.ErrForbidden)
+	}
+	numeroOP, err := s.orderRepo.GetNumeroOP(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := comment.Comentario
+	now := time.Now()
+	comment.Comentario = comentario
+	comment.FechaEdicion = &now
+	if err := s.commentRepo.Update(comment); err != nil {
+		return nil, err
+	}
+
+	s.notifyMentions(user, orderID, numeroOP, comentario, previous)
+	return comment, nil
+}
+
+func (s *commentService) DeleteComment(orderID, commentID, userID uint) error {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderComment)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.commentRepo.GetByID(orderID, commentID)
+	if err != nil {
+		return err
+	}
+	if comment.IDUsuario != user.ID {
+		if err := authz.Check(user.Rol, authz.PermOrderEdit); err != nil {
+			return err
+		}
+	}
+
+	return s.commentRepo.Delete(comment.ID)
+}
+
+// notifyMentions notifies the users mentioned in comentario that weren't
+// already mentioned in previous. The comment is saved by then, so failures
+// are logged.
+func (s *commentService) notifyMentions(author *domain.User, orderID uint, numeroOP, comentario, previous string) {
+	if !strings.Contains(comentario, "@") {
+		return
+	}
+	users, err := s.userRepo.List()
+	if err != nil {
+		log.Printf("Failed to resolve mentions on order %d: %v", orderID, err)
+		return
+	}
+
+	already := make(map[uint]bool)
+	for _, user := range findMentions(previous, users) {
+		already[user.ID] = true
+	}
+	var userIDs []uint
+	for _, user := range findMentions(comentario, users) {
+		// Only users who can open the order hear about it
+		if user.ID == author.ID || already[user.ID] || !authz.Can(user.Rol, authz.PermOrderView) {
+			continue
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	excerpt := comentario
+	if utf8.RuneCountInString(excerpt) > mentionExcerptLength {
+		excerpt = string([]rune(excerpt)[:mentionExcerptLength]) + "…"
+	}
+	err = s.notifier.NotifyUsers(userIDs, domain.UserNotification{
+		Title:       author.Nombre + " te mencionó en " + numeroOP,
+		Description: &excerpt,
+		Type:        domain.NotificacionMencion,
+		OrdenID:     &orderID,
+	})
+	if err != nil {
+		log.Printf("Failed to notify mentions on order %d: %v", orderID, err)
+	}
+}
+
+// findMentions returns the users named after an @ in text. Names may contain
+// spaces, so at each @ the longest matching name wins; it has to end at a word
+// boundary. Matching ignores case.
+func findMentions(text string, users []domain.User) []domain.User {
+	if !strings.Contains(text, "@") {
+		return nil
+	}
+
+	candidates := make([]domain.User, 0, len(users))
+	for _, user := range users {
+		if user.Nombre != "" && !strings.HasPrefix(user.Nombre, domain.AnonymousNamePrefix) {
+			candidates = append(candidates, user)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return len(candidates[i].Nombre) > len(candidates[j].Nombre)
+	})
+
+	lower := strings.ToLower(text)
+	found := make(map[uint]bool)
+	var mentioned []domain.User
+	for i := 0; i < len(lower); i++ {
+		if lower[i] != '@' {
+			continue
+		}
+		// An @ inside a word is an e-mail address, not a mention
+		if i > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(lower[:i]); isNameRune(r) {
+				continue
+			}
+		}
+		rest := lower[i+1:]
+		for _, user := range candidates {
+			name := strings.ToLower(user.Nombre)
+			if !strings.HasPrefix(rest, name) {
+				continue
+			}
+			if r, _ := utf8.DecodeRuneInString(rest[len(name):]); isNameRune(r) {
+				continue
+			}
+			if !found[user.ID] {
+				found[user.ID] = true
+				mentioned = append(mentioned, user)
+			}
+			break
+		}
+	}
+	return mentioned
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+func validateComment(comentario string) (string, error) {
+	comentario = strings.TrimSpace(comentario)
+	if comentario == "" {
+		return "", errors.New("comentario is required")
+	}
+	if utf8.RuneCountInString(comentario) > maxCommentLength {
+		return "", errors.New("comentario is too long")
+	}
+	return comentario, nil
+}
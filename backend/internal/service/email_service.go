@@ -0,0 +1,272 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"task-board/pkg/mailer"
+	"time"
+)
+
+const (
+	// emailBatchSize is how many deliveries one dispatcher pass claims at a time
+	emailBatchSize = 50
+	// emailLease keeps a claimed delivery away from other dispatchers; it must
+	// outlast the SMTP timeout
+	emailLease = 10 * time.Minute
+	// maxEmailRetryDelay caps the backoff between attempts
+	maxEmailRetryDelay = 6 * time.Hour
+)
+
+// ErrNoEmailAddress is returned when email is picked for a user with no address
+var ErrNoEmailAddress = errors.New("user has no email address")
+
+// MailSender sends one email
+type MailSender interface {
+	Send(msg mailer.Message) error
+}
+
+// EmailSettings tunes retries and digests
+type EmailSettings struct {
+	// MaxAttempts is how many times a delivery is tried before it is given up
+	MaxAttempts int
+	// DigestHour is the local hour daily digests go out
+	DigestHour int
+	// AppURL is linked from every email when set
+	AppURL string
+}
+
+// EmailService is the email notification channel. Send queues the mail, or
+// holds the notification for the user's digest; Dispatch does the sending.
+type EmailService interface {
+	ChannelSender
+	// Dispatch queues the digests that are due and sends every delivery due
+	// at now, returning how many went out
+	Dispatch(now time.Time) (int, error)
+}
+
+type emailService struct {
+	emailRepo        repository.EmailRepository
+	notificationRepo repository.NotificationRepository
+	preferenceRepo   repository.NotificationPreferenceRepository
+	userRepo         repository.UserRepository
+	mailer           MailSender
+	settings         EmailSettings
+	location         *time.Location
+}
+
+func NewEmailService(emailRepo repository.EmailRepository, notificationRepo repository.NotificationRepository, preferenceRepo repository.NotificationPreferenceRepository, userRepo repository.UserRepository, mailer MailSender, settings EmailSettings, location *time.Location) EmailService {
+	if settings.MaxAttempts < 1 {
+		settings.MaxAttempts = 1
+	}
+	return &emailService{
+		emailRepo:        emailRepo,
+		notificationRepo: notificationRepo,
+		preferenceRepo:   preferenceRepo,
+		userRepo:         userRepo,
+		mailer:           mailer,
+		settings:         settings,
+		location:         location,
+	}
+}
+
+func (s *emailService) Send(user *domain.User, notification domain.UserNotification) error {
+	if user.Email == nil || *user.Email == "" {
+		return ErrNoEmailAddress
+	}
+	notification.UserID = user.ID
+	notification.Canal = domain.CanalEmail
+
+	preference, err := s.emailRepo.GetPreference(user.ID)
+	if err != nil {
+		return err
+	}
+	if preference != nil && preference.Modo != domain.ModoEmailInmediato {
+		// Waits for the next digest
+		return s.notificationRepo.Create([]domain.UserNotification{notification})
+	}
+
+	var order *domain.Order
+	if notification.OrdenID != nil {
+		// A missing order only leaves its line out of the mail
+		order, _ = s.emailRepo.GetOrder(*notification.OrdenID)
+	}
+	subject, body, err := renderImmediate(user.Nombre, notification, order, s.settings.AppURL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	delivery := &domain.EmailDelivery{
+		IDUsuario:      user.ID,
+		Destinatario:   *user.Email,
+		Asunto:         subject,
+		Cuerpo:         body,
+		Tipo:           domain.EnvioInmediato,
+		Estado:         domain.EnvioPendiente,
+		ProximoIntento: &now,
+	}
+	return s.emailRepo.QueueImmediate(delivery, &notification)
+}
+
+func (s *emailService) Dispatch(now time.Time) (int, error) {
+	if err := s.queueDigests(now); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for {
+		deliveries, err := s.emailRepo.LeaseDue(now, now.Add(emailLease), emailBatchSize)
+		if err != nil {
+			return sent, err
+		}
+		if len(deliveries) == 0 {
+			return sent, nil
+		}
+
+		userIDs := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			userIDs[i] = delivery.IDUsuario
+		}
+		windows, err := s.preferenceRepo.ListQuietHours(userIDs)
+		if err != nil {
+			return sent, err
+		}
+		quiet := make(map[uint]domain.QuietHours, len(windows))
+		for _, window := range windows {
+			quiet[window.IDUsuario] = window
+		}
+
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			if window, ok := quiet[delivery.IDUsuario]; ok && inQuietHours(window, now, s.location) {
+				// Held until the quiet hours end; that isn't an attempt
+				until := quietHoursEnd(window, now, s.location)
+				delivery.ProximoIntento = &until
+			} else if s.attempt(delivery, now) {
+				sent++
+			}
+			if err := s.emailRepo.UpdateAttempt(delivery); err != nil {
+				log.Printf("Failed to record email delivery %d: %v", delivery.ID, err)
+			}
+		}
+		if len(deliveries) < emailBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// attempt sends the delivery once and updates its state: sent, bounced when
+// the server refuses it for good, or scheduled for a retry until the attempts
+// run out
+func (s *emailService) attempt(delivery *domain.EmailDelivery, now time.Time) bool {
+	err := s.mailer.Send(mailer.Message{
+		To:      delivery.Destinatario,
+		Subject: delivery.Asunto,
+		Body:    delivery.Cuerpo,
+	})
+	delivery.Intentos++
+	delivery.ProximoIntento = nil
+	if err == nil {
+		sentAt := time.Now()
+		delivery.Estado = domain.EnvioEnviado
+		delivery.FechaEnvio = &sentAt
+		return true
+	}
+
+	message := err.Error()
+	delivery.UltimoError = &message
+	switch {
+	case mailer.IsPermanent(err):
+		delivery.Estado = domain.EnvioRebotado
+	case delivery.Intentos >= s.settings.MaxAttempts:
+		delivery.Estado = domain.EnvioFallido
+	default:
+		next := now.Add(emailRetryDelay(delivery.Intentos))
+		delivery.ProximoIntento = &next
+	}
+	log.Printf("Failed to send email delivery %d (attempt %d, %s): %v", delivery.ID, delivery.Intentos, delivery.Estado, err)
+	return false
+}
+
+// queueDigests turns each user's waiting notifications into one digest once
+// the digest slot after the oldest of them has passed. Notifications left
+// waiting by a switch back to immediate mail go out right away.
+func (s *emailService) queueDigests(now time.Time) error {
+	candidates, err := s.emailRepo.ListDigestCandidates([]string{
+		domain.ModoEmailInmediato, domain.ModoEmailHorario, domain.ModoEmailDiario,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range candidates {
+		if !candidate.Oldest.Before(s.digestSlot(candidate.Modo, now)) {
+			continue
+		}
+		if err := s.queueDigest(candidate, now); err != nil && !errors.Is(err, repository.ErrDigestTaken) {
+			log.Printf("Failed to queue email digest for user %d: %v", candidate.IDUsuario, err)
+		}
+	}
+	return nil
+}
+
+func (s *emailService) queueDigest(candidate repository.DigestCandidate, now time.Time) error {
+	user, err := s.userRepo.GetByID(candidate.IDUsuario)
+	if err != nil {
+		return err
+	}
+	if user.Email == nil || *user.Email == "" {
+		// They keep waiting until the user gets an address
+		return ErrNoEmailAddress
+	}
+	notifications, err := s.emailRepo.ListWaiting(user.ID)
+	if err != nil || len(notifications) == 0 {
+		return err
+	}
+
+	subject, body, err := renderDigest(user.Nombre, candidate.Modo, notifications, s.location, s.settings.AppURL)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, len(notifications))
+	for i, notification := range notifications {
+		ids[i] = notification.ID
+	}
+	delivery := &domain.EmailDelivery{
+		IDUsuario:      user.ID,
+		Destinatario:   *user.Email,
+		Asunto:         subject,
+		Cuerpo:         body,
+		Tipo:           domain.EnvioResumen,
+		Estado:         domain.EnvioPendiente,
+		ProximoIntento: &now,
+	}
+	return s.emailRepo.QueueDigest(delivery, ids, now)
+}
+
+// digestSlot returns the latest digest time at or before now for the mode
+func (s *emailService) digestSlot(modo string, now time.Time) time.Time {
+	local := now.In(s.location)
+	switch modo {
+	case domain.ModoEmailHorario:
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, s.location)
+	case domain.ModoEmailDiario:
+		slot := time.Date(local.Year(), local.Month(), local.Day(), s.settings.DigestHour, 0, 0, 0, s.location)
+		if slot.After(local) {
+			slot = slot.AddDate(0, 0, -1)
+		}
+		return slot
+	default:
+		return now
+	}
+}
+
+// emailRetryDelay backs off 1m, 4m, 16m, ... after each failed attempt
+func emailRetryDelay(attempts int) time.Duration {
+	if attempts > 8 {
+		return maxEmailRetryDelay
+	}
+	return min(time.Minute<<(2*(attempts-1)), maxEmailRetryDelay)
+}
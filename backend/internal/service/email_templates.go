@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"task-board/internal/domain"
+	"text/template"
+	"time"
+)
+
+// Email templates, in Spanish like the rest of the board. Bodies are plain text.
+var (
+	immediateEmail = template.Must(template.New("inmediato").Parse(`Hola {{.Nombre}},
+
+{{.Titulo}}
+{{- with .Descripcion}}
+
+{{.}}
+{{- end}}
+{{- with .Orden}}
+
+Orden {{.NumeroOP}} - {{.Cliente}}
+{{- end}}{{template "pie" .}}`))
+
+	digestEmail = template.Must(template.New("resumen").Parse(`Hola {{.Nombre}},
+
+{{if eq .Total 1}}Tenés 1 novedad{{else}}Tenés {{.Total}} novedades{{end}} {{.Periodo}}.
+{{- range .Grupos}}
+
+{{if .Orden}}Orden {{.Orden.NumeroOP}} - {{.Orden.Cliente}}{{else}}General{{end}}
+{{- range .Items}}
+  · {{.Hora}}  {{.Titulo}}{{with .Descripcion}}: {{.}}{{end}}
+{{- end}}
+{{- end}}{{template "pie" .}}`))
+
+	emailFooter = `{{define "pie"}}{{with .Enlace}}
+
+Abrí el tablero: {{.}}
+{{- end}}
+
+--
+Plot Center
+Recibís este correo por tus preferencias de notificación; podés cambiarlas desde el tablero.
+{{end}}`
+)
+
+func init() {
+	template.Must(immediateEmail.Parse(emailFooter))
+	template.Must(digestEmail.Parse(emailFooter))
+}
+
+type emailOrder struct {
+	NumeroOP string
+	Cliente  string
+}
+
+type digestItem struct {
+	Hora        string
+	Titulo      string
+	Descripcion string
+}
+
+type digestGroup struct {
+	Orden *emailOrder
+	Items []digestItem
+}
+
+// renderImmediate returns the subject and body of a single notification email
+func renderImmediate(nombre string, notification domain.UserNotification, order *domain.Order, link string) (string, string, error) {
+	data := struct {
+		Nombre      string
+		Titulo      string
+		Descripcion string
+		Orden       *emailOrder
+		Enlace      string
+	}{Nombre: nombre, Titulo: notification.Title, Enlace: link}
+	if notification.Description != nil {
+		data.Descripcion = *notification.Description
+	}
+	if order != nil {
+		data.Orden = &emailOrder{NumeroOP: order.NumeroOP, Cliente: order.Cliente}
+	}
+
+	var body strings.Builder
+	if err := immediateEmail.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return notification.Title, body.String(), nil
+}
+
+// renderDigest returns the subject and body of a digest. The notifications
+// come grouped by order, as ListWaiting returns them.
+func renderDigest(nombre, modo string, notifications []domain.UserNotification, loc *time.Location, link string) (string, string, error) {
+	var groups []digestGroup
+	var current *uint
+	for i, notification := range notifications {
+		if i == 0 || !sameOrder(current, notification.OrdenID) {
+			group := digestGroup{}
+			if notification.Orden != nil {
+				group.Orden = &emailOrder{NumeroOP: notification.Orden.NumeroOP, Cliente: notification.Orden.Cliente}
+			}
+			groups = append(groups, group)
+			current = notification.OrdenID
+		}
+		item := digestItem{
+			Hora:   notification.CreatedAt.In(loc).Format("02/01 15:04"),
+			Titulo: notification.Title,
+		}
+		if notification.Description != nil {
+			item.Descripcion = *notification.Description
+		}
+		groups[len(groups)-1].Items = append(groups[len(groups)-1].Items, item)
+	}
+
+	periodo := "de la última hora"
+	if modo == domain.ModoEmailDiario {
+		periodo = "del día"
+	} else if modo == domain.ModoEmailInmediato {
+		periodo = "pendientes"
+	}
+	data := struct {
+		Nombre  string
+		Total   int
+		Periodo string
+		Grupos  []digestGroup
+		Enlace  string
+	}{Nombre: nombre, Total: len(notifications), Periodo: periodo, Grupos: groups, Enlace: link}
+
+	var body strings.Builder
+	if err := digestEmail.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	subject := fmt.Sprintf("Resumen de notificaciones: %d novedades", len(notifications))
+	if len(notifications) == 1 {
+		subject = "Resumen de notificaciones: 1 novedad"
+	}
+	return subject, body.String(), nil
+}
+
+func sameOrder(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
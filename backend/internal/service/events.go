@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"time"
+)
+
+// Broadcaster pushes real-time events to the connected WebSocket clients whose
+// role holds the permission
+type Broadcaster interface {
+	BroadcastMessage(perm authz.Permission, msgType string, data interface{}) error
+}
+
+// UserPusher pushes real-time events to the WebSocket connections of one user
+type UserPusher interface {
+	SendToUser(userID uint, msgType string, data interface{}) error
+}
+
+// Event is a committed domain event, as the outbox dispatcher relays it
+type Event struct {
+	ID        string // the same on every redelivery
+	Type      string
+	Aggregate string // domain.Agregado*
+	Time      time.Time
+	Data      json.RawMessage
+	UserID    uint // when set, the event is only for this user
+}
+
+// EventSubscriber receives the events relayed by the outbox dispatcher.
+// Delivery is at least once, so an event may arrive again after a failure.
+type EventSubscriber interface {
+	HandleEvent(event Event) error
+}
+
+// EventWaker nudges the outbox dispatcher once new events are committed, so
+// they don't wait for the next polling tick
+type EventWaker interface {
+	Wake()
+}
+
+func wake(events EventWaker) {
+	if events != nil {
+		events.Wake()
+	}
+}
+
+// broadcastPermissions is what a WebSocket client's role needs to get the
+// broadcast events of each aggregate. Aggregates missing here are never
+// broadcast; their events must name a user.
+var broadcastPermissions = map[string]authz.Permission{
+	domain.AgregadoOrden: authz.PermOrderView,
+}
+
+type realtimeSubscriber struct {
+	broadcaster Broadcaster
+	pusher      UserPusher
+}
+
+// NewRealtimeSubscriber relays outbox events to the WebSocket clients: to the
+// clients allowed to see orders, or only to the connections of the event's user
+func NewRealtimeSubscriber(broadcaster Broadcaster, pusher UserPusher) EventSubscriber {
+	return &realtimeSubscriber{broadcaster: broadcaster, pusher: pusher}
+}
+
+func (s *realtimeSubscriber) HandleEvent(event Event) error {
+	if event.UserID != 0 {
+		return s.pusher.SendToUser(event.UserID, event.Type, event.Data)
+	}
+	perm, ok := broadcastPermissions[event.Aggregate]
+	if !ok {
+		log.Printf("Not broadcasting %s: no permission for aggregate %q", event.Type, event.Aggregate)
+		return nil
+	}
+	return s.broadcaster.BroadcastMessage(perm, event.Type, event.Data)
+}
+
+// Real-time event types
+const (
+	EventOrderCreated      = "order_created"
+	EventOrderUpdated      = "order_updated"
+	EventOrderStateChanged = "order_state_changed"
+	EventOrderMoved        = "order_moved" // reordered within its column
+	EventOrderDeleted      = "order_deleted"
+
+	EventOrderClaimed  = "order_claimed"
+	EventOrderReleased = "order_released"
+	EventNotification  = "notification"
+
+	EventNotificationsRead = "notifications_read"
+
+	EventOrderSectorChanged    = "order_sector_changed"
+	EventOrderChecklistChanged = "order_checklist_changed"
+
+	EventBoardCreated = "board_created"
+	EventBoardUpdated = "board_updated"
+	EventBoardDeleted = "board_deleted"
+	EventTaskCreated  = "task_created"
+	EventTaskUpdated  = "task_updated"
+	EventTaskDeleted  = "task_deleted"
+)
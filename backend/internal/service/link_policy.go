@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrLinkNotAllowed is returned for URLs rejected by the link policy
+var ErrLinkNotAllowed = errors.New("link is not allowed")
+
+// maxLinkLength bounds a normalized URL
+const maxLinkLength = 2048
+
+// LinkPolicy decides which URLs can be attached to orders. Hosts match exactly
+// or, written as "*.example.com", on any subdomain. Denied hosts win over
+// allowed ones; with no allowed hosts every host not denied is accepted.
+type LinkPolicy struct {
+	schemes map[string]bool
+	allowed []string
+	denied  []string
+}
+
+// NewLinkPolicy parses comma separated lists, such as "http,https" and
+// "drive.google.com,*.dropbox.com"
+func NewLinkPolicy(schemes, allowedHosts, deniedHosts string) (*LinkPolicy, error) {
+	policy := &LinkPolicy{schemes: map[string]bool{}}
+	for _, scheme := range splitList(schemes) {
+		if scheme != "http" && scheme != "https" {
+			return nil, fmt.Errorf("unsupported link scheme %q", scheme)
+		}
+		policy.schemes[scheme] = true
+	}
+	if len(policy.schemes) == 0 {
+		return nil, errors.New("at least one link scheme must be allowed")
+	}
+
+	var err error
+	if policy.allowed, err = parseHostPatterns(allowedHosts); err != nil {
+		return nil, err
+	}
+	if policy.denied, err = parseHostPatterns(deniedHosts); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Normalize validates a URL and returns its canonical form: lowercase scheme
+// and host, no default port, no credentials and at least "/" as the path
+func (p *LinkPolicy) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("url is required")
+	}
+	// Links pasted without a scheme, like "drive.google.com/...", are web links
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid url", ErrLinkNotAllowed)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.User != nil {
+		return "", fmt.Errorf("%w: credentials in links are not accepted", ErrLinkNotAllowed)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	if port != "" {
+		u.Host = host + ":" + port
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.Fragment == "" {
+		u.RawFragment = ""
+	}
+
+	if err := p.Check(u); err != nil {
+		return "", err
+	}
+	normalized := u.String()
+	if len(normalized) > maxLinkLength {
+		return "", fmt.Errorf("%w: url is too long", ErrLinkNotAllowed)
+	}
+	return normalized, nil
+}
+
+// Check applies the scheme and host rules to a parsed URL
+func (p *LinkPolicy) Check(u *url.URL) error {
+	if !p.schemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("%w: scheme %q", ErrLinkNotAllowed, u.Scheme)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: url has no host", ErrLinkNotAllowed)
+	}
+	if matchHost(p.denied, host) {
+		return fmt.Errorf("%w: host %s", ErrLinkNotAllowed, host)
+	}
+	if len(p.allowed) > 0 && !matchHost(p.allowed, host) {
+		return fmt.Errorf("%w: host %s", ErrLinkNotAllowed, host)
+	}
+	return nil
+}
+
+func parseHostPatterns(list string) ([]string, error) {
+	patterns := splitList(list)
+	for _, pattern := range patterns {
+		host := strings.TrimPrefix(pattern, "*.")
+		if host == "" || strings.ContainsAny(host, "*/:@ ") {
+			return nil, fmt.Errorf("invalid link host %q", pattern)
+		}
+	}
+	return patterns, nil
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// ErrLinkExists is returned when the order already has the same link
+var ErrLinkExists = errors.New("the order already has this link")
+
+// TitleFetcher reads the title of the page a link points to
+type TitleFetcher interface {
+	Title(ctx context.Context, url string) (string, error)
+}
+
+type LinkInput struct {
+	URL         string
+	Descripcion *string
+}
+
+type LinkService interface {
+	GetLinks(orderID, userID uint) ([]domain.OrderLink, error)
+	// AddLink normalizes the URL and checks it against the link policy. With a
+	// title fetcher the page title is captured too; failing to read it doesn't
+	// stop the link from being added.
+	AddLink(orderID, userID uint, input LinkInput) (*domain.OrderLink, error)
+	// RemoveLink is allowed to whoever added the link and to users who can edit orders
+	RemoveLink(orderID, linkID, userID uint) error
+}
+
+type linkService struct {
+	linkRepo  repository.LinkRepository
+	orderRepo repository.OrderRepository
+	userRepo  repository.UserRepository
+	policy    *LinkPolicy
+	titles    TitleFetcher // nil when title capture is disabled
+}
+
+func NewLinkService(linkRepo repository.LinkRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, policy *LinkPolicy, titles TitleFetcher) LinkService {
+	return &linkService{
+		linkRepo:  linkRepo,
+		orderRepo: orderRepo,
+		userRepo:  userRepo,
+		policy:    policy,
+		titles:    titles,
+	}
+}
+
+func (s *linkService) GetLinks(orderID, userID uint) ([]domain.OrderLink, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+	return s.linkRepo.ListByOrder(orderID)
+}
+
+func (s *linkService) AddLink(orderID, userID uint, input LinkInput) (*domain.OrderLink, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderAttach)
+	if err != nil {
+		return nil, err
+	}
+	url, err := s.policy.Normalize(input.URL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+	exists, err := s.linkRepo.ExistsURL(orderID, url)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrLinkExists
+	}
+
+	link := &domain.OrderLink{
+		IDOrden:   orderID,
+		URL:       url,
+		IDUsuario: &user.ID,
+		Timestamp: time.Now(),
+	}
+	if input.Descripcion != nil {
+		if descripcion := strings.TrimSpace(*input.Descripcion); descripcion != "" {
+			link.Descripcion = &descripcion
+		}
+	}
+	if s.titles != nil {
+		title, err := s.titles.Title(context.Background(), url)
+		if err != nil {
+			log.Printf("Failed to read the title of a link on order %d: %v", orderID, err)
+		} else if title != "" {
+			link.Titulo = &title
+		}
+	}
+
+	if err := s.linkRepo.Create(link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *linkService) RemoveLink(orderID, linkID, userID uint) error {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderAttach)
+	if err != nil {
+		return err
+	}
+
+	link, err := s.linkRepo.GetByID(orderID, linkID)
+	if err != nil {
+		return err
+	}
+	if link.IDUsuario == nil || *link.IDUsuario != user.ID {
+		if err := authz.Check(user.Rol, authz.PermOrderEdit); err != nil {
+			return err
+		}
+	}
+
+	return s.linkRepo.Delete(link.ID)
+}
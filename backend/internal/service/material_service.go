@@ -0,0 +1,288 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+)
+
+var (
+	// ErrMaterialCodeTaken is returned when another material already uses the code
+	ErrMaterialCodeTaken = errors.New("material with this codigo already exists")
+	// ErrMaterialInUse is returned when deleting a material that is on an order
+	ErrMaterialInUse = errors.New("material is used by orders")
+	// ErrMaterialOnOrder is returned when adding a material the order already has a line for
+	ErrMaterialOnOrder = errors.New("material is already on the order, update its line instead")
+)
+
+// MaterialInput holds the editable fields of a material
+type MaterialInput struct {
+	Codigo          *string
+	Descripcion     string
+	PuntoReposicion *float64
+}
+
+type MaterialService interface {
+	GetMaterials(userID uint, filter repository.MaterialFilter) ([]domain.Material, error)
+	GetMaterial(materialID, userID uint) (*domain.Material, error)
+	CreateMaterial(userID uint, input MaterialInput) (*domain.Material, error)
+	UpdateMaterial(materialID, userID uint, input MaterialInput) (*domain.Material, error)
+	DeleteMaterial(materialID, userID uint) error
+
+	// Bill of materials of an order
+	GetOrderMaterials(orderID, userID uint) ([]domain.OrderMaterial, error)
+	AddOrderMaterial(orderID, userID, materialID uint, cantidad float64) (*domain.OrderMaterial, error)
+	UpdateOrderMaterial(orderID, lineID, userID uint, cantidad float64) (*domain.OrderMaterial, error)
+	RemoveOrderMaterial(orderID, lineID, userID uint) error
+}
+
+type materialService struct {
+	materialRepo repository.MaterialRepository
+	orderRepo    repository.OrderRepository
+	userRepo     repository.UserRepository
+	uow          repository.UnitOfWork
+	notifier     Notifier
+}
+
+func NewMaterialService(materialRepo repository.MaterialRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, uow repository.UnitOfWork, notifier Notifier) MaterialService {
+	return &materialService{
+		materialRepo: materialRepo,
+		orderRepo:    orderRepo,
+		userRepo:     userRepo,
+		uow:          uow,
+		notifier:     notifier,
+	}
+}
+
+func (s *materialService) GetMaterials(userID uint, filter repository.MaterialFilter) ([]domain.Material, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	filter.Search = strings.TrimSpace(filter.Search)
+	return s.materialRepo.List(filter)
+}
+
+func (s *materialService) GetMaterial(materialID, userID uint) (*domain.Material, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	return s.materialRepo.GetByID(materialID)
+}
+
+func (s *materialService) CreateMaterial(userID uint, input MaterialInput) (*domain.Material, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermMaterialManage); err != nil {
+		return nil, err
+	}
+	if err := s.validateMaterialInput(&input, 0); err != nil {
+		return nil, err
+	}
+
+	material := &domain.Material{
+		Codigo:          input.Codigo,
+		Descripcion:     input.Descripcion,
+		PuntoReposicion: input.PuntoReposicion,
+	}
+	if err := s.materialRepo.Create(material); err != nil {
+		return nil, err
+	}
+
+	return material, nil
+}
+
+func (s *materialService) UpdateMaterial(materialID, userID uint, input MaterialInput) (*domain.Material, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermMaterialManage); err != nil {
+		return nil, err
+	}
+
+	material, err := s.materialRepo.GetByID(materialID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateMaterialInput(&input, material.ID); err != nil {
+		return nil, err
+	}
+
+	material.Codigo = input.Codigo
+	material.Descripcion = input.Descripcion
+	material.PuntoReposicion = input.PuntoReposicion
+	if err := s.materialRepo.Update(material); err != nil {
+		return nil, err
+	}
+
+	return material, nil
+}
+
+func (s *materialService) DeleteMaterial(materialID, userID uint) error {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermMaterialManage); err != nil {
+		return err
+	}
+
+	// AddOrderMaterial locks the material too, so no line can be added between
+	// the check and the delete
+	return s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := repos.Materials.GetByIDForUpdate(materialID); err != nil {
+			return err
+		}
+		used, err := repos.Materials.IsUsed(materialID)
+		if err != nil {
+			return err
+		}
+		if used {
+			return ErrMaterialInUse
+		}
+		return repos.Materials.Delete(materialID)
+	})
+}
+
+func (s *materialService) GetOrderMaterials(orderID, userID uint) ([]domain.OrderMaterial, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+	return s.materialRepo.ListOrderMaterials(orderID)
+}
+
+func (s *materialService) AddOrderMaterial(orderID, userID, materialID uint, cantidad float64) (*domain.OrderMaterial, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderEdit); err != nil {
+		return nil, err
+	}
+	if cantidad <= 0 {
+		return nil, errors.New("cantidad must be greater than zero")
+	}
+	line := &domain.OrderMaterial{
+		IDOrden:    orderID,
+		IDMaterial: materialID,
+		Cantidad:   cantidad,
+	}
+	err := s.withOrderStock(orderID, userID, func(repos *repository.Repositories, order *domain.Order) error {
+		// The new material is locked with the others, in the same order
+		// syncing the stock takes them, which also keeps DeleteMaterial out
+		lines, err := repos.Materials.ListOrderMaterials(orderID)
+		if err != nil {
+			return err
+		}
+		if err := lockMaterials(repos, lines, materialID); err != nil {
+			return err
+		}
+		if _, err := repos.Materials.GetByID(materialID); err != nil {
+			return err
+		}
+		existing, err := repos.Materials.FindOrderMaterial(orderID, materialID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrMaterialOnOrder
+		}
+		return repos.Materials.CreateOrderMaterial(line)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.materialRepo.GetOrderMaterial(orderID, line.ID)
+}
+
+func (s *materialService) UpdateOrderMaterial(orderID, lineID, userID uint, cantidad float64) (*domain.OrderMaterial, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderEdit); err != nil {
+		return nil, err
+	}
+	if cantidad <= 0 {
+		return nil, errors.New("cantidad must be greater than zero")
+	}
+
+	err := s.withOrderStock(orderID, userID, func(repos *repository.Repositories, order *domain.Order) error {
+		line, err := repos.Materials.GetOrderMaterial(orderID, lineID)
+		if err != nil {
+			return err
+		}
+		line.Cantidad = cantidad
+		return repos.Materials.UpdateOrderMaterial(line)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.materialRepo.GetOrderMaterial(orderID, lineID)
+}
+
+func (s *materialService) RemoveOrderMaterial(orderID, lineID, userID uint) error {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderEdit); err != nil {
+		return err
+	}
+
+	low := lowStockSet{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		if _, err := repos.Orders.GetByIDForUpdate(orderID); err != nil {
+			return err
+		}
+		line, err := repos.Materials.GetOrderMaterial(orderID, lineID)
+		if err != nil {
+			return err
+		}
+		// Release the line's reservation whatever the order's state
+		if err := syncLineStock(repos, low, orderID, *line, stockNone, &userID); err != nil {
+			return err
+		}
+		return repos.Materials.DeleteOrderMaterial(lineID)
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyLowStock(s.notifier, low)
+	return nil
+}
+
+// withOrderStock runs a bill of materials change with the order locked, then
+// brings the order's stock reservations in step with its lines
+func (s *materialService) withOrderStock(orderID, userID uint, fn func(repos *repository.Repositories, order *domain.Order) error) error {
+	low := lowStockSet{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := repos.Orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		if err := fn(repos, order); err != nil {
+			return err
+		}
+		return syncOrderStock(repos, low, orderID, order.Estado, &userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyLowStock(s.notifier, low)
+	return nil
+}
+
+// validateMaterialInput normalizes the input and checks the code is free.
+// selfID is the material being updated, or 0 on create.
+func (s *materialService) validateMaterialInput(input *MaterialInput, selfID uint) error {
+	input.Descripcion = strings.TrimSpace(input.Descripcion)
+	if input.Descripcion == "" {
+		return errors.New("descripcion is required")
+	}
+	if input.PuntoReposicion != nil && *input.PuntoReposicion < 0 {
+		return errors.New("punto_reposicion must be zero or greater")
+	}
+
+	if input.Codigo != nil {
+		codigo := strings.TrimSpace(*input.Codigo)
+		if codigo == "" {
+			input.Codigo = nil
+			return nil
+		}
+		input.Codigo = &codigo
+
+		if existing, _ := s.materialRepo.GetByCodigo(codigo); existing != nil && existing.ID != selfID {
+			return ErrMaterialCodeTaken
+		}
+	}
+
+	return nil
+}
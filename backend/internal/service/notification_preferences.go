@@ -0,0 +1,113 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"task-board/internal/domain"
+	"time"
+)
+
+// NotificationEvents lists the events users can route, in display order
+var NotificationEvents = []string{
+	domain.EventoMencion,
+	domain.EventoAsignacion,
+	domain.EventoCambioEstado,
+	domain.EventoAlertaPlazo,
+	domain.EventoAlertaStock,
+}
+
+// notificationEvent maps a notification type to the event its preference is
+// kept under. Types without one, such as smart alerts, always go in-app.
+var notificationEvent = map[string]string{
+	domain.NotificacionMencion:      domain.EventoMencion,
+	domain.NotificacionAsignacion:   domain.EventoAsignacion,
+	domain.NotificacionCambioEstado: domain.EventoCambioEstado,
+	domain.NotificacionPlazo:        domain.EventoAlertaPlazo,
+	domain.NotificacionEstancada:    domain.EventoAlertaPlazo,
+	domain.NotificacionStockBajo:    domain.EventoAlertaStock,
+}
+
+// NotificationSettings are a user's channel per event and quiet hours
+type NotificationSettings struct {
+	// Preferencias holds the channel of every event
+	Preferencias map[string]string `json:"preferencias"`
+	// Silencio is nil when the user has no quiet hours
+	Silencio *domain.QuietHours `json:"silencio"`
+	// ModoEmail is whether email comes one per notification or in an hourly
+	// or daily digest
+	ModoEmail string `json:"modo_email"`
+	// Canales lists the channels that can be picked
+	Canales []string `json:"canales"`
+}
+
+// PreferencesInput replaces a user's notification settings
+type PreferencesInput struct {
+	Preferencias map[string]string
+	Silencio     *domain.QuietHours
+	ModoEmail    string
+}
+
+var emailModes = []string{domain.ModoEmailInmediato, domain.ModoEmailHorario, domain.ModoEmailDiario}
+
+// ChannelSender delivers notifications over a channel other than the in-app
+// notification center, such as email
+type ChannelSender interface {
+	Send(user *domain.User, notification domain.UserNotification) error
+}
+
+// validatePreferences rejects unknown events and channels that are unknown or
+// not available, and returns the preferences as rows
+func validatePreferences(preferencias map[string]string, canales []string) ([]domain.NotificationPreference, error) {
+	rows := make([]domain.NotificationPreference, 0, len(preferencias))
+	for evento, canal := range preferencias {
+		if !slices.Contains(NotificationEvents, evento) {
+			return nil, fmt.Errorf("unknown notification event %q", evento)
+		}
+		if !slices.Contains(canales, canal) {
+			return nil, fmt.Errorf("channel %q is not available", canal)
+		}
+		// The default needs no row
+		if canal != domain.CanalApp {
+			rows = append(rows, domain.NotificationPreference{Evento: evento, Canal: canal})
+		}
+	}
+	return rows, nil
+}
+
+// validateQuietHours normalizes both ends to HH:MM
+func validateQuietHours(quiet *domain.QuietHours) error {
+	for _, end := range []*string{&quiet.Desde, &quiet.Hasta} {
+		t, err := time.Parse("15:04", strings.TrimSpace(*end))
+		if err != nil {
+			return fmt.Errorf("invalid quiet hour %q, expected HH:MM", *end)
+		}
+		*end = t.Format("15:04")
+	}
+	if quiet.Desde == quiet.Hasta {
+		return fmt.Errorf("quiet hours must start and end at different times")
+	}
+	return nil
+}
+
+// inQuietHours reports whether now, in loc, falls inside the quiet hours.
+// The window includes Desde and excludes Hasta.
+func inQuietHours(quiet domain.QuietHours, now time.Time, loc *time.Location) bool {
+	clock := now.In(loc).Format("15:04")
+	if quiet.Desde < quiet.Hasta {
+		return clock >= quiet.Desde && clock < quiet.Hasta
+	}
+	// Wraps past midnight, e.g. 22:00-07:00
+	return clock >= quiet.Desde || clock < quiet.Hasta
+}
+
+// quietHoursEnd returns when the quiet hours that now falls in end
+func quietHoursEnd(quiet domain.QuietHours, now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	hasta, _ := time.Parse("15:04", quiet.Hasta)
+	end := time.Date(local.Year(), local.Month(), local.Day(), hasta.Hour(), hasta.Minute(), 0, 0, loc)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
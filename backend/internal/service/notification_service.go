@@ -0,0 +1,282 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+const (
+	defaultNotificationPage = 20
+	maxNotificationPage     = 100
+	// changesWindow is how far back the "what changed on my orders" feed looks
+	changesWindow = 30 * 24 * time.Hour
+)
+
+// NotificationPage is one page of a user's notifications, newest first
+type NotificationPage struct {
+	Notifications []domain.UserNotification `json:"notifications"`
+	UnreadCount   int64                     `json:"unread_count"`
+	// NextCursor is passed as cursor to get the next page; nil on the last one
+	NextCursor *uint `json:"next_cursor"`
+}
+
+// ChangesFeed lists what others changed on the user's orders since they last looked
+type ChangesFeed struct {
+	Changes []repository.OrderChange `json:"changes"`
+	// Unseen counts every unseen change, including those beyond the limit
+	Unseen int64 `json:"unseen"`
+}
+
+// NotificationsReadEvent is pushed to the user's other connections when
+// notifications are read, so every open tab updates its badge
+type NotificationsReadEvent struct {
+	IDs         []uint `json:"ids,omitempty"` // nil when all were read
+	UnreadCount int64  `json:"unread_count"`
+}
+
+type NotificationService interface {
+	GetNotifications(userID uint, filter repository.NotificationFilter) (*NotificationPage, error)
+	GetUnreadCount(userID uint) (int64, error)
+	MarkRead(notificationID, userID uint) (int64, error)
+	// MarkAllRead marks every notification up to upToID as read, or all of them
+	// when it is 0, so ones that arrived after the list was loaded stay unread
+	MarkAllRead(userID, upToID uint) (int64, error)
+
+	GetChanges(userID uint, limit int) (*ChangesFeed, error)
+	// MarkChangesSeen records the changes as seen by the user
+	MarkChangesSeen(userID uint, ids []uint, upToID uint) (int64, error)
+
+	GetPreferences(userID uint) (*NotificationSettings, error)
+	// UpdatePreferences replaces the user's settings; events left out go back
+	// to the in-app default
+	UpdatePreferences(userID uint, input PreferencesInput) (*NotificationSettings, error)
+	// GetEmailDeliveries returns the user's latest emails and how sending them went
+	GetEmailDeliveries(userID uint, limit int) ([]domain.EmailDelivery, error)
+}
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+	preferenceRepo   repository.NotificationPreferenceRepository
+	emailRepo        repository.EmailRepository
+	userRepo         repository.UserRepository
+	notifier         Notifier
+	pusher           UserPusher
+}
+
+func NewNotificationService(notificationRepo repository.NotificationRepository, preferenceRepo repository.NotificationPreferenceRepository, emailRepo repository.EmailRepository, userRepo repository.UserRepository, notifier Notifier, pusher UserPusher) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		preferenceRepo:   preferenceRepo,
+		emailRepo:        emailRepo,
+		userRepo:         userRepo,
+		notifier:         notifier,
+		pusher:           pusher,
+	}
+}
+
+func (s *notificationService) GetNotifications(userID uint, filter repository.NotificationFilter) (*NotificationPage, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+	filter.Limit = pageSize(filter.Limit)
+
+	// One extra row tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	notifications, err := s.notificationRepo.List(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NotificationPage{Notifications: notifications, UnreadCount: unread}
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		cursor := page.Notifications[limit-1].ID
+		page.NextCursor = &cursor
+	}
+	return page, nil
+}
+
+func (s *notificationService) GetUnreadCount(userID uint) (int64, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return 0, err
+	}
+	return s.notificationRepo.CountUnread(userID)
+}
+
+func (s *notificationService) MarkRead(notificationID, userID uint) (int64, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return 0, err
+	}
+	marked, err := s.notificationRepo.MarkRead(userID, notificationID)
+	if err != nil {
+		return 0, err
+	}
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return 0, err
+	}
+	if marked {
+		s.pushRead(userID, []uint{notificationID}, unread)
+	}
+	return unread, nil
+}
+
+func (s *notificationService) MarkAllRead(userID, upToID uint) (int64, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return 0, err
+	}
+	marked, err := s.notificationRepo.MarkAllRead(userID, upToID)
+	if err != nil {
+		return 0, err
+	}
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return 0, err
+	}
+	if marked > 0 {
+		s.pushRead(userID, nil, unread)
+	}
+	return unread, nil
+}
+
+func (s *notificationService) GetChanges(userID uint, limit int) (*ChangesFeed, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderView)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-changesWindow)
+	changes, err := s.notificationRepo.ListChanges(user, since, pageSize(limit))
+	if err != nil {
+		return nil, err
+	}
+	unseen, err := s.notificationRepo.CountChanges(user, since)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []repository.OrderChange{}
+	}
+	return &ChangesFeed{Changes: changes, Unseen: unseen}, nil
+}
+
+func (s *notificationService) MarkChangesSeen(userID uint, ids []uint, upToID uint) (int64, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermOrderView)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 && upToID == 0 {
+		return 0, errors.New("ids or hasta_id is required")
+	}
+
+	since := time.Now().Add(-changesWindow)
+	if _, err := s.notificationRepo.MarkChangesSeen(user, since, ids, upToID); err != nil {
+		return 0, err
+	}
+	return s.notificationRepo.CountChanges(user, since)
+}
+
+func (s *notificationService) GetPreferences(userID uint) (*NotificationSettings, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+	return s.settings(userID)
+}
+
+func (s *notificationService) UpdatePreferences(userID uint, input PreferencesInput) (*NotificationSettings, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := validatePreferences(input.Preferencias, s.notifier.Channels())
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.Canal == domain.CanalEmail && (user.Email == nil || *user.Email == "") {
+			return nil, ErrNoEmailAddress
+		}
+	}
+	if input.Silencio != nil {
+		if err := validateQuietHours(input.Silencio); err != nil {
+			return nil, err
+		}
+	}
+	if input.ModoEmail == "" {
+		input.ModoEmail = domain.ModoEmailInmediato
+	}
+	if !slices.Contains(emailModes, input.ModoEmail) {
+		return nil, fmt.Errorf("unknown modo_email %q", input.ModoEmail)
+	}
+
+	if err := s.preferenceRepo.Save(userID, rows, input.Silencio, input.ModoEmail); err != nil {
+		return nil, err
+	}
+	return s.settings(userID)
+}
+
+func (s *notificationService) GetEmailDeliveries(userID uint, limit int) ([]domain.EmailDelivery, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+	return s.emailRepo.ListDeliveries(userID, pageSize(limit))
+}
+
+// settings returns the channel of every event, defaults included
+func (s *notificationService) settings(userID uint) (*NotificationSettings, error) {
+	preferences, err := s.preferenceRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	quiet, err := s.preferenceRepo.GetQuietHours(userID)
+	if err != nil {
+		return nil, err
+	}
+	email, err := s.emailRepo.GetPreference(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &NotificationSettings{
+		Preferencias: make(map[string]string, len(NotificationEvents)),
+		Silencio:     quiet,
+		ModoEmail:    domain.ModoEmailInmediato,
+		Canales:      s.notifier.Channels(),
+	}
+	if email != nil {
+		settings.ModoEmail = email.Modo
+	}
+	for _, evento := range NotificationEvents {
+		settings.Preferencias[evento] = domain.CanalApp
+	}
+	for _, preference := range preferences {
+		settings.Preferencias[preference.Evento] = preference.Canal
+	}
+	return settings, nil
+}
+
+func (s *notificationService) pushRead(userID uint, ids []uint, unread int64) {
+	event := NotificationsReadEvent{IDs: ids, UnreadCount: unread}
+	if err := s.pusher.SendToUser(userID, EventNotificationsRead, event); err != nil {
+		log.Printf("Failed to push read notifications of user %d: %v", userID, err)
+	}
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultNotificationPage
+	}
+	return min(limit, maxNotificationPage)
+}
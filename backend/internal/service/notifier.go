@@ -0,0 +1,154 @@
+package service
+
+import (
+	"log"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// Notifier stores notifications for users and pushes them to connected clients
+type Notifier interface {
+	// NotifyRole sends a copy of the notification to every user with the role
+	NotifyRole(rol string, notification domain.UserNotification) error
+	// NotifyUsers sends a copy of the notification to each of the users
+	NotifyUsers(userIDs []uint, notification domain.UserNotification) error
+	// Channels returns the channels users can route notifications to
+	Channels() []string
+}
+
+// notifier routes each notification through the channel the user picked for
+// its event. During a user's quiet hours nothing is pushed: in-app
+// notifications wait in the center, and senders hold their deliveries until
+// the window ends.
+type notifier struct {
+	notificationRepo repository.NotificationRepository
+	preferenceRepo   repository.NotificationPreferenceRepository
+	userRepo         repository.UserRepository
+	pusher           UserPusher
+	senders          map[string]ChannelSender
+	location         *time.Location
+}
+
+func NewNotifier(notificationRepo repository.NotificationRepository, preferenceRepo repository.NotificationPreferenceRepository, userRepo repository.UserRepository, pusher UserPusher, senders map[string]ChannelSender, location *time.Location) Notifier {
+	return &notifier{
+		notificationRepo: notificationRepo,
+		preferenceRepo:   preferenceRepo,
+		userRepo:         userRepo,
+		pusher:           pusher,
+		senders:          senders,
+		location:         location,
+	}
+}
+
+func (n *notifier) NotifyRole(rol string, notification domain.UserNotification) error {
+	users, err := n.userRepo.ListByRol(rol)
+	if err != nil {
+		return err
+	}
+
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	return n.NotifyUsers(userIDs, notification)
+}
+
+func (n *notifier) Channels() []string {
+	channels := []string{domain.CanalApp}
+	if _, ok := n.senders[domain.CanalEmail]; ok {
+		channels = append(channels, domain.CanalEmail)
+	}
+	return append(channels, domain.CanalNinguno)
+}
+
+// NotifyUsers stores the in-app notifications and pushes each one only to the
+// connections of the user it is for; other channels get theirs from a sender
+func (n *notifier) NotifyUsers(userIDs []uint, notification domain.UserNotification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	channels, quiet, err := n.route(userIDs, notification.Type, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var inApp []domain.UserNotification
+	for _, userID := range userIDs {
+		canal := channels[userID]
+		if canal == domain.CanalNinguno {
+			continue
+		}
+		if canal != domain.CanalApp && n.send(userID, canal, notification) {
+			continue
+		}
+		entry := notification
+		entry.UserID = userID
+		entry.Canal = domain.CanalApp
+		inApp = append(inApp, entry)
+	}
+	if len(inApp) == 0 {
+		return nil
+	}
+	if err := n.notificationRepo.Create(inApp); err != nil {
+		return err
+	}
+
+	for _, created := range inApp {
+		if quiet[created.UserID] {
+			continue
+		}
+		if err := n.pusher.SendToUser(created.UserID, EventNotification, created); err != nil {
+			log.Printf("Failed to push notification %d: %v", created.ID, err)
+		}
+	}
+	return nil
+}
+
+// route returns each user's channel for the notification type and which
+// users are inside their quiet hours
+func (n *notifier) route(userIDs []uint, tipo string, now time.Time) (map[uint]string, map[uint]bool, error) {
+	channels := make(map[uint]string, len(userIDs))
+	for _, userID := range userIDs {
+		channels[userID] = domain.CanalApp
+	}
+	if evento, ok := notificationEvent[tipo]; ok {
+		preferences, err := n.preferenceRepo.ListByUsers(userIDs, evento)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, preference := range preferences {
+			channels[preference.IDUsuario] = preference.Canal
+		}
+	}
+
+	windows, err := n.preferenceRepo.ListQuietHours(userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	quiet := make(map[uint]bool)
+	for _, window := range windows {
+		quiet[window.IDUsuario] = inQuietHours(window, now, n.location)
+	}
+	return channels, quiet, nil
+}
+
+// send hands the notification to a sender and reports whether it took it;
+// when it didn't, the caller falls back to the in-app center
+func (n *notifier) send(userID uint, canal string, notification domain.UserNotification) bool {
+	sender, ok := n.senders[canal]
+	if !ok {
+		return false
+	}
+	user, err := n.userRepo.GetByID(userID)
+	if err == nil {
+		notification.UserID = userID
+		err = sender.Send(user, notification)
+	}
+	if err != nil {
+		log.Printf("Failed to send %s notification to user %d, keeping it in-app: %v", canal, userID, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// ErrOrderClaimed is returned when another user is already working on the order
+var ErrOrderClaimed = errors.New("order is being worked on by another user")
+
+// Reasons attached to claim events
+const (
+	ClaimReasonClaimed  = "claimed"
+	ClaimReasonOverride = "override"
+	ClaimReasonReleased = "released"
+	ClaimReasonExpired  = "expired"
+)
+
+// ClaimEvent is published whenever an order is claimed or released
+type ClaimEvent struct {
+	OrderID                 uint       `json:"order_id"`
+	NumeroOP                string     `json:"numero_op"`
+	UsuarioTrabajandoID     *uint      `json:"usuario_trabajando_id"`
+	UsuarioTrabajandoNombre *string    `json:"usuario_trabajando_nombre"`
+	TimestampInicioTrabajo  *time.Time `json:"timestamp_inicio_trabajo"`
+	Reason                  string     `json:"reason"`
+	ByUserID                *uint      `json:"by_user_id,omitempty"`
+}
+
+// ClaimOrder marks the order as being worked on by the user. Claiming an order
+// the user already holds renews the claim, which is how clients keep it from
+// expiring. Taking over someone else's live claim requires override, which needs
+// the claim override permission.
+func (s *orderService) ClaimOrder(orderID, userID uint, override bool) (*domain.Order, error) {
+	user, err := s.authorize(userID, authz.PermOrderClaim)
+	if err != nil {
+		return nil, err
+	}
+	if override {
+		if err := authz.Check(user.Rol, authz.PermOrderClaimOverride); err != nil {
+			return nil, err
+		}
+	}
+
+	reason := ClaimReasonClaimed
+	if override {
+		reason = ClaimReasonOverride
+	}
+
+	now := time.Now()
+	var claimed bool
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		claimed, err = repos.Orders.Claim(orderID, user.ID, user.Nombre, now, now.Add(-s.claimTimeout), override)
+		if err != nil || !claimed {
+			return err
+		}
+		return recordClaim(repos, EventOrderClaimed, orderID, reason, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	wake(s.events)
+
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return order, ErrOrderClaimed
+	}
+	return order, nil
+}
+
+// ReleaseOrder clears the claim on an order. The holder can always release it;
+// other users only when the claim has expired or with an admin override.
+func (s *orderService) ReleaseOrder(orderID, userID uint, override bool) (*domain.Order, error) {
+	user, err := s.authorize(userID, authz.PermOrderClaim)
+	if err != nil {
+		return nil, err
+	}
+	if override {
+		if err := authz.Check(user.Rol, authz.PermOrderClaimOverride); err != nil {
+			return nil, err
+		}
+	}
+
+	reason := ClaimReasonReleased
+	if override {
+		reason = ClaimReasonOverride
+	}
+
+	var released bool
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		released, err = repos.Orders.Release(orderID, user.ID, time.Now().Add(-s.claimTimeout), override)
+		if err != nil || !released {
+			return err
+		}
+		return recordClaim(repos, EventOrderReleased, orderID, reason, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	wake(s.events)
+
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !released && order.UsuarioTrabajandoID != nil {
+		return order, ErrOrderClaimed
+	}
+	// Otherwise released, or there was nothing to release
+	return order, nil
+}
+
+// ExpireClaims releases every claim that has been idle longer than the claim
+// timeout and returns how many were released
+func (s *orderService) ExpireClaims() (int, error) {
+	var orders []domain.Order
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if orders, err = repos.Orders.ReleaseStale(time.Now().Add(-s.claimTimeout)); err != nil {
+			return err
+		}
+		for _, order := range orders {
+			if err := recordClaim(repos, EventOrderReleased, order.ID, ClaimReasonExpired, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(orders) > 0 {
+		wake(s.events)
+	}
+	return len(orders), nil
+}
+
+// recordClaim publishes the claim as the order now has it. It runs after the
+// claim change, in the same unit of work.
+func recordClaim(repos *repository.Repositories, eventType string, orderID uint, reason string, byUserID uint) error {
+	order, err := repos.Orders.GetByIDForUpdate(orderID)
+	if err != nil {
+		return err
+	}
+
+	event := ClaimEvent{
+		OrderID:                 order.ID,
+		NumeroOP:                order.NumeroOP,
+		UsuarioTrabajandoID:     order.UsuarioTrabajandoID,
+		UsuarioTrabajandoNombre: order.UsuarioTrabajandoNombre,
+		TimestampInicioTrabajo:  order.TimestampInicioTrabajo,
+		Reason:                  reason,
+	}
+	if byUserID != 0 {
+		event.ByUserID = &byUserID
+	}
+	return recordEvent(repos, domain.AgregadoOrden, order.ID, eventType, event)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// OrderEvent is published when an order is created, edited, moved or deleted
+type OrderEvent struct {
+	OrderID          uint      `json:"order_id"`
+	NumeroOP         string    `json:"numero_op"`
+	Cliente          string    `json:"cliente"`
+	Estado           string    `json:"estado"`
+	EstadoAnterior   *string   `json:"estado_anterior,omitempty"` // only on state changes
+	Posicion         int       `json:"posicion"`
+	FechaEntrega     time.Time `json:"fecha_entrega"`
+	OperarioAsignado string    `json:"operario_asignado"`
+	Reasignada       bool      `json:"reasignada,omitempty"` // an edit that changed OperarioAsignado
+	IDUsuarioCreador *uint     `json:"id_usuario_creador"`
+	ByUserID         uint      `json:"by_user_id"`
+	ByUserNombre     string    `json:"by_user_nombre"`
+}
+
+func newOrderEvent(order *domain.Order, previous *string, user *domain.User) OrderEvent {
+	return OrderEvent{
+		OrderID:          order.ID,
+		NumeroOP:         order.NumeroOP,
+		Cliente:          order.Cliente,
+		Estado:           order.Estado,
+		EstadoAnterior:   previous,
+		Posicion:         order.Posicion,
+		FechaEntrega:     order.FechaEntrega,
+		OperarioAsignado: order.OperarioAsignado,
+		IDUsuarioCreador: order.IDUsuarioCreador,
+		ByUserID:         user.ID,
+		ByUserNombre:     user.Nombre,
+	}
+}
+
+func recordOrderEvent(repos *repository.Repositories, eventType string, event OrderEvent) error {
+	return recordEvent(repos, domain.AgregadoOrden, event.OrderID, eventType, event)
+}
+
+type orderNotifications struct {
+	notifier Notifier
+	userRepo repository.UserRepository
+}
+
+// NewOrderNotifications turns order events into notifications for the
+// operator an order is assigned to and the people following its state
+func NewOrderNotifications(notifier Notifier, userRepo repository.UserRepository) EventSubscriber {
+	return &orderNotifications{notifier: notifier, userRepo: userRepo}
+}
+
+func (s *orderNotifications) HandleEvent(event Event) error {
+	switch event.Type {
+	case EventOrderCreated, EventOrderUpdated, EventOrderStateChanged:
+	default:
+		return nil
+	}
+
+	var order OrderEvent
+	if err := json.Unmarshal(event.Data, &order); err != nil {
+		return err
+	}
+	switch {
+	case event.Type == EventOrderCreated, event.Type == EventOrderUpdated && order.Reasignada:
+		return s.notifyAssignment(order)
+	case event.Type == EventOrderStateChanged && order.EstadoAnterior != nil:
+		return s.notifyStateChange(order, *order.EstadoAnterior)
+	}
+	return nil
+}
+
+// notifyAssignment tells the operator named in OperarioAsignado that the order
+// is now theirs
+func (s *orderNotifications) notifyAssignment(order OrderEvent) error {
+	operator := s.assignedUser(order)
+	if operator == nil || operator.ID == order.ByUserID {
+		return nil
+	}
+
+	description := order.Cliente
+	return s.notifier.NotifyUsers([]uint{operator.ID}, domain.UserNotification{
+		Title:       order.ByUserNombre + " te asignó " + order.NumeroOP,
+		Description: &description,
+		Type:        domain.NotificacionAsignacion,
+		OrdenID:     &order.OrderID,
+	})
+}
+
+// notifyStateChange tells the order's creator and assigned operator that it
+// moved, unless they moved it themselves
+func (s *orderNotifications) notifyStateChange(order OrderEvent, from string) error {
+	var userIDs []uint
+	if order.IDUsuarioCreador != nil && *order.IDUsuarioCreador != order.ByUserID {
+		if creator, err := s.userRepo.GetByID(*order.IDUsuarioCreador); err == nil && authz.Can(creator.Rol, authz.PermOrderView) {
+			userIDs = append(userIDs, creator.ID)
+		}
+	}
+	if operator := s.assignedUser(order); operator != nil && operator.ID != order.ByUserID {
+		if len(userIDs) == 0 || userIDs[0] != operator.ID {
+			userIDs = append(userIDs, operator.ID)
+		}
+	}
+
+	description := from + " → " + order.Estado + " (" + order.ByUserNombre + ")"
+	return s.notifier.NotifyUsers(userIDs, domain.UserNotification{
+		Title:       order.NumeroOP + " pasó a " + order.Estado,
+		Description: &description,
+		Type:        domain.NotificacionCambioEstado,
+		OrdenID:     &order.OrderID,
+	})
+}
+
+// assignedUser resolves OperarioAsignado, a free-text name, to a user who can
+// see the order; nil when nobody matches
+func (s *orderNotifications) assignedUser(order OrderEvent) *domain.User {
+	nombre := strings.TrimSpace(order.OperarioAsignado)
+	if nombre == "" || strings.HasPrefix(nombre, domain.AnonymousNamePrefix) {
+		return nil
+	}
+	user, err := s.userRepo.GetByNombre(nombre)
+	if err != nil || !authz.Can(user.Rol, authz.PermOrderView) {
+		return nil
+	}
+	return user
+}
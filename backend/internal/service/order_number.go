@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"task-board/internal/repository"
+	"time"
+)
+
+// maxNumberSkips bounds how many taken numbers an allocation skips over
+const maxNumberSkips = 10000
+
+var seqToken = regexp.MustCompile(`\{SEQ(?::(\d+))?\}`)
+
+// OrderNumberFormat renders NumeroOP values from a pattern such as "OP-{YYYY}-{SEQ:5}".
+//
+// Supported tokens:
+//
+//	{YYYY} four-digit year    {YY} two-digit year    {MM} two-digit month
+//	{SEQ}  sequence number    {SEQ:n} sequence zero-padded to n digits
+//
+// The sequence restarts for every distinct rendering of the rest of the pattern,
+// so a yearly prefix gives a yearly sequence.
+type OrderNumberFormat struct {
+	pattern string
+	width   int
+}
+
+// NewOrderNumberFormat validates a pattern; it must contain exactly one {SEQ} token
+func NewOrderNumberFormat(pattern string) (*OrderNumberFormat, error) {
+	matches := seqToken.FindAllStringSubmatch(pattern, -1)
+	if len(matches) != 1 {
+		return nil, errors.New("order number pattern must contain exactly one {SEQ} token")
+	}
+
+	width := 0
+	if matches[0][1] != "" {
+		width, _ = strconv.Atoi(matches[0][1])
+		if width > 12 {
+			return nil, errors.New("order number sequence width is at most 12")
+		}
+	}
+
+	return &OrderNumberFormat{pattern: pattern, width: width}, nil
+}
+
+// Scope returns the pattern with its dates filled in; it identifies the sequence
+func (f *OrderNumberFormat) Scope(now time.Time) string {
+	return strings.NewReplacer(
+		"{YYYY}", now.Format("2006"),
+		"{YY}", now.Format("06"),
+		"{MM}", now.Format("01"),
+	).Replace(f.pattern)
+}
+
+// Render returns the order number for a sequence value
+func (f *OrderNumberFormat) Render(now time.Time, seq int) string {
+	return seqToken.ReplaceAllLiteralString(f.Scope(now), fmt.Sprintf("%0*d", f.width, seq))
+}
+
+// allocate hands out the next free number in the current scope. It must run
+// inside a unit of work so that the number is only consumed if the order is
+// actually created. Numbers used by imported orders or reserved are skipped.
+func (f *OrderNumberFormat) allocate(numbers repository.OrderNumberRepository, now time.Time) (string, error) {
+	scope := f.Scope(now)
+	for i := 0; i < maxNumberSkips; i++ {
+		seq, err := numbers.NextValue(scope)
+		if err != nil {
+			return "", err
+		}
+
+		numero := f.Render(now, seq)
+		taken, err := numbers.IsTaken(numero)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return numero, nil
+		}
+	}
+	return "", fmt.Errorf("no free order number found in scope %q", scope)
+}
@@ -0,0 +1,571 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"task-board/pkg/storage"
+	"time"
+)
+
+// OrderInput carries the editable fields of a work order
+type OrderInput struct {
+	NumeroOP            string
+	Cliente             string
+	Descripcion         string
+	FechaEntrega        time.Time
+	Prioridad           string
+	OperarioAsignado    string
+	Complejidad         string
+	Sector              string
+	HoraEstimadaEntrega *string
+	HoraEntregaEfectiva *string
+}
+
+// BoardColumn is one Estado column of the production board, with its orders by position
+type BoardColumn struct {
+	Estado string         `json:"estado"`
+	Orders []domain.Order `json:"orders"`
+}
+
+// maxMoveAttempts bounds the retries when an order changes column while a move waits for its locks
+const maxMoveAttempts = 3
+
+// errColumnChanged signals that the order left the column a move had locked
+var errColumnChanged = errors.New("order changed column")
+
+type OrderService interface {
+	CreateOrder(userID uint, input OrderInput) (*domain.Order, error)
+	GetOrders(userID uint, filter repository.OrderFilter) ([]domain.Order, error)
+	GetOrder(orderID, userID uint) (*domain.Order, error)
+	UpdateOrder(orderID, userID uint, input OrderInput) (*domain.Order, error)
+	DeleteOrder(orderID, userID uint) error
+	ChangeState(orderID, userID uint, estado string, comentario *string) (*domain.Order, error)
+	MoveOrder(orderID, userID uint, estado string, posicion int, comentario *string) (*domain.Order, error)
+	GetBoard(userID uint) ([]BoardColumn, error)
+	GetHistory(orderID, userID uint) ([]domain.MovementHistory, error)
+	GetWorkflow() []WorkflowState
+	ClaimOrder(orderID, userID uint, override bool) (*domain.Order, error)
+	ReleaseOrder(orderID, userID uint, override bool) (*domain.Order, error)
+	ExpireClaims() (int, error)
+	ReserveNumbers(userID uint, numeros []string, motivo *string) (int64, error)
+}
+
+type orderService struct {
+	orderRepo repository.OrderRepository
+	userRepo  repository.UserRepository
+	uow       repository.UnitOfWork
+	workflow  *Workflow
+
+	events       EventWaker
+	claimTimeout time.Duration
+	numberFormat *OrderNumberFormat
+	numberRepo   repository.OrderNumberRepository
+	notifier     Notifier
+	files        storage.Storage
+}
+
+func NewOrderService(orderRepo repository.OrderRepository, userRepo repository.UserRepository, numberRepo repository.OrderNumberRepository, uow repository.UnitOfWork, workflow *Workflow, numberFormat *OrderNumberFormat, events EventWaker, notifier Notifier, files storage.Storage, claimTimeout time.Duration) OrderService {
+	return &orderService{
+		orderRepo:    orderRepo,
+		userRepo:     userRepo,
+		numberRepo:   numberRepo,
+		uow:          uow,
+		workflow:     workflow,
+		numberFormat: numberFormat,
+		events:       events,
+		notifier:     notifier,
+		files:        files,
+		claimTimeout: claimTimeout,
+	}
+}
+
+func (s *orderService) CreateOrder(userID uint, input OrderInput) (*domain.Order, error) {
+	if err := validateOrderInput(&input); err != nil {
+		return nil, err
+	}
+
+	user, err := s.authorize(userID, authz.PermOrderCreate)
+	if err != nil {
+		return nil, err
+	}
+	// Bringing your own number is reserved for legacy imports
+	if input.NumeroOP != "" {
+		if err := authz.Check(user.Rol, authz.PermOrderReserveNumbers); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	estado := s.workflow.States()[0]
+	order := &domain.Order{
+		Estado:           estado,
+		FechaCreacion:    now,
+		FechaIngreso:     now,
+		IDUsuarioCreador: &userID,
+	}
+	applyOrderInput(order, input)
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Orders.LockColumns(estado); err != nil {
+			return err
+		}
+		column, err := repos.Orders.GetColumn(estado)
+		if err != nil {
+			return err
+		}
+		order.Posicion = len(column)
+
+		// Generate the number unless the client brought one (e.g. a legacy import)
+		if order.NumeroOP == "" {
+			numero, err := s.numberFormat.allocate(repos.OrderNumbers, now)
+			if err != nil {
+				return err
+			}
+			order.NumeroOP = numero
+		} else {
+			taken, err := repos.OrderNumbers.IsTaken(order.NumeroOP)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("%w: %q", repository.ErrNumeroOPTaken, order.NumeroOP)
+			}
+		}
+
+		if err := repos.Orders.Create(order); err != nil {
+			return err
+		}
+
+		// The initial entry gives the first transition a starting point
+		err = repos.Orders.CreateHistory(&domain.MovementHistory{
+			IDOrden:       order.ID,
+			IDUsuario:     user.ID,
+			NombreUsuario: user.Nombre,
+			EstadoNuevo:   &estado,
+			Timestamp:     now,
+		})
+		if err != nil {
+			return err
+		}
+		return recordOrderEvent(repos, EventOrderCreated, newOrderEvent(order, nil, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	wake(s.events)
+
+	return s.orderRepo.GetByID(order.ID)
+}
+
+func (s *orderService) GetOrders(userID uint, filter repository.OrderFilter) ([]domain.Order, error) {
+	if _, err := s.authorize(userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.List(filter)
+}
+
+func (s *orderService) GetOrder(orderID, userID uint) (*domain.Order, error) {
+	if _, err := s.authorize(userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetByID(orderID)
+}
+
+func (s *orderService) UpdateOrder(orderID, userID uint, input OrderInput) (*domain.Order, error) {
+	if err := validateOrderInput(&input); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !authz.Can(user.Rol, authz.PermOrderEdit) && !authz.Can(user.Rol, authz.PermOrderEditDelivery) {
+		return nil, authz.Check(user.Rol, authz.PermOrderEdit)
+	}
+
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.NumeroOP == "" {
+		input.NumeroOP = order.NumeroOP
+	}
+	// Roles limited to delivery fields may resend the rest of the order, but not change it
+	if !authz.Can(user.Rol, authz.PermOrderEdit) && changesNonDeliveryFields(order, input) {
+		return nil, fmt.Errorf("%w: role %q may only edit delivery fields", authz.ErrForbidden, user.Rol)
+	}
+	reassigned := strings.TrimSpace(input.OperarioAsignado) != strings.TrimSpace(order.OperarioAsignado)
+	renumbered := input.NumeroOP != order.NumeroOP
+	applyOrderInput(order, input)
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if renumbered {
+			taken, err := repos.OrderNumbers.IsTaken(order.NumeroOP)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("%w: %q", repository.ErrNumeroOPTaken, order.NumeroOP)
+			}
+		}
+		if err := repos.Orders.Update(order); err != nil {
+			return err
+		}
+		// The state and position don't come from the edit; take them as committed
+		updated, err := repos.Orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		event := newOrderEvent(updated, nil, user)
+		event.Reasignada = reassigned
+		return recordOrderEvent(repos, EventOrderUpdated, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	wake(s.events)
+
+	return s.orderRepo.GetByID(orderID)
+}
+
+func (s *orderService) DeleteOrder(orderID, userID uint) error {
+	user, err := s.authorize(userID, authz.PermOrderDelete)
+	if err != nil {
+		return err
+	}
+
+	low := lowStockSet{}
+	var deleted *domain.Order
+	var attachments []domain.Attachment
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		if deleted, err = repos.Orders.GetByIDForUpdate(orderID); err != nil {
+			return err
+		}
+		// Hand back what the order reserved; consumed stock stays consumed
+		if err := syncOrderStock(repos, low, orderID, "", &userID); err != nil {
+			return err
+		}
+		if attachments, err = repos.Attachments.ListByOrder(orderID); err != nil {
+			return err
+		}
+		if err := repos.Orders.Delete(orderID); err != nil {
+			return err
+		}
+		return recordOrderEvent(repos, EventOrderDeleted, newOrderEvent(deleted, nil, user))
+	})
+	if err != nil {
+		return err
+	}
+	wake(s.events)
+
+	// The files go once the rows are gone for good
+	deleteStoredFiles(s.files, attachments)
+	return nil
+}
+
+// ChangeState is the only way an order changes Estado: it checks the transition
+// against the workflow and records it in the movement history. The order goes
+// to the end of its new column.
+func (s *orderService) ChangeState(orderID, userID uint, estado string, comentario *string) (*domain.Order, error) {
+	return s.move(orderID, userID, estado, -1, comentario, false)
+}
+
+// MoveOrder places an order at a position of an Estado column. Moving to another
+// column goes through the same transition checks and history as ChangeState.
+func (s *orderService) MoveOrder(orderID, userID uint, estado string, posicion int, comentario *string) (*domain.Order, error) {
+	if posicion < 0 {
+		return nil, errors.New("posicion must be zero or greater")
+	}
+	return s.move(orderID, userID, estado, posicion, comentario, true)
+}
+
+// move runs a state change and/or reorder in one transaction. A negative
+// position appends the order to the end of the column.
+func (s *orderService) move(orderID, userID uint, estado string, posicion int, comentario *string, allowReorder bool) (*domain.Order, error) {
+	if !s.workflow.HasState(estado) {
+		return nil, fmt.Errorf("unknown estado %q", estado)
+	}
+
+	user, err := s.authorize(userID, authz.PermOrderChangeState)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxMoveAttempts; attempt++ {
+		currentEstado, err := s.orderRepo.GetEstado(orderID)
+		if err != nil {
+			return nil, err
+		}
+
+		low := lowStockSet{}
+		err = s.uow.Do(func(repos *repository.Repositories) error {
+			// Column locks come before the row lock, in the same order for
+			// every move, so concurrent drags queue up instead of deadlocking
+			if err := repos.Orders.LockColumns(currentEstado, estado); err != nil {
+				return err
+			}
+			order, err := repos.Orders.GetByIDForUpdate(orderID)
+			if err != nil {
+				return err
+			}
+			if order.Estado != currentEstado {
+				return errColumnChanged
+			}
+
+			source := order.Estado
+			if source == estado && !allowReorder {
+				return fmt.Errorf("%w: order is already in %q", ErrInvalidTransition, estado)
+			}
+			if source != estado {
+				if err := s.transition(repos, order, user, estado, comentario, time.Now()); err != nil {
+					return err
+				}
+				if err := syncOrderStock(repos, low, order.ID, estado, &user.ID); err != nil {
+					return err
+				}
+			}
+
+			if err := placeInColumn(repos.Orders, order.ID, source, estado, posicion); err != nil {
+				return err
+			}
+			moved, err := repos.Orders.GetByIDForUpdate(order.ID)
+			if err != nil {
+				return err
+			}
+			if source != estado {
+				return recordOrderEvent(repos, EventOrderStateChanged, newOrderEvent(moved, &source, user))
+			}
+			return recordOrderEvent(repos, EventOrderMoved, newOrderEvent(moved, nil, user))
+		})
+		if errors.Is(err, errColumnChanged) {
+			// Someone moved the order between the read and the lock; retry
+			// with the column it is in now
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		wake(s.events)
+		notifyLowStock(s.notifier, low)
+		return s.orderRepo.GetByID(orderID)
+	}
+
+	return nil, errors.New("order is being moved by someone else, try again")
+}
+
+// placeInColumn removes an order from its source column and inserts it into the
+// target column at posicion, renumbering both columns densely from zero.
+func placeInColumn(orders repository.OrderRepository, orderID uint, source, target string, posicion int) error {
+	if source != target {
+		sourceIDs, err := orders.GetColumn(source)
+		if err != nil {
+			return err
+		}
+		if err := orders.SetPositions(sourceIDs); err != nil {
+			return err
+		}
+	}
+
+	targetIDs, err := orders.GetColumn(target)
+	if err != nil {
+		return err
+	}
+	return orders.SetPositions(insertAt(targetIDs, orderID, posicion))
+}
+
+func (s *orderService) GetBoard(userID uint) ([]BoardColumn, error) {
+	if _, err := s.authorize(userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+
+	orders, err := s.orderRepo.ListBoard()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]BoardColumn, 0, len(s.workflow.States()))
+	index := make(map[string]int)
+	for _, estado := range s.workflow.States() {
+		index[estado] = len(columns)
+		columns = append(columns, BoardColumn{Estado: estado, Orders: []domain.Order{}})
+	}
+	for _, order := range orders {
+		i, ok := index[order.Estado]
+		if !ok {
+			// Legacy states outside the workflow still get a column
+			i = len(columns)
+			index[order.Estado] = i
+			columns = append(columns, BoardColumn{Estado: order.Estado, Orders: []domain.Order{}})
+		}
+		columns[i].Orders = append(columns[i].Orders, order)
+	}
+
+	return columns, nil
+}
+
+// transition moves a locked order to a new state and writes the history entry.
+// It must run inside a unit of work.
+func (s *orderService) transition(repos *repository.Repositories, order *domain.Order, user *domain.User, estado string, comentario *string, now time.Time) error {
+	if order.Estado == estado {
+		return fmt.Errorf("%w: order is already in %q", ErrInvalidTransition, estado)
+	}
+	if !s.workflow.CanTransition(order.Estado, estado) {
+		return fmt.Errorf("%w: %q -> %q", ErrInvalidTransition, order.Estado, estado)
+	}
+	if s.workflow.RequiresChecklist(estado) {
+		// Checklist changes lock the order row too, so this count holds
+		// until the transaction ends
+		progress, err := repos.Checklist.Progress(order.ID)
+		if err != nil {
+			return err
+		}
+		if open := progress.Open(); open > 0 {
+			return fmt.Errorf("%w: %d of %d tasks open, %q requires a finished checklist", ErrChecklistOpen, open, progress.Total, estado)
+		}
+	}
+
+	// The previous state started with the last history entry, or when the
+	// order came in if it predates history tracking
+	since := order.FechaIngreso
+	last, err := repos.Orders.GetLastHistory(order.ID)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		since = last.Timestamp
+	}
+	duracion := int(now.Sub(since).Seconds())
+	if duracion < 0 {
+		duracion = 0
+	}
+
+	anterior := order.Estado
+	entry := &domain.MovementHistory{
+		IDOrden:                   order.ID,
+		IDUsuario:                 user.ID,
+		NombreUsuario:             user.Nombre,
+		EstadoAnterior:            &anterior,
+		EstadoNuevo:               &estado,
+		DuracionEstadoAnteriorSeg: &duracion,
+		Timestamp:                 now,
+		Comentario:                comentario,
+	}
+	if err := repos.Orders.CreateHistory(entry); err != nil {
+		return err
+	}
+
+	if err := repos.Orders.UpdateEstado(order.ID, estado); err != nil {
+		return err
+	}
+	order.Estado = estado
+
+	return nil
+}
+
+func (s *orderService) GetHistory(orderID, userID uint) ([]domain.MovementHistory, error) {
+	if _, err := s.authorize(userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetByID(orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetHistory(orderID)
+}
+
+func (s *orderService) GetWorkflow() []WorkflowState {
+	return s.workflow.Definition()
+}
+
+// ReserveNumbers records numbers that generation must never hand out, such as
+// the NumeroOP values of the legacy system. It returns how many were new.
+func (s *orderService) ReserveNumbers(userID uint, numeros []string, motivo *string) (int64, error) {
+	if _, err := s.authorize(userID, authz.PermOrderReserveNumbers); err != nil {
+		return 0, err
+	}
+
+	cleaned := make([]string, 0, len(numeros))
+	for _, numero := range numeros {
+		if numero = strings.TrimSpace(numero); numero != "" {
+			cleaned = append(cleaned, numero)
+		}
+	}
+	return s.numberRepo.Reserve(cleaned, motivo)
+}
+
+func (s *orderService) authorize(userID uint, perm authz.Permission) (*domain.User, error) {
+	return authorizeUser(s.userRepo, userID, perm)
+}
+
+// changesNonDeliveryFields reports whether input differs from the order in any
+// field other than the delivery date and times
+func changesNonDeliveryFields(order *domain.Order, input OrderInput) bool {
+	return order.NumeroOP != input.NumeroOP ||
+		order.Cliente != input.Cliente ||
+		order.Descripcion != input.Descripcion ||
+		order.Prioridad != input.Prioridad ||
+		order.OperarioAsignado != input.OperarioAsignado ||
+		order.Complejidad != input.Complejidad ||
+		order.Sector != input.Sector
+}
+
+func applyOrderInput(order *domain.Order, input OrderInput) {
+	order.NumeroOP = input.NumeroOP
+	order.Cliente = input.Cliente
+	order.Descripcion = input.Descripcion
+	order.FechaEntrega = input.FechaEntrega
+	order.Prioridad = input.Prioridad
+	order.OperarioAsignado = input.OperarioAsignado
+	order.Complejidad = input.Complejidad
+	order.Sector = input.Sector
+	order.HoraEstimadaEntrega = input.HoraEstimadaEntrega
+	order.HoraEntregaEfectiva = input.HoraEntregaEfectiva
+}
+
+// validateOrderInput trims the input, fills defaults and rejects unknown enum values
+func validateOrderInput(input *OrderInput) error {
+	input.NumeroOP = strings.TrimSpace(input.NumeroOP)
+	input.Cliente = strings.TrimSpace(input.Cliente)
+
+	if input.Cliente == "" {
+		return errors.New("cliente is required")
+	}
+	if input.FechaEntrega.IsZero() {
+		return errors.New("fecha_entrega is required")
+	}
+
+	if input.Prioridad == "" {
+		input.Prioridad = domain.PrioridadNormal
+	}
+	if input.Complejidad == "" {
+		input.Complejidad = domain.ComplejidadMedia
+	}
+	if input.Sector == "" {
+		input.Sector = domain.SectorTallerGrafico
+	}
+
+	switch input.Prioridad {
+	case domain.PrioridadAlta, domain.PrioridadNormal, domain.PrioridadBaja:
+	default:
+		return errors.New("invalid prioridad")
+	}
+	switch input.Complejidad {
+	case domain.ComplejidadBaja, domain.ComplejidadMedia, domain.ComplejidadAlta:
+	default:
+		return errors.New("invalid complejidad")
+	}
+	switch input.Sector {
+	case domain.SectorTallerGrafico, domain.SectorMostrador:
+	default:
+		return errors.New("invalid sector")
+	}
+
+	return nil
+}
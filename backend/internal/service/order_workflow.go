@@ -0,0 +1,199 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"task-board/internal/domain"
+)
+
+// ErrInvalidTransition is returned when a state change is not allowed by the workflow
+var ErrInvalidTransition = errors.New("state transition not allowed")
+
+// ErrChecklistOpen is returned when an order with unfinished checklist tasks
+// is moved into a state that requires the checklist to be done
+var ErrChecklistOpen = errors.New("checklist has unfinished tasks")
+
+// WorkflowState is one column of the production flow and the states it may move to.
+// Orders only enter a state with RequiereChecklist once every checklist task is finished.
+type WorkflowState struct {
+	Nombre            string   `json:"nombre"`
+	Siguientes        []string `json:"siguientes"`
+	RequiereChecklist bool     `json:"requiere_checklist,omitempty"`
+}
+
+// Workflow is the transition graph that every order state change is checked against.
+// States keep the order in which they were declared, which is also the column order
+// of the production board.
+type Workflow struct {
+	states      []string
+	transitions map[string]map[string]bool
+	checklist   map[string]bool
+}
+
+// NewWorkflow builds a workflow from an ordered list of states and validates that
+// every transition points to a declared state.
+func NewWorkflow(states []WorkflowState) (*Workflow, error) {
+	if len(states) == 0 {
+		return nil, errors.New("workflow must declare at least one state")
+	}
+
+	w := &Workflow{transitions: make(map[string]map[string]bool), checklist: make(map[string]bool)}
+	for _, state := range states {
+		if state.Nombre == "" {
+			return nil, errors.New("workflow state name is required")
+		}
+		if _, exists := w.transitions[state.Nombre]; exists {
+			return nil, fmt.Errorf("workflow state %q declared twice", state.Nombre)
+		}
+		w.states = append(w.states, state.Nombre)
+		w.transitions[state.Nombre] = make(map[string]bool)
+		w.checklist[state.Nombre] = state.RequiereChecklist
+	}
+
+	for _, state := range states {
+		for _, next := range state.Siguientes {
+			if _, exists := w.transitions[next]; !exists {
+				return nil, fmt.Errorf("workflow state %q points to unknown state %q", state.Nombre, next)
+			}
+			if next == state.Nombre {
+				return nil, fmt.Errorf("workflow state %q cannot transition to itself", state.Nombre)
+			}
+			w.transitions[state.Nombre][next] = true
+		}
+	}
+
+	return w, nil
+}
+
+// LoadWorkflow reads a workflow definition from a JSON file containing a list of
+// {"nombre": ..., "siguientes": [...]} objects.
+func LoadWorkflow(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []WorkflowState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("invalid workflow file %s: %w", path, err)
+	}
+
+	return NewWorkflow(states)
+}
+
+// DefaultWorkflow returns the Plot Center production flow documented in DATABASE-SCHEMA.md
+func DefaultWorkflow() *Workflow {
+	w, err := NewWorkflow([]WorkflowState{
+		{Nombre: domain.EstadoPendiente, Siguientes: []string{
+			domain.EstadoDisenoGrafico, domain.EstadoEnEspera, domain.EstadoImprenta,
+			domain.EstadoTallerImprenta, domain.EstadoTallerGrafico, domain.EstadoMostrador,
+		}},
+		{Nombre: domain.EstadoDisenoGrafico, Siguientes: []string{
+			domain.EstadoDisenoEnProceso, domain.EstadoEnEspera, domain.EstadoPendiente,
+		}},
+		{Nombre: domain.EstadoDisenoEnProceso, Siguientes: []string{
+			domain.EstadoDisenoGrafico, domain.EstadoEnEspera, domain.EstadoImprenta,
+			domain.EstadoTallerImprenta, domain.EstadoTallerGrafico,
+		}},
+		{Nombre: domain.EstadoEnEspera, Siguientes: []string{
+			domain.EstadoPendiente, domain.EstadoDisenoGrafico, domain.EstadoDisenoEnProceso,
+			domain.EstadoImprenta, domain.EstadoTallerImprenta, domain.EstadoTallerGrafico,
+		}},
+		{Nombre: domain.EstadoImprenta, Siguientes: []string{
+			domain.EstadoTallerImprenta, domain.EstadoTallerGrafico, domain.EstadoInstalaciones,
+			domain.EstadoFinalizadoTaller, domain.EstadoEnEspera,
+		}},
+		{Nombre: domain.EstadoTallerImprenta, Siguientes: []string{
+			domain.EstadoTallerGrafico, domain.EstadoInstalaciones, domain.EstadoMetalurgica,
+			domain.EstadoFinalizadoTaller, domain.EstadoEnEspera,
+		}},
+		{Nombre: domain.EstadoTallerGrafico, Siguientes: []string{
+			domain.EstadoImprenta, domain.EstadoTallerImprenta, domain.EstadoInstalaciones,
+			domain.EstadoMetalurgica, domain.EstadoFinalizadoTaller, domain.EstadoEnEspera,
+		}},
+		{Nombre: domain.EstadoInstalaciones, Siguientes: []string{
+			domain.EstadoFinalizadoTaller, domain.EstadoEntregado, domain.EstadoEnEspera,
+		}},
+		{Nombre: domain.EstadoMetalurgica, Siguientes: []string{
+			domain.EstadoTallerGrafico, domain.EstadoInstalaciones, domain.EstadoFinalizadoTaller,
+			domain.EstadoEnEspera,
+		}},
+		{Nombre: domain.EstadoFinalizadoTaller, Siguientes: []string{
+			domain.EstadoAlmacenEntrega, domain.EstadoInstalaciones, domain.EstadoMostrador,
+			domain.EstadoEntregado,
+		}},
+		{Nombre: domain.EstadoAlmacenEntrega, Siguientes: []string{
+			domain.EstadoEntregado, domain.EstadoMostrador,
+		}},
+		{Nombre: domain.EstadoEntregado},
+		{Nombre: domain.EstadoMostrador, Siguientes: []string{
+			domain.EstadoPendiente, domain.EstadoDisenoGrafico, domain.EstadoAlmacenEntrega,
+			domain.EstadoEntregado,
+		}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// States returns the workflow states in declaration order
+func (w *Workflow) States() []string {
+	return append([]string(nil), w.states...)
+}
+
+// HasState reports whether the state is declared in the workflow
+func (w *Workflow) HasState(state string) bool {
+	_, ok := w.transitions[state]
+	return ok
+}
+
+// CanTransition reports whether an order may move from one state to another
+func (w *Workflow) CanTransition(from, to string) bool {
+	return w.transitions[from][to]
+}
+
+// RequiresChecklist reports whether orders need a finished checklist to enter the state
+func (w *Workflow) RequiresChecklist(state string) bool {
+	return w.checklist[state]
+}
+
+// RequireChecklist marks more states as needing a finished checklist, on top
+// of those flagged in the definition
+func (w *Workflow) RequireChecklist(states []string) error {
+	for _, state := range states {
+		if !w.HasState(state) {
+			return fmt.Errorf("unknown workflow state %q", state)
+		}
+	}
+	for _, state := range states {
+		w.checklist[state] = true
+	}
+	return nil
+}
+
+// Next returns the states reachable from the given state, in declaration order
+func (w *Workflow) Next(state string) []string {
+	var next []string
+	for _, candidate := range w.states {
+		if w.transitions[state][candidate] {
+			next = append(next, candidate)
+		}
+	}
+	return next
+}
+
+// Definition returns the workflow in the same shape LoadWorkflow accepts
+func (w *Workflow) Definition() []WorkflowState {
+	definition := make([]WorkflowState, 0, len(w.states))
+	for _, state := range w.states {
+		definition = append(definition, WorkflowState{
+			Nombre:            state,
+			Siguientes:        w.Next(state),
+			RequiereChecklist: w.checklist[state],
+		})
+	}
+	return definition
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+const (
+	// outboxBatchSize is how many events one dispatcher pass claims at a time
+	outboxBatchSize = 100
+	// outboxLease keeps a claimed event away from other dispatchers while the
+	// subscribers handle it
+	outboxLease = time.Minute
+	// outboxFirstRetry doubles after every failed attempt, up to maxOutboxRetryDelay
+	outboxFirstRetry    = time.Second
+	maxOutboxRetryDelay = 5 * time.Minute
+)
+
+// OutboxDispatcher relays the events in the outbox to the subscribers, at
+// least once each and in order within each aggregate
+type OutboxDispatcher interface {
+	EventWaker
+	// Woken fires after Wake, so the dispatch loop can run before its next tick
+	Woken() <-chan struct{}
+
+	// Dispatch relays every event due at now, returning how many were published
+	Dispatch(now time.Time) (int, error)
+	// Purge deletes the events published before the given time
+	Purge(before time.Time) (int64, error)
+}
+
+type outboxDispatcher struct {
+	outboxRepo  repository.OutboxRepository
+	subscribers map[string]EventSubscriber
+	names       []string
+	maxAttempts int
+	wakeup      chan struct{}
+}
+
+// NewOutboxDispatcher relays the outbox to the subscribers by name. The names
+// are stored with each event once its subscriber has it, so they must not
+// change between releases while events are pending.
+func NewOutboxDispatcher(outboxRepo repository.OutboxRepository, subscribers map[string]EventSubscriber, maxAttempts int) OutboxDispatcher {
+	names := make([]string, 0, len(subscribers))
+	for name := range subscribers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &outboxDispatcher{
+		outboxRepo:  outboxRepo,
+		subscribers: subscribers,
+		names:       names,
+		maxAttempts: maxAttempts,
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+func (d *outboxDispatcher) Wake() {
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+		// A dispatch is already due
+	}
+}
+
+func (d *outboxDispatcher) Woken() <-chan struct{} {
+	return d.wakeup
+}
+
+func (d *outboxDispatcher) Dispatch(now time.Time) (int, error) {
+	published := 0
+	for {
+		// Each pass gets the oldest pending event of every aggregate, so an
+		// aggregate with a backlog advances one event per pass
+		events, err := d.outboxRepo.LeaseDue(now, now.Add(outboxLease), outboxBatchSize)
+		if err != nil {
+			return published, err
+		}
+		if len(events) == 0 {
+			return published, nil
+		}
+
+		for i := range events {
+			if d.publish(&events[i], now) {
+				published++
+			}
+		}
+	}
+}
+
+// publish hands the event to the subscribers that don't have it yet and records
+// the outcome. A failed event is retried with backoff and holds back the later
+// events of its aggregate; once it runs out of attempts it is given up on, and
+// they go ahead.
+func (d *outboxDispatcher) publish(event *domain.OutboxEvent, now time.Time) bool {
+	relayed := Event{
+		ID:        event.IDEvento,
+		Type:      event.Evento,
+		Aggregate: event.TipoAgregado,
+		Time:      event.FechaCreacion,
+		Data:      json.RawMessage(event.Payload),
+	}
+	if event.IDUsuarioDestino != nil {
+		relayed.UserID = *event.IDUsuarioDestino
+	}
+
+	var failures []string
+	for _, name := range d.names {
+		if slices.Contains(event.Entregado, name) {
+			continue
+		}
+		if err := d.subscribers[name].HandleEvent(relayed); err != nil {
+			failures = append(failures, name+": "+err.Error())
+			continue
+		}
+		event.Entregado = append(event.Entregado, name)
+	}
+
+	event.Intentos++
+	if len(failures) == 0 {
+		event.Estado = domain.OutboxPublicado
+		event.UltimoError = nil
+		event.FechaPublicacion = &now
+	} else {
+		message := strings.Join(failures, "; ")
+		event.UltimoError = &message
+		if event.Intentos >= d.maxAttempts {
+			event.Estado = domain.OutboxFallido
+			log.Printf("Gave up on outbox event %s (%s %s %d) after %d attempts: %s",
+				event.IDEvento, event.Evento, event.TipoAgregado, event.IDAgregado, event.Intentos, message)
+		} else {
+			event.ProximoIntento = now.Add(outboxRetryDelay(event.Intentos))
+		}
+	}
+
+	if err := d.outboxRepo.UpdateAttempt(event); err != nil {
+		// The lease runs out and the event goes again
+		log.Printf("Failed to record outbox event %s: %v", event.IDEvento, err)
+		return false
+	}
+	return event.Estado == domain.OutboxPublicado
+}
+
+func (d *outboxDispatcher) Purge(before time.Time) (int64, error) {
+	return d.outboxRepo.PurgePublished(before)
+}
+
+// outboxRetryDelay is the wait after the given number of failed attempts
+func outboxRetryDelay(attempts int) time.Duration {
+	if attempts > 9 {
+		return maxOutboxRetryDelay
+	}
+	return min(outboxFirstRetry<<(attempts-1), maxOutboxRetryDelay)
+}
+
+// recordEvent adds an event to the outbox in the transaction of the change it
+// describes, so the event exists if and only if the change commits. Record it
+// after the statement that writes or locks the aggregate's row: concurrent
+// changes to one aggregate then get their events in commit order.
+func recordEvent(repos *repository.Repositories, aggregate string, aggregateID uint, eventType string, data interface{}) error {
+	return recordEventFor(repos, aggregate, aggregateID, 0, eventType, data)
+}
+
+// recordEventFor is recordEvent for an event only the user should get; 0 means
+// every client allowed to see the aggregate
+func recordEventFor(repos *repository.Repositories, aggregate string, aggregateID, userID uint, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	eventID, err := newEventID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	event := &domain.OutboxEvent{
+		IDEvento:       eventID,
+		TipoAgregado:   aggregate,
+		IDAgregado:     aggregateID,
+		Evento:         eventType,
+		Payload:        string(payload),
+		Estado:         domain.OutboxPendiente,
+		ProximoIntento: now,
+		FechaCreacion:  now,
+	}
+	if userID != 0 {
+		event.IDUsuarioDestino = &userID
+	}
+	return repos.Outbox.Create(event)
+}
+
+// newEventID returns a random UUID (version 4)
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
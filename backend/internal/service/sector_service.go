@@ -0,0 +1,492 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+var (
+	// ErrSectorNameTaken is returned when another sector already uses the name
+	ErrSectorNameTaken = errors.New("sector with this nombre already exists")
+	// ErrSectorInUse is returned when deleting a sector that orders are routed through
+	ErrSectorInUse = errors.New("sector is used by orders, deactivate it instead")
+	// ErrSectorNotInRoute is returned when moving an order to a sector outside its route
+	ErrSectorNotInRoute = errors.New("sector is not in the order's route")
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// SectorInput holds the editable fields of a sector
+type SectorInput struct {
+	Nombre            string
+	Color             string
+	Activo            *bool
+	CapacidadHorasDia *float64
+}
+
+// SectorChangeEvent is published whenever an order moves to another sector
+type SectorChangeEvent struct {
+	OrderID        uint    `json:"order_id"`
+	NumeroOP       string  `json:"numero_op"`
+	IDSectorActual *uint   `json:"id_sector_actual"`
+	SectorNombre   *string `json:"sector_nombre"`
+	ByUserID       uint    `json:"by_user_id"`
+}
+
+type SectorService interface {
+	GetSectors(userID uint, includeInactive bool) ([]domain.Sector, error)
+	GetSector(sectorID, userID uint) (*domain.Sector, error)
+	CreateSector(userID uint, input SectorInput) (*domain.Sector, error)
+	UpdateSector(sectorID, userID uint, input SectorInput) (*domain.Sector, error)
+	DeleteSector(sectorID, userID uint) error
+	// ReorderSectors puts the given sectors first, in that order, followed by the rest
+	ReorderSectors(userID uint, ids []uint) ([]domain.Sector, error)
+
+	// Routing of orders through the sectors
+	GetRoute(orderID, userID uint) ([]domain.OrderSector, error)
+	SetRoute(orderID, userID uint, sectorIDs []uint) ([]domain.OrderSector, error)
+	AdvanceRoute(orderID, userID uint) (*domain.Order, error)
+	SetCurrentSector(orderID, userID uint, sectorID *uint) (*domain.Order, error)
+
+	// GetWorkload projects the load of open orders per sector and working day
+	GetWorkload(userID uint, desde time.Time, dias int) (*WorkloadReport, error)
+}
+
+type sectorService struct {
+	sectorRepo repository.SectorRepository
+	orderRepo  repository.OrderRepository
+	userRepo   repository.UserRepository
+	uow        repository.UnitOfWork
+	events     EventWaker
+
+	complexityHours ComplexityHours
+	// location is the shop's time zone, which decides what day today is
+	location *time.Location
+}
+
+func NewSectorService(sectorRepo repository.SectorRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, uow repository.UnitOfWork, events EventWaker, complexityHours ComplexityHours, location *time.Location) SectorService {
+	return &sectorService{
+		sectorRepo:      sectorRepo,
+		orderRepo:       orderRepo,
+		userRepo:        userRepo,
+		uow:             uow,
+		events:          events,
+		complexityHours: complexityHours,
+		location:        location,
+	}
+}
+
+func (s *sectorService) GetSectors(userID uint, includeInactive bool) ([]domain.Sector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	return s.sectorRepo.List(includeInactive)
+}
+
+func (s *sectorService) GetSector(sectorID, userID uint) (*domain.Sector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	return s.sectorRepo.GetByID(sectorID)
+}
+
+func (s *sectorService) CreateSector(userID uint, input SectorInput) (*domain.Sector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermSectorManage); err != nil {
+		return nil, err
+	}
+	if err := s.validateSectorInput(&input, 0); err != nil {
+		return nil, err
+	}
+
+	// New sectors go to the end of the display order
+	sectors, err := s.sectorRepo.List(true)
+	if err != nil {
+		return nil, err
+	}
+
+	sector := &domain.Sector{
+		Nombre:             input.Nombre,
+		Color:              input.Color,
+		Activo:             true,
+		OrdenVisualizacion: len(sectors),
+		CapacidadHorasDia:  input.CapacidadHorasDia,
+	}
+	if err := s.sectorRepo.Create(sector); err != nil {
+		return nil, err
+	}
+
+	// The column defaults to active, so an inactive sector needs a second write
+	if input.Activo != nil && !*input.Activo {
+		sector.Activo = false
+		if err := s.sectorRepo.Update(sector); err != nil {
+			return nil, err
+		}
+	}
+
+	return sector, nil
+}
+
+func (s *sectorService) UpdateSector(sectorID, userID uint, input SectorInput) (*domain.Sector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermSectorManage); err != nil {
+		return nil, err
+	}
+
+	sector, err := s.sectorRepo.GetByID(sectorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateSectorInput(&input, sector.ID); err != nil {
+		return nil, err
+	}
+
+	sector.Nombre = input.Nombre
+	sector.Color = input.Color
+	sector.CapacidadHorasDia = input.CapacidadHorasDia
+	if input.Activo != nil {
+		sector.Activo = *input.Activo
+	}
+	if err := s.sectorRepo.Update(sector); err != nil {
+		return nil, err
+	}
+
+	return sector, nil
+}
+
+func (s *sectorService) DeleteSector(sectorID, userID uint) error {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermSectorManage); err != nil {
+		return err
+	}
+
+	if _, err := s.sectorRepo.GetByID(sectorID); err != nil {
+		return err
+	}
+
+	used, err := s.sectorRepo.IsUsed(sectorID)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrSectorInUse
+	}
+
+	return s.sectorRepo.Delete(sectorID)
+}
+
+func (s *sectorService) ReorderSectors(userID uint, ids []uint) ([]domain.Sector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermSectorManage); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		sectors, err := repos.Sectors.List(true)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[uint]bool, len(sectors))
+		for _, sector := range sectors {
+			known[sector.ID] = true
+		}
+		listed := make(map[uint]bool, len(ids))
+		order := make([]uint, 0, len(sectors))
+		for _, id := range ids {
+			if !known[id] {
+				return fmt.Errorf("unknown sector %d", id)
+			}
+			if listed[id] {
+				return fmt.Errorf("sector %d is listed twice", id)
+			}
+			listed[id] = true
+			order = append(order, id)
+		}
+		for _, sector := range sectors {
+			if !listed[sector.ID] {
+				order = append(order, sector.ID)
+			}
+		}
+
+		return repos.Sectors.SetDisplayOrder(order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sectorRepo.List(true)
+}
+
+func (s *sectorService) GetRoute(orderID, userID uint) ([]domain.OrderSector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if _, err := s.orderRepo.GetEstado(orderID); err != nil {
+		return nil, err
+	}
+	return s.sectorRepo.ListRoute(orderID)
+}
+
+// SetRoute replaces the sectors an order goes through. Sectors that stay in the
+// route keep their arrival and departure times.
+func (s *sectorService) SetRoute(orderID, userID uint, sectorIDs []uint) ([]domain.OrderSector, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderEdit); err != nil {
+		return nil, err
+	}
+
+	var cleared bool
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := repos.Orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		steps, err := repos.Sectors.ListRoute(orderID)
+		if err != nil {
+			return err
+		}
+
+		existing := make(map[uint]domain.OrderSector, len(steps))
+		for _, step := range steps {
+			existing[step.IDSector] = step
+		}
+
+		now := time.Now()
+		keep := make(map[uint]bool, len(sectorIDs))
+		for paso, sectorID := range sectorIDs {
+			if keep[sectorID] {
+				return fmt.Errorf("sector %d is listed twice", sectorID)
+			}
+			keep[sectorID] = true
+
+			if step, ok := existing[sectorID]; ok {
+				step.Paso = paso
+				if err := repos.Sectors.UpdateStep(&step); err != nil {
+					return err
+				}
+				continue
+			}
+
+			sector, err := repos.Sectors.GetByID(sectorID)
+			if err != nil {
+				return fmt.Errorf("sector %d: %w", sectorID, err)
+			}
+			if !sector.Activo {
+				return fmt.Errorf("sector %q is not active", sector.Nombre)
+			}
+			step := &domain.OrderSector{
+				IDOrden:         orderID,
+				IDSector:        sectorID,
+				Paso:            paso,
+				FechaAsignacion: now,
+			}
+			if err := repos.Sectors.CreateStep(step); err != nil {
+				return err
+			}
+		}
+
+		for _, step := range steps {
+			if !keep[step.IDSector] {
+				if err := repos.Sectors.DeleteStep(step.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		// An order can't sit in a sector it is no longer routed through
+		if order.IDSectorActual != nil && !keep[*order.IDSectorActual] {
+			cleared = true
+			if err := repos.Orders.SetSectorActual(orderID, nil); err != nil {
+				return err
+			}
+			return recordSectorChange(repos, orderID, userID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cleared {
+		wake(s.events)
+	}
+	return s.sectorRepo.ListRoute(orderID)
+}
+
+// AdvanceRoute moves the order from its current sector to the next one in its
+// route. An order that isn't in any sector yet enters the first sector it
+// hasn't been through; leaving the last sector completes the route.
+func (s *sectorService) AdvanceRoute(orderID, userID uint) (*domain.Order, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderChangeState); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := repos.Orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		steps, err := repos.Sectors.ListRoute(orderID)
+		if err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return errors.New("order has no route")
+		}
+
+		current := currentStep(steps, order.IDSectorActual)
+		next := -1
+		if current >= 0 {
+			if current+1 < len(steps) {
+				next = current + 1
+			}
+		} else {
+			for i, step := range steps {
+				if step.FechaFin == nil {
+					next = i
+					break
+				}
+			}
+			if next < 0 {
+				return errors.New("order has already completed its route")
+			}
+		}
+
+		if err := moveToStep(repos, order, steps, current, next); err != nil {
+			return err
+		}
+		return recordSectorChange(repos, orderID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wake(s.events)
+	return s.orderRepo.GetByID(orderID)
+}
+
+// SetCurrentSector moves the order straight to a sector of its route, or out of
+// every sector when sectorID is nil
+func (s *sectorService) SetCurrentSector(orderID, userID uint, sectorID *uint) (*domain.Order, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderChangeState); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		order, err := repos.Orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		steps, err := repos.Sectors.ListRoute(orderID)
+		if err != nil {
+			return err
+		}
+
+		next := -1
+		if sectorID != nil {
+			next = currentStep(steps, sectorID)
+			if next < 0 {
+				return ErrSectorNotInRoute
+			}
+		}
+
+		if err := moveToStep(repos, order, steps, currentStep(steps, order.IDSectorActual), next); err != nil {
+			return err
+		}
+		return recordSectorChange(repos, orderID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wake(s.events)
+	return s.orderRepo.GetByID(orderID)
+}
+
+// currentStep returns the index of the step for the sector, or -1
+func currentStep(steps []domain.OrderSector, sectorID *uint) int {
+	if sectorID == nil {
+		return -1
+	}
+	for i, step := range steps {
+		if step.IDSector == *sectorID {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveToStep closes the current step and opens the next one. Either may be -1:
+// no current step, or leaving every sector.
+func moveToStep(repos *repository.Repositories, order *domain.Order, steps []domain.OrderSector, current, next int) error {
+	if current == next {
+		return nil
+	}
+
+	now := time.Now()
+	if current >= 0 {
+		step := steps[current]
+		step.FechaFin = &now
+		if err := repos.Sectors.UpdateStep(&step); err != nil {
+			return err
+		}
+	}
+
+	var sectorID *uint
+	if next >= 0 {
+		step := steps[next]
+		step.FechaInicio = &now
+		step.FechaFin = nil
+		if err := repos.Sectors.UpdateStep(&step); err != nil {
+			return err
+		}
+		sectorID = &step.IDSector
+	}
+
+	return repos.Orders.SetSectorActual(order.ID, sectorID)
+}
+
+// recordSectorChange publishes the order's current sector. It runs after the
+// move, in the same unit of work.
+func recordSectorChange(repos *repository.Repositories, orderID, userID uint) error {
+	order, err := repos.Orders.GetByID(orderID)
+	if err != nil {
+		return err
+	}
+
+	event := SectorChangeEvent{
+		OrderID:        order.ID,
+		NumeroOP:       order.NumeroOP,
+		IDSectorActual: order.IDSectorActual,
+		ByUserID:       userID,
+	}
+	if order.SectorActual != nil {
+		event.SectorNombre = &order.SectorActual.Nombre
+	}
+	return recordEvent(repos, domain.AgregadoOrden, order.ID, EventOrderSectorChanged, event)
+}
+
+// validateSectorInput normalizes the input and checks the name is free.
+// selfID is the sector being updated, or 0 on create.
+func (s *sectorService) validateSectorInput(input *SectorInput, selfID uint) error {
+	input.Nombre = strings.TrimSpace(input.Nombre)
+	if input.Nombre == "" {
+		return errors.New("nombre is required")
+	}
+	if input.Color == "" {
+		input.Color = "#6B7280"
+	}
+	if !colorPattern.MatchString(input.Color) {
+		return errors.New("color must be a hex color like #6B7280")
+	}
+	if input.CapacidadHorasDia != nil && *input.CapacidadHorasDia < 0 {
+		return errors.New("capacidad_horas_dia must be zero or greater")
+	}
+
+	if existing, _ := s.sectorRepo.GetByNombre(input.Nombre); existing != nil && existing.ID != selfID {
+		return ErrSectorNameTaken
+	}
+	return nil
+}
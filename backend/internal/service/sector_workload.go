@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"time"
+)
+
+const (
+	// defaultWorkloadDays is the horizon shown when none is requested
+	defaultWorkloadDays = 14
+	// maxWorkloadDays bounds the horizon of a single projection
+	maxWorkloadDays = 90
+	// dateLayout formats the calendar dates of the projection
+	dateLayout = "2006-01-02"
+)
+
+// workloadClosedEstados are the states whose work no longer loads any sector
+var workloadClosedEstados = []string{
+	domain.EstadoFinalizadoTaller,
+	domain.EstadoAlmacenEntrega,
+	domain.EstadoEntregado,
+}
+
+// ComplexityHours is the work, in hours, an order of each Complejidad takes in
+// every sector of its route
+type ComplexityHours map[string]float64
+
+// NewComplexityHours parses a spec such as "Baja=2,Media=4,Alta=8". Every
+// complejidad must be given.
+func NewComplexityHours(spec string) (ComplexityHours, error) {
+	hours := ComplexityHours{}
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid complexity hours entry %q", part)
+		}
+		name = strings.TrimSpace(name)
+		switch name {
+		case domain.ComplejidadBaja, domain.ComplejidadMedia, domain.ComplejidadAlta:
+		default:
+			return nil, fmt.Errorf("unknown complejidad %q", name)
+		}
+		h, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || h < 0 {
+			return nil, fmt.Errorf("invalid hours for complejidad %s", name)
+		}
+		hours[name] = h
+	}
+
+	for _, name := range []string{domain.ComplejidadBaja, domain.ComplejidadMedia, domain.ComplejidadAlta} {
+		if _, ok := hours[name]; !ok {
+			return nil, fmt.Errorf("missing hours for complejidad %s", name)
+		}
+	}
+	return hours, nil
+}
+
+// For returns the hours of a complejidad; unknown values count as Media
+func (c ComplexityHours) For(complejidad string) float64 {
+	if h, ok := c[complejidad]; ok {
+		return h
+	}
+	return c[domain.ComplejidadMedia]
+}
+
+// WorkloadOrder is an order's share of a sector's load on a day
+type WorkloadOrder struct {
+	IDOrden      uint    `json:"id_orden"`
+	NumeroOP     string  `json:"numero_op"`
+	Complejidad  string  `json:"complejidad"`
+	FechaEntrega string  `json:"fecha_entrega"`
+	Atrasada     bool    `json:"atrasada"` // the delivery date has already passed
+	Horas        float64 `json:"horas"`
+}
+
+// WorkloadDay is the projected load of a sector on one working day
+type WorkloadDay struct {
+	Fecha        string          `json:"fecha"`
+	Horas        float64         `json:"horas"`
+	Capacidad    *float64        `json:"capacidad"`
+	Sobrecargado bool            `json:"sobrecargado"`
+	Ordenes      []WorkloadOrder `json:"ordenes"`
+}
+
+// SectorWorkload is the projection for one sector. A nil Sector holds the
+// orders that aren't routed through any sector.
+type SectorWorkload struct {
+	Sector            *domain.Sector `json:"sector"`
+	HorasTotales      float64        `json:"horas_totales"`
+	DiasSobrecargados int            `json:"dias_sobrecargados"`
+	Dias              []WorkloadDay  `json:"dias"`
+}
+
+// WorkloadReport projects the load of open orders per sector and working day
+type WorkloadReport struct {
+	Desde     string           `json:"desde"`
+	Hasta     string           `json:"hasta"`
+	Sectores  []SectorWorkload `json:"sectores"`
+	SinSector *SectorWorkload  `json:"sin_sector"`
+}
+
+// dateOf drops the clock and location of t, keeping its calendar date
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// isWorkingDay reports whether the shop works on the date (Monday to Friday)
+func isWorkingDay(day time.Time) bool {
+	return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+}
+
+// workingDays lists the working days from start to end, both included
+func workingDays(start, end time.Time) []time.Time {
+	var days []time.Time
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if isWorkingDay(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// pendingSectors returns the sectors an order still has to go through: the
+// steps of its route not yet left, or its current sector when it has no route
+func pendingSectors(order *domain.Order) []uint {
+	var ids []uint
+	for _, step := range order.Sectores {
+		if step.FechaFin == nil {
+			ids = append(ids, step.IDSector)
+		}
+	}
+	if len(order.Sectores) == 0 && order.IDSectorActual != nil {
+		ids = append(ids, *order.IDSectorActual)
+	}
+	return ids
+}
+
+// nextWorkingDay returns the first working day on or after day
+func nextWorkingDay(day time.Time) time.Time {
+	for !isWorkingDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// GetWorkload projects the load of every open order over the working days of
+// the horizon. Each sector still ahead of an order gets the hours of the order's
+// complejidad, spread evenly over the working days from today to its delivery
+// date; orders with no working day left load the first working day of the
+// horizon in full. Days past a sector's capacity are flagged as overbooked.
+func (s *sectorService) GetWorkload(userID uint, desde time.Time, dias int) (*WorkloadReport, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermPlanningView); err != nil {
+		return nil, err
+	}
+	if dias <= 0 {
+		dias = defaultWorkloadDays
+	}
+	if dias > maxWorkloadDays {
+		return nil, fmt.Errorf("dias must be at most %d", maxWorkloadDays)
+	}
+
+	today := dateOf(time.Now().In(s.location))
+	if desde.IsZero() {
+		desde = today
+	}
+	desde = dateOf(desde)
+	hasta := desde.AddDate(0, 0, dias-1)
+	if len(workingDays(desde, hasta)) == 0 {
+		return nil, errors.New("the requested range has no working days")
+	}
+
+	sectors, err := s.sectorRepo.List(true)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.orderRepo.ListOpen(workloadClosedEstados)
+	if err != nil {
+		return nil, err
+	}
+	return projectWorkload(sectors, orders, s.complexityHours, today, desde, hasta), nil
+}
+
+// projectWorkload builds the report for the dates from desde to hasta, which
+// must include a working day
+func projectWorkload(sectors []domain.Sector, orders []domain.Order, complexityHours ComplexityHours, today, desde, hasta time.Time) *WorkloadReport {
+	days := workingDays(desde, hasta)
+	// Work that is due, or overdue, before the next working day is shown on
+	// it, so weekends and past horizons don't hide it
+	firstDay := nextWorkingDay(today)
+	if desde.After(firstDay) {
+		firstDay = days[0]
+	}
+
+	// load[sectorID][date] holds each order's share; sector 0 is "no sector"
+	load := map[uint]map[string][]WorkloadOrder{}
+	for i := range orders {
+		order := &orders[i]
+		hours := complexityHours.For(order.Complejidad)
+		if hours == 0 {
+			continue
+		}
+
+		due := dateOf(order.FechaEntrega)
+		spread := workingDays(today, due)
+		if len(spread) == 0 {
+			spread = []time.Time{firstDay}
+		}
+		share := WorkloadOrder{
+			IDOrden:      order.ID,
+			NumeroOP:     order.NumeroOP,
+			Complejidad:  order.Complejidad,
+			FechaEntrega: due.Format(dateLayout),
+			Atrasada:     due.Before(today),
+			Horas:        hours / float64(len(spread)),
+		}
+
+		targets := pendingSectors(order)
+		if len(targets) == 0 {
+			targets = []uint{0}
+		}
+		for _, sectorID := range targets {
+			if load[sectorID] == nil {
+				load[sectorID] = map[string][]WorkloadOrder{}
+			}
+			for _, day := range spread {
+				if day.Before(desde) || day.After(hasta) {
+					continue
+				}
+				key := day.Format(dateLayout)
+				load[sectorID][key] = append(load[sectorID][key], share)
+			}
+		}
+	}
+
+	report := &WorkloadReport{
+		Desde:    desde.Format(dateLayout),
+		Hasta:    hasta.Format(dateLayout),
+		Sectores: []SectorWorkload{},
+	}
+	for i := range sectors {
+		sector := &sectors[i]
+		// Inactive sectors only show up while orders still need them
+		if !sector.Activo && len(load[sector.ID]) == 0 {
+			continue
+		}
+		report.Sectores = append(report.Sectores, buildSectorWorkload(sector, days, load[sector.ID]))
+	}
+	if len(load[0]) > 0 {
+		unassigned := buildSectorWorkload(nil, days, load[0])
+		report.SinSector = &unassigned
+	}
+	return report
+}
+
+func buildSectorWorkload(sector *domain.Sector, days []time.Time, load map[string][]WorkloadOrder) SectorWorkload {
+	var capacidad *float64
+	if sector != nil {
+		capacidad = sector.CapacidadHorasDia
+	}
+
+	workload := SectorWorkload{Sector: sector, Dias: make([]WorkloadDay, 0, len(days))}
+	for _, day := range days {
+		key := day.Format(dateLayout)
+		entry := WorkloadDay{Fecha: key, Capacidad: capacidad, Ordenes: []WorkloadOrder{}}
+		for _, share := range load[key] {
+			share.Horas = roundHours(share.Horas)
+			entry.Horas += share.Horas
+			entry.Ordenes = append(entry.Ordenes, share)
+		}
+		entry.Horas = roundHours(entry.Horas)
+		entry.Sobrecargado = capacidad != nil && entry.Horas > *capacidad
+
+		workload.HorasTotales += entry.Horas
+		if entry.Sobrecargado {
+			workload.DiasSobrecargados++
+		}
+		workload.Dias = append(workload.Dias, entry)
+	}
+	workload.HorasTotales = roundHours(workload.HorasTotales)
+	return workload
+}
+
+// roundHours rounds to hundredths of an hour
+func roundHours(h float64) float64 {
+	return math.Round(h*100) / 100
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"task-board/internal/domain"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// dayLoad returns the hours and orders a workload has on the date
+func dayLoad(t *testing.T, workload SectorWorkload, fecha string) (float64, []string) {
+	t.Helper()
+	for _, day := range workload.Dias {
+		if day.Fecha == fecha {
+			var ops []string
+			for _, order := range day.Ordenes {
+				ops = append(ops, order.NumeroOP)
+			}
+			return day.Horas, ops
+		}
+	}
+	t.Fatalf("%s is not in the report", fecha)
+	return 0, nil
+}
+
+func TestProjectWorkloadOnWeekend(t *testing.T) {
+	saturday := date(2026, time.March, 14)
+	if saturday.Weekday() != time.Saturday {
+		t.Fatalf("%s is a %s", saturday.Format(dateLayout), saturday.Weekday())
+	}
+	hours := ComplexityHours{domain.ComplejidadBaja: 2, domain.ComplejidadMedia: 4, domain.ComplejidadAlta: 9}
+	capacity := 8.0
+	sectors := []domain.Sector{{ID: 1, Nombre: "Imprenta", Activo: true, CapacidadHorasDia: &capacity}}
+	route := []domain.OrderSector{{IDSector: 1}}
+	orders := []domain.Order{
+		{ID: 1, NumeroOP: "OP-1", Complejidad: domain.ComplejidadBaja, FechaEntrega: date(2026, time.March, 11), Sectores: route},
+		{ID: 2, NumeroOP: "OP-2", Complejidad: domain.ComplejidadBaja, FechaEntrega: saturday, Sectores: route},
+		{ID: 3, NumeroOP: "OP-3", Complejidad: domain.ComplejidadBaja, FechaEntrega: date(2026, time.March, 15), Sectores: route},
+		{ID: 4, NumeroOP: "OP-4", Complejidad: domain.ComplejidadAlta, FechaEntrega: date(2026, time.March, 18), Sectores: route},
+	}
+
+	t.Run("from today", func(t *testing.T) {
+		report := projectWorkload(sectors, orders, hours, saturday, saturday, saturday.AddDate(0, 0, 13))
+		if len(report.Sectores) != 1 {
+			t.Fatalf("got %d sectors, want 1", len(report.Sectores))
+		}
+		workload := report.Sectores[0]
+
+		// Overdue, due today and due tomorrow all land on Monday, with a
+		// third of the order due on Wednesday
+		horas, ops := dayLoad(t, workload, "2026-03-16")
+		if horas != 9 || len(ops) != 4 {
+			t.Errorf("Monday has %v hours of %v, want 9 hours of 4 orders", horas, ops)
+		}
+		if horas, _ := dayLoad(t, workload, "2026-03-18"); horas != 3 {
+			t.Errorf("Wednesday has %v hours, want 3", horas)
+		}
+		if workload.HorasTotales != 15 {
+			t.Errorf("HorasTotales = %v, want every order's 15 hours", workload.HorasTotales)
+		}
+		if workload.DiasSobrecargados != 1 {
+			t.Errorf("DiasSobrecargados = %d, want Monday only", workload.DiasSobrecargados)
+		}
+		for _, day := range workload.Dias[0].Ordenes {
+			if atrasada := day.NumeroOP == "OP-1"; day.Atrasada != atrasada {
+				t.Errorf("%s Atrasada = %v", day.NumeroOP, day.Atrasada)
+			}
+		}
+	})
+
+	t.Run("from a later desde", func(t *testing.T) {
+		tuesday := date(2026, time.March, 17)
+		report := projectWorkload(sectors, orders, hours, saturday, tuesday, tuesday.AddDate(0, 0, 6))
+		horas, ops := dayLoad(t, report.Sectores[0], "2026-03-17")
+		// The late orders move to the first day shown; OP-4 keeps its share
+		if horas != 9 || len(ops) != 4 {
+			t.Errorf("Tuesday has %v hours of %v, want 9 hours of 4 orders", horas, ops)
+		}
+	})
+}
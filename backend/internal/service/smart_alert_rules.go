@@ -0,0 +1,279 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+const (
+	// smartAlertBaseline is how much history the typical time in a state is taken from
+	smartAlertBaseline = 30 * 24 * time.Hour
+	// smartAlertMinSamples is how many state exits a duration statistic needs to be trusted
+	smartAlertMinSamples = 5
+	// smartAlertMaxOrders bounds the orders listed as evidence
+	smartAlertMaxOrders = 20
+)
+
+// SmartAlertRules are the thresholds of the smart alert rules
+type SmartAlertRules struct {
+	// OperatorMaxOrders is how many open orders one operator can carry
+	OperatorMaxOrders int
+	// QueueWindow is the recent period queue growth and efficiency are measured over
+	QueueWindow time.Duration
+	// QueueGrowth is how many more orders entering a state than leaving it,
+	// within QueueWindow, make it a bottleneck
+	QueueGrowth int
+	// SlowdownFactor is how many times its usual median the recent time in a
+	// state has to be to count as low efficiency
+	SlowdownFactor float64
+}
+
+// smartFinding is a condition the rules found, before it is matched against
+// the stored alerts
+type smartFinding struct {
+	tipo        string
+	prioridad   string
+	huella      string
+	titulo      string
+	descripcion string
+	contexto    map[string]interface{}
+}
+
+// smartAlertData is what the rules are evaluated on
+type smartAlertData struct {
+	now       time.Time
+	location  *time.Location
+	orders    []repository.AlertCandidate
+	flows     []repository.StateFlow
+	recent    map[string]repository.StateDuration // exits within the queue window
+	earlier   map[string]repository.StateDuration // exits in the rest of the baseline
+	baseline  map[string]repository.StateDuration // every exit in the baseline
+	closedSet map[string]bool
+}
+
+func (r SmartAlertRules) evaluate(data smartAlertData) []smartFinding {
+	var findings []smartFinding
+	findings = append(findings, r.operatorOverload(data)...)
+	findings = append(findings, r.bottlenecks(data)...)
+	findings = append(findings, r.lowEfficiency(data)...)
+	findings = append(findings, r.predictedDelays(data)...)
+	return findings
+}
+
+// operatorOverload flags operators assigned more open orders than they can carry
+func (r SmartAlertRules) operatorOverload(data smartAlertData) []smartFinding {
+	if r.OperatorMaxOrders <= 0 {
+		return nil
+	}
+
+	byOperator := map[string][]repository.AlertCandidate{}
+	names := map[string]string{}
+	for _, order := range data.orders {
+		nombre := strings.TrimSpace(order.OperarioAsignado)
+		if nombre == "" || strings.HasPrefix(nombre, domain.AnonymousNamePrefix) || data.closedSet[order.Estado] {
+			continue
+		}
+		key := strings.ToLower(nombre)
+		byOperator[key] = append(byOperator[key], order)
+		names[key] = nombre
+	}
+
+	var findings []smartFinding
+	for key, orders := range byOperator {
+		count := len(orders)
+		if count <= r.OperatorMaxOrders {
+			continue
+		}
+		ratio := float64(count) / float64(r.OperatorMaxOrders)
+		prioridad := domain.PrioridadAlertaMedia
+		switch {
+		case ratio >= 2:
+			prioridad = domain.PrioridadAlertaCritica
+		case ratio >= 1.5:
+			prioridad = domain.PrioridadAlertaAlta
+		}
+
+		sort.Slice(orders, func(i, j int) bool { return orders[i].FechaEntrega.Before(orders[j].FechaEntrega) })
+		numeros := make([]string, 0, min(len(orders), smartAlertMaxOrders))
+		for _, order := range orders[:min(len(orders), smartAlertMaxOrders)] {
+			numeros = append(numeros, order.NumeroOP)
+		}
+
+		findings = append(findings, smartFinding{
+			tipo:        domain.AlertaSobrecargaOperario,
+			prioridad:   prioridad,
+			huella:      domain.AlertaSobrecargaOperario + ":" + key,
+			titulo:      fmt.Sprintf("%s tiene %d órdenes abiertas", names[key], count),
+			descripcion: fmt.Sprintf("El límite configurado es %d órdenes por operario.", r.OperatorMaxOrders),
+			contexto: map[string]interface{}{
+				"operario":         names[key],
+				"ordenes_abiertas": count,
+				"limite":           r.OperatorMaxOrders,
+				"ordenes":          numeros, // by delivery date
+			},
+		})
+	}
+	return findings
+}
+
+// bottlenecks flags states whose queue grew by QueueGrowth orders or more
+// within the queue window
+func (r SmartAlertRules) bottlenecks(data smartAlertData) []smartFinding {
+	if r.QueueGrowth <= 0 {
+		return nil
+	}
+
+	queue := map[string]int{}
+	for _, order := range data.orders {
+		queue[order.Estado]++
+	}
+
+	var findings []smartFinding
+	for _, flow := range data.flows {
+		growth := flow.Entradas - flow.Salidas
+		if data.closedSet[flow.Estado] || growth < r.QueueGrowth {
+			continue
+		}
+		prioridad := domain.PrioridadAlertaMedia
+		if growth >= 2*r.QueueGrowth {
+			prioridad = domain.PrioridadAlertaAlta
+		}
+
+		findings = append(findings, smartFinding{
+			tipo:      domain.AlertaCuelloBotella,
+			prioridad: prioridad,
+			huella:    domain.AlertaCuelloBotella + ":" + flow.Estado,
+			titulo:    fmt.Sprintf("La cola de %s crece más rápido de lo que se despacha", flow.Estado),
+			descripcion: fmt.Sprintf("En los últimos %s entraron %d órdenes y salieron %d.",
+				formatDays(r.QueueWindow), flow.Entradas, flow.Salidas),
+			contexto: map[string]interface{}{
+				"estado":        flow.Estado,
+				"entradas":      flow.Entradas,
+				"salidas":       flow.Salidas,
+				"crecimiento":   growth,
+				"cola_actual":   queue[flow.Estado],
+				"ventana_horas": r.QueueWindow.Hours(),
+			},
+		})
+	}
+	return findings
+}
+
+// lowEfficiency flags states where orders recently take SlowdownFactor times
+// longer than they used to
+func (r SmartAlertRules) lowEfficiency(data smartAlertData) []smartFinding {
+	if r.SlowdownFactor <= 1 {
+		return nil
+	}
+
+	var findings []smartFinding
+	for estado, recent := range data.recent {
+		earlier, ok := data.earlier[estado]
+		if data.closedSet[estado] || !ok || recent.Muestras < smartAlertMinSamples || earlier.Muestras < smartAlertMinSamples || earlier.MedianaSeg <= 0 {
+			continue
+		}
+		ratio := recent.MedianaSeg / earlier.MedianaSeg
+		if ratio < r.SlowdownFactor {
+			continue
+		}
+		prioridad := domain.PrioridadAlertaBaja
+		if ratio >= 2*r.SlowdownFactor {
+			prioridad = domain.PrioridadAlertaMedia
+		}
+
+		findings = append(findings, smartFinding{
+			tipo:      domain.AlertaEficienciaBaja,
+			prioridad: prioridad,
+			huella:    domain.AlertaEficienciaBaja + ":" + estado,
+			titulo:    fmt.Sprintf("Las órdenes tardan %.1f veces más de lo habitual en %s", ratio, estado),
+			descripcion: fmt.Sprintf("Mediana reciente: %s; habitual: %s.",
+				formatHours(recent.MedianaSeg), formatHours(earlier.MedianaSeg)),
+			contexto: map[string]interface{}{
+				"estado":                 estado,
+				"mediana_reciente_horas": roundHours(recent.MedianaSeg / 3600),
+				"mediana_habitual_horas": roundHours(earlier.MedianaSeg / 3600),
+				"muestras_recientes":     recent.Muestras,
+				"muestras_habituales":    earlier.Muestras,
+				"factor":                 roundHours(ratio),
+			},
+		})
+	}
+	return findings
+}
+
+// predictedDelays flags orders that, leaving their current state as late as
+// a typical slow order (75th percentile), would already miss their delivery.
+// Orders already past it get deadline alerts instead.
+func (r SmartAlertRules) predictedDelays(data smartAlertData) []smartFinding {
+	var findings []smartFinding
+	for _, order := range data.orders {
+		if data.closedSet[order.Estado] {
+			continue
+		}
+		stat, ok := data.baseline[order.Estado]
+		if !ok || stat.Muestras < smartAlertMinSamples {
+			continue
+		}
+		due, label := deliveryDue(order, data.location)
+		if !data.now.Before(due) {
+			continue
+		}
+		expectedExit := order.EnEstadoDesde.Add(time.Duration(stat.P75Seg * float64(time.Second)))
+		if !expectedExit.After(due) {
+			continue
+		}
+		delay := expectedExit.Sub(due)
+
+		prioridad := domain.PrioridadAlertaMedia
+		if delay >= 48*time.Hour {
+			prioridad = domain.PrioridadAlertaAlta
+		}
+		if order.Prioridad == domain.PrioridadAlta {
+			prioridad = raisePriority(prioridad)
+		}
+
+		findings = append(findings, smartFinding{
+			tipo:        domain.AlertaRetrasoPredicho,
+			prioridad:   prioridad,
+			huella:      fmt.Sprintf("%s:%d", domain.AlertaRetrasoPredicho, order.ID),
+			titulo:      fmt.Sprintf("%s probablemente no llegue al %s", order.NumeroOP, label),
+			descripcion: fmt.Sprintf("%s. Lleva %s en %s, donde las órdenes suelen estar hasta %s.", order.Cliente, formatDays(data.now.Sub(order.EnEstadoDesde)), order.Estado, formatHours(stat.P75Seg)),
+			contexto: map[string]interface{}{
+				"id_orden":              order.ID,
+				"numero_op":             order.NumeroOP,
+				"estado":                order.Estado,
+				"en_estado_desde":       order.EnEstadoDesde,
+				"entrega":               due,
+				"duracion_tipica_horas": roundHours(stat.P75Seg / 3600),
+				"muestras":              stat.Muestras,
+				"atraso_estimado_horas": roundHours(delay.Hours()),
+			},
+		})
+	}
+	return findings
+}
+
+func raisePriority(prioridad string) string {
+	switch prioridad {
+	case domain.PrioridadAlertaBaja:
+		return domain.PrioridadAlertaMedia
+	case domain.PrioridadAlertaMedia:
+		return domain.PrioridadAlertaAlta
+	default:
+		return domain.PrioridadAlertaCritica
+	}
+}
+
+// formatHours describes a number of seconds in hours, or days past two days
+func formatHours(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	if d >= 48*time.Hour {
+		return formatDays(d)
+	}
+	return fmt.Sprintf("%.0f horas", math.Max(1, math.Round(d.Hours())))
+}
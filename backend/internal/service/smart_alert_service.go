@@ -0,0 +1,247 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// ErrAlertResolved is returned when acting on an alert that is already resolved
+var ErrAlertResolved = errors.New("alert is already resolved")
+
+// maxSnooze bounds how long an alert can be silenced
+const maxSnooze = 30 * 24 * time.Hour
+
+type SmartAlertService interface {
+	GetAlerts(userID uint, filter repository.SmartAlertFilter) ([]domain.SmartAlert, error)
+	// ResolveAlert closes the alert. If its condition still holds the next
+	// evaluation raises it again; snooze it to keep it quiet meanwhile.
+	ResolveAlert(alertID, userID uint) (*domain.SmartAlert, error)
+	// SnoozeAlert hides the alert from the default list for a while; a zero
+	// duration wakes it up
+	SnoozeAlert(alertID, userID uint, duration time.Duration) (*domain.SmartAlert, error)
+
+	// Evaluate runs the rules: new conditions raise alerts, ones still holding
+	// update theirs and ones that cleared resolve by themselves. Admins are
+	// notified of new alta and critica alerts. It returns how many were raised.
+	Evaluate(now time.Time) (int, error)
+}
+
+type smartAlertService struct {
+	smartAlertRepo repository.SmartAlertRepository
+	alertRepo      repository.AlertRepository
+	userRepo       repository.UserRepository
+	uow            repository.UnitOfWork
+	notifier       Notifier
+	rules          SmartAlertRules
+	location       *time.Location
+}
+
+func NewSmartAlertService(smartAlertRepo repository.SmartAlertRepository, alertRepo repository.AlertRepository, userRepo repository.UserRepository, uow repository.UnitOfWork, notifier Notifier, rules SmartAlertRules, location *time.Location) SmartAlertService {
+	return &smartAlertService{
+		smartAlertRepo: smartAlertRepo,
+		alertRepo:      alertRepo,
+		userRepo:       userRepo,
+		uow:            uow,
+		notifier:       notifier,
+		rules:          rules,
+		location:       location,
+	}
+}
+
+func (s *smartAlertService) GetAlerts(userID uint, filter repository.SmartAlertFilter) ([]domain.SmartAlert, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermAlertManage); err != nil {
+		return nil, err
+	}
+	filter.Now = time.Now()
+	return s.smartAlertRepo.List(filter)
+}
+
+func (s *smartAlertService) ResolveAlert(alertID, userID uint) (*domain.SmartAlert, error) {
+	user, err := authorizeUser(s.userRepo, userID, authz.PermAlertManage)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.smartAlertRepo.GetByID(alertID); err != nil {
+		return nil, err
+	}
+
+	resolved, err := s.smartAlertRepo.Resolve(alertID, &user.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrAlertResolved
+	}
+	return s.smartAlertRepo.GetByID(alertID)
+}
+
+func (s *smartAlertService) SnoozeAlert(alertID, userID uint, duration time.Duration) (*domain.SmartAlert, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermAlertManage); err != nil {
+		return nil, err
+	}
+	if duration < 0 || duration > maxSnooze {
+		return nil, fmt.Errorf("snooze must be between 0 and %s", formatDays(maxSnooze))
+	}
+	if _, err := s.smartAlertRepo.GetByID(alertID); err != nil {
+		return nil, err
+	}
+
+	var until *time.Time
+	if duration > 0 {
+		t := time.Now().Add(duration)
+		until = &t
+	}
+	snoozed, err := s.smartAlertRepo.Snooze(alertID, until)
+	if err != nil {
+		return nil, err
+	}
+	if !snoozed {
+		return nil, ErrAlertResolved
+	}
+	return s.smartAlertRepo.GetByID(alertID)
+}
+
+func (s *smartAlertService) Evaluate(now time.Time) (int, error) {
+	data, err := s.load(now)
+	if err != nil {
+		return 0, err
+	}
+	findings := s.rules.evaluate(data)
+
+	var raised []domain.SmartAlert
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		raised = nil
+		// Two instances evaluating at once would raise the same alert twice
+		if err := repos.SmartAlerts.LockEvaluation(); err != nil {
+			return err
+		}
+		active, err := repos.SmartAlerts.ListActive()
+		if err != nil {
+			return err
+		}
+		byHuella := make(map[string]domain.SmartAlert, len(active))
+		for _, alert := range active {
+			if alert.Huella != nil {
+				byHuella[*alert.Huella] = alert
+			}
+		}
+
+		seen := make(map[string]bool, len(findings))
+		for _, finding := range findings {
+			seen[finding.huella] = true
+			alert := finding.toAlert(now)
+			if existing, ok := byHuella[finding.huella]; ok {
+				alert.ID = existing.ID
+				if err := repos.SmartAlerts.Refresh(&alert); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := repos.SmartAlerts.Create(&alert); err != nil {
+				return err
+			}
+			raised = append(raised, alert)
+		}
+
+		// Conditions that no longer hold resolve by themselves
+		for huella, alert := range byHuella {
+			if seen[huella] {
+				continue
+			}
+			if _, err := repos.SmartAlerts.Resolve(alert.ID, nil, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, alert := range raised {
+		if alert.Prioridad != domain.PrioridadAlertaAlta && alert.Prioridad != domain.PrioridadAlertaCritica {
+			continue
+		}
+		err := s.notifier.NotifyRole(domain.RolAdministracion, domain.UserNotification{
+			Title:       alert.Titulo,
+			Description: alert.Descripcion,
+			Type:        domain.NotificacionAlerta,
+		})
+		if err != nil {
+			log.Printf("Failed to notify smart alert %d: %v", alert.ID, err)
+		}
+	}
+	return len(raised), nil
+}
+
+// load gathers the orders and history statistics the rules look at
+func (s *smartAlertService) load(now time.Time) (smartAlertData, error) {
+	data := smartAlertData{
+		now:       now,
+		location:  s.location,
+		closedSet: map[string]bool{},
+	}
+	for _, estado := range workloadClosedEstados {
+		data.closedSet[estado] = true
+	}
+
+	var err error
+	if data.orders, err = s.alertRepo.ListCandidates(alertClosedEstados); err != nil {
+		return data, err
+	}
+	windowStart := now.Add(-s.rules.QueueWindow)
+	baselineStart := now.Add(-smartAlertBaseline)
+	if data.flows, err = s.smartAlertRepo.StateFlow(windowStart); err != nil {
+		return data, err
+	}
+	if data.recent, err = s.durations(windowStart, now); err != nil {
+		return data, err
+	}
+	if data.earlier, err = s.durations(baselineStart, windowStart); err != nil {
+		return data, err
+	}
+	if data.baseline, err = s.durations(baselineStart, now); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+func (s *smartAlertService) durations(from, to time.Time) (map[string]repository.StateDuration, error) {
+	byState := map[string]repository.StateDuration{}
+	if !from.Before(to) {
+		return byState, nil
+	}
+	durations, err := s.smartAlertRepo.StateDurations(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, duration := range durations {
+		byState[duration.Estado] = duration
+	}
+	return byState, nil
+}
+
+func (f smartFinding) toAlert(now time.Time) domain.SmartAlert {
+	huella := f.huella
+	descripcion := f.descripcion
+	alert := domain.SmartAlert{
+		TipoAlerta:         f.tipo,
+		Prioridad:          f.prioridad,
+		Titulo:             f.titulo,
+		Descripcion:        &descripcion,
+		Huella:             &huella,
+		FechaCreacion:      now,
+		FechaActualizacion: &now,
+	}
+	if contexto, err := json.Marshal(f.contexto); err == nil {
+		datos := string(contexto)
+		alert.DatosContexto = &datos
+	}
+	return alert
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+// defaultMovementLimit caps the ledger entries returned when no limit is given
+const defaultMovementLimit = 100
+
+// ErrNegativeStock is returned when an adjustment would take more than the stock on hand
+var ErrNegativeStock = errors.New("adjustment would leave the stock on hand below zero")
+
+// stockStage is what an order's Estado means for the materials on its lines
+type stockStage int
+
+const (
+	// stockNone: the order isn't in production, it holds no stock
+	stockNone stockStage = iota
+	// stockHold: the order is paused and keeps whatever it holds
+	stockHold
+	// stockReserve: the order is in production and holds its materials
+	stockReserve
+	// stockConsume: production is done and the materials are used up
+	stockConsume
+)
+
+// stockStageOf maps the documented production flow onto stock stages. States
+// outside it (e.g. from a custom workflow file) hold no stock.
+func stockStageOf(estado string) stockStage {
+	switch estado {
+	case domain.EstadoImprenta, domain.EstadoTallerImprenta, domain.EstadoTallerGrafico,
+		domain.EstadoInstalaciones, domain.EstadoMetalurgica:
+		return stockReserve
+	case domain.EstadoFinalizadoTaller, domain.EstadoAlmacenEntrega, domain.EstadoEntregado:
+		return stockConsume
+	case domain.EstadoEnEspera:
+		return stockHold
+	default:
+		return stockNone
+	}
+}
+
+// roundQty rounds to the three decimals quantities are stored with
+func roundQty(q float64) float64 {
+	return math.Round(q*1000) / 1000
+}
+
+// lowStockSet collects the materials that fell below their reorder point
+type lowStockSet map[uint]domain.Material
+
+func (l lowStockSet) add(before float64, after *domain.Material) {
+	if after.BelowReorderPoint() && before >= *after.PuntoReposicion {
+		l[after.ID] = *after
+	}
+}
+
+// applyStockChange updates a material's totals and writes the ledger entry.
+// It must run inside a unit of work.
+func applyStockChange(repos *repository.Repositories, low lowStockSet, movement domain.StockMovement) (*domain.Material, error) {
+	movement.CambioStock = roundQty(movement.CambioStock)
+	movement.CambioReservado = roundQty(movement.CambioReservado)
+
+	material, err := repos.Stock.ApplyChange(movement.IDMaterial, movement.CambioStock, movement.CambioReservado)
+	if err != nil {
+		return nil, err
+	}
+
+	movement.StockResultante = material.StockActual
+	movement.ReservadoResultante = material.StockReservado
+	if movement.Timestamp.IsZero() {
+		movement.Timestamp = time.Now()
+	}
+	if err := repos.Stock.CreateMovement(&movement); err != nil {
+		return nil, err
+	}
+
+	low.add(material.StockDisponible()-movement.CambioStock+movement.CambioReservado, material)
+	return material, nil
+}
+
+// syncOrderStock brings the reservations and consumption of an order's material
+// lines in step with estado. Lines remember what they reserved and consumed, so
+// running it again for the same state changes nothing. It must run inside a
+// unit of work holding the order's row lock.
+func syncOrderStock(repos *repository.Repositories, low lowStockSet, orderID uint, estado string, userID *uint) error {
+	stage := stockStageOf(estado)
+	if stage == stockHold {
+		return nil
+	}
+
+	lines, err := repos.Materials.ListOrderMaterials(orderID)
+	if err != nil {
+		return err
+	}
+	if err := lockMaterials(repos, lines); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if err := syncLineStock(repos, low, orderID, line, stage, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockMaterials locks the materials of the order lines, and any extra ones, in
+// id order. Transactions that change the stock of several materials take their
+// locks this way, so two orders sharing materials can't deadlock whatever the
+// order of their lines.
+func lockMaterials(repos *repository.Repositories, lines []domain.OrderMaterial, extra ...uint) error {
+	ids := slices.Clone(extra)
+	for _, line := range lines {
+		ids = append(ids, line.IDMaterial)
+	}
+	return repos.Stock.LockMaterials(ids)
+}
+
+func syncLineStock(repos *repository.Repositories, low lowStockSet, orderID uint, line domain.OrderMaterial, stage stockStage, userID *uint) error {
+	reservada := line.CantidadReservada
+	consumida := line.CantidadConsumida
+	pendiente := roundQty(math.Max(line.Cantidad-consumida, 0))
+
+	movement := domain.StockMovement{IDMaterial: line.IDMaterial, IDOrden: &orderID, IDUsuario: userID}
+	switch stage {
+	case stockReserve:
+		movement.CambioReservado = pendiente - reservada
+		if movement.CambioReservado > 0 {
+			movement.Tipo = domain.MovimientoReserva
+		} else {
+			movement.Tipo = domain.MovimientoLiberacion
+		}
+		reservada = pendiente
+	case stockConsume:
+		movement.Tipo = domain.MovimientoConsumo
+		movement.CambioStock = -pendiente
+		movement.CambioReservado = -reservada
+		if pendiente == 0 {
+			movement.Tipo = domain.MovimientoLiberacion
+		}
+		consumida += pendiente
+		reservada = 0
+	default:
+		movement.Tipo = domain.MovimientoLiberacion
+		movement.CambioReservado = -reservada
+		reservada = 0
+	}
+
+	if roundQty(movement.CambioStock) == 0 && roundQty(movement.CambioReservado) == 0 {
+		return nil
+	}
+	if _, err := applyStockChange(repos, low, movement); err != nil {
+		return err
+	}
+	return repos.Stock.SetLineStock(line.ID, roundQty(reservada), roundQty(consumida))
+}
+
+// notifyLowStock alerts administracion about materials that fell below their
+// reorder point. It runs after the change is committed; failures are logged.
+func notifyLowStock(notifier Notifier, low lowStockSet) {
+	for _, material := range low {
+		nombre := material.Descripcion
+		if material.Codigo != nil {
+			nombre = *material.Codigo + " - " + nombre
+		}
+		description := fmt.Sprintf("Disponible: %.3f (punto de reposición %.3f)", material.StockDisponible(), *material.PuntoReposicion)
+
+		err := notifier.NotifyRole(domain.RolAdministracion, domain.UserNotification{
+			Title:       "Stock bajo: " + nombre,
+			Description: &description,
+			Type:        domain.NotificacionStockBajo,
+		})
+		if err != nil {
+			log.Printf("Failed to notify low stock of material %d: %v", material.ID, err)
+		}
+	}
+}
+
+type StockService interface {
+	GetMovements(materialID, userID uint, limit int) ([]domain.StockMovement, error)
+	// ReceiveStock records goods coming in
+	ReceiveStock(materialID, userID uint, cantidad float64, motivo *string) (*domain.Material, error)
+	// AdjustStock corrects the stock on hand by a signed amount, e.g. after a count.
+	// It can't take the stock on hand below zero.
+	AdjustStock(materialID, userID uint, cantidad float64, motivo string) (*domain.Material, error)
+}
+
+type stockService struct {
+	stockRepo repository.StockRepository
+	userRepo  repository.UserRepository
+	uow       repository.UnitOfWork
+	notifier  Notifier
+}
+
+func NewStockService(stockRepo repository.StockRepository, userRepo repository.UserRepository, uow repository.UnitOfWork, notifier Notifier) StockService {
+	return &stockService{
+		stockRepo: stockRepo,
+		userRepo:  userRepo,
+		uow:       uow,
+		notifier:  notifier,
+	}
+}
+
+func (s *stockService) GetMovements(materialID, userID uint, limit int) ([]domain.StockMovement, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermOrderView); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > defaultMovementLimit {
+		limit = defaultMovementLimit
+	}
+	return s.stockRepo.ListMovements(materialID, limit)
+}
+
+func (s *stockService) ReceiveStock(materialID, userID uint, cantidad float64, motivo *string) (*domain.Material, error) {
+	if cantidad <= 0 {
+		return nil, errors.New("cantidad must be greater than zero")
+	}
+	return s.record(materialID, userID, domain.StockMovement{
+		Tipo:        domain.MovimientoIngreso,
+		CambioStock: cantidad,
+		Motivo:      motivo,
+	})
+}
+
+func (s *stockService) AdjustStock(materialID, userID uint, cantidad float64, motivo string) (*domain.Material, error) {
+	if roundQty(cantidad) == 0 {
+		return nil, errors.New("cantidad must not be zero")
+	}
+	motivo = strings.TrimSpace(motivo)
+	if motivo == "" {
+		return nil, errors.New("motivo is required for adjustments")
+	}
+	return s.record(materialID, userID, domain.StockMovement{
+		Tipo:        domain.MovimientoAjuste,
+		CambioStock: cantidad,
+		Motivo:      &motivo,
+	})
+}
+
+func (s *stockService) record(materialID, userID uint, movement domain.StockMovement) (*domain.Material, error) {
+	if _, err := authorizeUser(s.userRepo, userID, authz.PermStockManage); err != nil {
+		return nil, err
+	}
+
+	movement.IDMaterial = materialID
+	movement.IDUsuario = &userID
+
+	var material *domain.Material
+	low := lowStockSet{}
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		var err error
+		material, err = applyStockChange(repos, low, movement)
+		if err != nil {
+			return err
+		}
+		// The update holds the material's row lock until the transaction ends,
+		// so this sees every other change to its totals; rolling back undoes it
+		if movement.CambioStock < 0 && material.StockActual < 0 {
+			return ErrNegativeStock
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyLowStock(s.notifier, low)
+	return material, nil
+}
@@ -7,6 +7,18 @@ import (
 	"time"
 )
 
+// TaskEvent is published to the board's owner when one of its tasks is
+// created, edited or deleted
+type TaskEvent struct {
+	TaskID     uint                `json:"task_id"`
+	BoardID    uint                `json:"board_id"`
+	Title      string              `json:"title"`
+	Status     domain.TaskStatus   `json:"status"`
+	Priority   domain.TaskPriority `json:"priority"`
+	AssigneeID *uint               `json:"assignee_id"`
+	DueDate    *time.Time          `json:"due_date"`
+}
+
 type TaskService interface {
 	CreateTask(boardID, userID uint, title, description string, priority domain.TaskPriority, assigneeID *uint, dueDate *time.Time) (*domain.Task, error)
 	GetTasks(boardID, userID uint) ([]domain.Task, error)
@@ -18,12 +30,16 @@ type TaskService interface {
 type taskService struct {
 	taskRepo  repository.TaskRepository
 	boardRepo repository.BoardRepository
+	uow       repository.UnitOfWork
+	events    EventWaker
 }
 
-func NewTaskService(taskRepo repository.TaskRepository) TaskService {
+func NewTaskService(taskRepo repository.TaskRepository, uow repository.UnitOfWork, events EventWaker) TaskService {
 	return &taskService{
 		taskRepo:  taskRepo,
 		boardRepo: nil, // Will be set by dependency injection
+		uow:       uow,
+		events:    events,
 	}
 }
 
@@ -52,9 +68,16 @@ func (s *taskService) CreateTask(boardID, userID uint, title, description string
 		DueDate:     dueDate,
 	}
 
-	if err := s.taskRepo.Create(task); err != nil {
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Tasks.Create(task); err != nil {
+			return err
+		}
+		return recordTaskEvent(repos, EventTaskCreated, task, board.OwnerID)
+	})
+	if err != nil {
 		return nil, err
 	}
+	wake(s.events)
 
 	return task, nil
 }
@@ -105,18 +128,50 @@ func (s *taskService) UpdateTask(taskID, userID uint, title, description string,
 	task.AssigneeID = assigneeID
 	task.DueDate = dueDate
 
-	if err := s.taskRepo.Update(task); err != nil {
+	// Only the board owner gets this far
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Tasks.Update(task); err != nil {
+			return err
+		}
+		return recordTaskEvent(repos, EventTaskUpdated, task, userID)
+	})
+	if err != nil {
 		return nil, err
 	}
+	wake(s.events)
 
 	return task, nil
 }
 
 func (s *taskService) DeleteTask(taskID, userID uint) error {
-	_, err := s.GetTask(taskID, userID)
+	task, err := s.GetTask(taskID, userID)
 	if err != nil {
 		return err
 	}
 
-	return s.taskRepo.Delete(taskID)
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Tasks.Delete(taskID); err != nil {
+			return err
+		}
+		return recordTaskEvent(repos, EventTaskDeleted, task, userID)
+	})
+	if err != nil {
+		return err
+	}
+	wake(s.events)
+	return nil
+}
+
+// recordTaskEvent publishes the task change to the board's owner, in the unit
+// of work that made it
+func recordTaskEvent(repos *repository.Repositories, eventType string, task *domain.Task, ownerID uint) error {
+	return recordEventFor(repos, domain.AgregadoTarea, task.ID, ownerID, eventType, TaskEvent{
+		TaskID:     task.ID,
+		BoardID:    task.BoardID,
+		Title:      task.Title,
+		Status:     task.Status,
+		Priority:   task.Priority,
+		AssigneeID: task.AssigneeID,
+		DueDate:    task.DueDate,
+	})
 }
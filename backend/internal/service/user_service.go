@@ -2,29 +2,52 @@ package service
 
 import (
 	"errors"
-	"time"
+	"net/mail"
+	"strings"
+	"task-board/internal/authz"
 	"task-board/internal/domain"
 	"task-board/internal/repository"
 	"task-board/pkg/config"
-
-	"github.com/golang-jwt/jwt/v5"
+	"time"
 )
 
 type UserService interface {
-	Register(email, username, password, firstName, lastName string) (*domain.User, error)
-	Login(email, password string) (string, *domain.User, error)
+	Login(nombre, password string, client ClientInfo) (*AuthTokens, *domain.User, error)
 	GetProfile(userID uint) (*domain.User, error)
-	UpdateProfile(userID uint, firstName, lastName string) (*domain.User, error)
+	UpdateProfile(userID uint, currentPassword, newPassword string) (*domain.User, error)
+
+	// Sessions
+	Refresh(refreshToken string, client ClientInfo) (*AuthTokens, *domain.User, error)
+	Logout(refreshToken string) error
+	LogoutAll(userID uint) error
+	IsSessionActive(sessionID string) bool
+	PurgeExpiredSessions() (int64, error)
+
+	// User administration
+	GetUsers(adminID uint) ([]domain.User, error)
+	CreateUser(adminID uint, nombre, password, rol string) (*domain.User, error)
+	// UpdateUser changes the fields that are set; an empty email removes the address
+	UpdateUser(adminID, userID uint, nombre, rol, password string, email *string) (*domain.User, error)
+	DeleteUser(adminID, userID uint) error
+
+	// EnsureAdmin creates the first administracion user when there are no users yet
+	EnsureAdmin(nombre, password string) error
 }
 
 type userService struct {
-	userRepo repository.UserRepository
-	config   *config.Config
+	userRepo    repository.UserRepository
+	refreshRepo repository.RefreshTokenRepository
+	sessionRepo repository.SessionRepository
+	uow         repository.UnitOfWork
+	config      *config.Config
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
+func NewUserService(userRepo repository.UserRepository, refreshRepo repository.RefreshTokenRepository, sessionRepo repository.SessionRepository, uow repository.UnitOfWork) UserService {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		refreshRepo: refreshRepo,
+		sessionRepo: sessionRepo,
+		uow:         uow,
 	}
 }
 
@@ -32,86 +55,213 @@ func (s *userService) SetConfig(config *config.Config) {
 	s.config = config
 }
 
-func (s *userService) Register(email, username, password, firstName, lastName string) (*domain.User, error) {
-	// Check if user already exists
-	existingUser, _ := s.userRepo.GetByEmail(email)
-	if existingUser != nil {
-		return nil, errors.New("user with this email already exists")
+func (s *userService) Login(nombre, password string, client ClientInfo) (*AuthTokens, *domain.User, error) {
+	// Get user by nombre
+	user, err := s.userRepo.GetByNombre(strings.TrimSpace(nombre))
+	if err != nil {
+		return nil, nil, errors.New("invalid credentials")
 	}
 
-	existingUser, _ = s.userRepo.GetByUsername(username)
-	if existingUser != nil {
-		return nil, errors.New("user with this username already exists")
+	// Check password
+	if !user.CheckPassword(password) {
+		return nil, nil, errors.New("invalid credentials")
 	}
 
-	// Create new user
-	user := &domain.User{
-		Email:     email,
-		Username:  username,
-		FirstName: firstName,
-		LastName:  lastName,
+	// Open a session and issue its tokens
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Set password
-	if err := user.SetPassword(password); err != nil {
-		return nil, err
+	now := time.Now()
+	user.LastSeen = &now
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, nil, err
 	}
 
-	// Save user
-	if err := s.userRepo.Create(user); err != nil {
-		return nil, err
-	}
+	return tokens, user, nil
+}
 
-	return user, nil
+func (s *userService) GetProfile(userID uint) (*domain.User, error) {
+	return s.userRepo.GetByID(userID)
 }
 
-func (s *userService) Login(email, password string) (string, *domain.User, error) {
-	// Get user by email
-	user, err := s.userRepo.GetByEmail(email)
+func (s *userService) UpdateProfile(userID uint, currentPassword, newPassword string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
-		return "", nil, errors.New("invalid credentials")
+		return nil, err
 	}
 
-	// Check password
-	if !user.CheckPassword(password) {
-		return "", nil, errors.New("invalid credentials")
+	if !user.CheckPassword(currentPassword) {
+		return nil, errors.New("current password is incorrect")
+	}
+	if err := user.SetPassword(newPassword); err != nil {
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := s.generateJWT(user.ID)
-	if err != nil {
-		return "", nil, err
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	// A new password ends every open session, including the current one
+	if err := s.revokeUserSessions(user.ID); err != nil {
+		return nil, err
 	}
 
-	return token, user, nil
+	return user, nil
 }
 
-func (s *userService) GetProfile(userID uint) (*domain.User, error) {
-	return s.userRepo.GetByID(userID)
+func (s *userService) GetUsers(adminID uint) ([]domain.User, error) {
+	if err := s.authorizeAdmin(adminID); err != nil {
+		return nil, err
+	}
+	return s.userRepo.List()
 }
 
-func (s *userService) UpdateProfile(userID uint, firstName, lastName string) (*domain.User, error) {
+func (s *userService) CreateUser(adminID uint, nombre, password, rol string) (*domain.User, error) {
+	if err := s.authorizeAdmin(adminID); err != nil {
+		return nil, err
+	}
+	return s.createUser(nombre, password, rol)
+}
+
+func (s *userService) UpdateUser(adminID, userID uint, nombre, rol, password string, email *string) (*domain.User, error) {
+	if err := s.authorizeAdmin(adminID); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	user.FirstName = firstName
-	user.LastName = lastName
+	if nombre = strings.TrimSpace(nombre); nombre != "" && nombre != user.Nombre {
+		if strings.HasPrefix(nombre, domain.AnonymousNamePrefix) {
+			return nil, errors.New("nombre cannot start with " + domain.AnonymousNamePrefix)
+		}
+		if existing, _ := s.userRepo.GetByNombre(nombre); existing != nil {
+			return nil, errors.New("user with this nombre already exists")
+		}
+		user.Nombre = nombre
+	}
+	if rol != "" {
+		if !authz.IsValidRole(rol) {
+			return nil, errors.New("invalid rol")
+		}
+		if userID == adminID && authz.NormalizeRole(rol) != domain.RolAdministracion {
+			return nil, errors.New("you cannot remove your own administracion role")
+		}
+		user.Rol = authz.NormalizeRole(rol)
+	}
+	if password != "" {
+		if err := user.SetPassword(password); err != nil {
+			return nil, err
+		}
+	}
+	if email != nil {
+		address, err := normalizeEmail(*email)
+		if err != nil {
+			return nil, err
+		}
+		user.Email = address
+	}
 
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, err
 	}
 
+	if password != "" {
+		if err := s.revokeUserSessions(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	return user, nil
 }
 
-func (s *userService) generateJWT(userID uint) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     jwt.NewNumericDate(time.Now().Add(s.config.JWTExpiry)),
+func (s *userService) DeleteUser(adminID, userID uint) error {
+	if err := s.authorizeAdmin(adminID); err != nil {
+		return err
+	}
+	if userID == adminID {
+		return errors.New("you cannot delete your own user")
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Delete(userID); err != nil {
+		return err
+	}
+	return s.revokeUserSessions(userID)
+}
+
+func (s *userService) EnsureAdmin(nombre, password string) error {
+	count, err := s.userRepo.Count()
+	if err != nil || count > 0 {
+		return err
+	}
+	if nombre == "" || password == "" {
+		return errors.New("no users exist and ADMIN_NOMBRE/ADMIN_PASSWORD are not set")
+	}
+
+	_, err = s.createUser(nombre, password, domain.RolAdministracion)
+	return err
+}
+
+// normalizeEmail returns the bare address, or nil for an empty one
+func normalizeEmail(email string) (*string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return nil, errors.New("invalid email")
 	}
+	return &address.Address, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
+func (s *userService) createUser(nombre, password, rol string) (*domain.User, error) {
+	nombre = strings.TrimSpace(nombre)
+	if nombre == "" {
+		return nil, errors.New("nombre is required")
+	}
+	if strings.HasPrefix(nombre, domain.AnonymousNamePrefix) {
+		return nil, errors.New("nombre cannot start with " + domain.AnonymousNamePrefix)
+	}
+	if !authz.IsValidRole(rol) {
+		return nil, errors.New("invalid rol")
+	}
+
+	// Check if user already exists
+	if existing, _ := s.userRepo.GetByNombre(nombre); existing != nil {
+		return nil, errors.New("user with this nombre already exists")
+	}
+
+	user := &domain.User{
+		Nombre: nombre,
+		Rol:    authz.NormalizeRole(rol),
+	}
+
+	// Set password
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+
+	// Save user
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *userService) authorizeAdmin(adminID uint) error {
+	admin, err := s.userRepo.GetByID(adminID)
+	if err != nil {
+		return err
+	}
+	return authz.Check(admin.Rol, authz.PermUserManage)
 }
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or logged out refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again. The whole session it belongs to is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// ClientInfo describes the client a session was opened from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// AuthTokens is the token pair handed out on login and refresh
+type AuthTokens struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int       `json:"expires_in"`
+	ExpiresAt    time.Time `json:"refresh_expires_at"`
+}
+
+// Refresh trades a refresh token for a new token pair. The presented token is
+// single-use; presenting it again revokes the session.
+func (s *userService) Refresh(refreshToken string, client ClientInfo) (*AuthTokens, *domain.User, error) {
+	stored, err := s.refreshRepo.GetByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil {
+		if stored.ReplacedByID != nil {
+			// Either the client or someone holding a stolen copy already used this
+			// token, and there's no telling which, so end the session for both
+			if err := s.revokeSessions([]string{stored.FamilyID}, now); err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, ErrRefreshTokenReused
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(stored.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var tokens *AuthTokens
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		next, raw, err := s.createRefreshToken(repos.RefreshTokens, user.ID, stored.FamilyID, now, client)
+		if err != nil {
+			return err
+		}
+
+		rotated, err := repos.RefreshTokens.Rotate(stored.ID, next.ID, now)
+		if err != nil {
+			return err
+		}
+		if !rotated {
+			// A concurrent refresh won the race with the same token
+			return ErrRefreshTokenReused
+		}
+
+		tokens, err = s.tokenPair(user, next, raw)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := s.revokeSessions([]string{stored.FamilyID}, now); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, user, nil
+}
+
+// Logout ends the session the refresh token belongs to. Logging out twice is not an error.
+func (s *userService) Logout(refreshToken string) error {
+	stored, err := s.refreshRepo.GetByHash(hashToken(refreshToken))
+	if err != nil {
+		return ErrInvalidRefreshToken
+	}
+	return s.revokeSessions([]string{stored.FamilyID}, time.Now())
+}
+
+// LogoutAll ends every session of the user
+func (s *userService) LogoutAll(userID uint) error {
+	return s.revokeUserSessions(userID)
+}
+
+// IsSessionActive reports whether access tokens of the session are still accepted
+func (s *userService) IsSessionActive(sessionID string) bool {
+	revoked, err := s.sessionRepo.IsRevoked(sessionID)
+	if err != nil {
+		// Fail open: access tokens are short-lived, so a Redis outage delays a
+		// revocation by at most one access token lifetime instead of locking
+		// everybody out
+		log.Printf("Failed to check session %s: %v", sessionID, err)
+		return true
+	}
+	return !revoked
+}
+
+// PurgeExpiredSessions deletes refresh tokens that expired more than a day ago
+func (s *userService) PurgeExpiredSessions() (int64, error) {
+	return s.refreshRepo.DeleteExpired(time.Now().Add(-24 * time.Hour))
+}
+
+// startSession opens a new session for the user and returns its first token pair
+func (s *userService) startSession(user *domain.User, client ClientInfo) (*AuthTokens, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, raw, err := s.createRefreshToken(s.refreshRepo, user.ID, familyID, time.Now(), client)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, stored, raw)
+}
+
+func (s *userService) createRefreshToken(repo repository.RefreshTokenRepository, userID uint, familyID string, now time.Time, client ClientInfo) (*domain.RefreshToken, string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &domain.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(s.config.RefreshTokenExpiry),
+		UserAgent: truncatedOrNil(client.UserAgent, 255),
+		IP:        truncatedOrNil(client.IP, 64),
+	}
+	if err := repo.Create(token); err != nil {
+		return nil, "", err
+	}
+	return token, raw, nil
+}
+
+func (s *userService) tokenPair(user *domain.User, refresh *domain.RefreshToken, raw string) (*AuthTokens, error) {
+	access, err := s.generateJWT(user, refresh.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  access,
+		RefreshToken: raw,
+		ExpiresIn:    int(s.config.JWTExpiry.Seconds()),
+		ExpiresAt:    refresh.ExpiresAt,
+	}, nil
+}
+
+func (s *userService) generateJWT(user *domain.User, sessionID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"rol":     user.Rol,
+		"sid":     sessionID,
+		"iat":     jwt.NewNumericDate(now),
+		"exp":     jwt.NewNumericDate(now.Add(s.config.JWTExpiry)),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.config.JWTSecret))
+}
+
+// revokeSessions revokes the refresh tokens of the sessions and blocks their
+// access tokens until the last of them has expired
+func (s *userService) revokeSessions(familyIDs []string, now time.Time) error {
+	for _, familyID := range familyIDs {
+		if err := s.refreshRepo.RevokeFamily(familyID, now); err != nil {
+			return err
+		}
+	}
+	return s.sessionRepo.Revoke(familyIDs, s.config.JWTExpiry)
+}
+
+func (s *userService) revokeUserSessions(userID uint) error {
+	families, err := s.refreshRepo.RevokeUser(userID, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.sessionRepo.Revoke(families, s.config.JWTExpiry)
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is what gets stored for a refresh token, so a database leak
+// doesn't hand out live sessions
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncatedOrNil(value string, max int) *string {
+	if value == "" {
+		return nil
+	}
+	if len(value) > max {
+		value = value[:max]
+	}
+	return &value
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"task-board/internal/authz"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"time"
+)
+
+const (
+	// webhookBatchSize is how many deliveries one dispatcher pass claims at a time
+	webhookBatchSize = 50
+	// webhookLease keeps a claimed delivery away from other dispatchers; it
+	// must outlast the request timeout
+	webhookLease = 2 * time.Minute
+	// webhookFirstRetry doubles after every failed attempt, up to maxWebhookRetryDelay
+	webhookFirstRetry    = 30 * time.Second
+	maxWebhookRetryDelay = time.Hour
+	// maxWebhookErrorLen bounds the response excerpt kept with a failed attempt
+	maxWebhookErrorLen = 500
+)
+
+// Request headers of webhook deliveries. The signature is the hex HMAC-SHA256,
+// keyed with the webhook secret, of "<timestamp>.<body>".
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to
+var WebhookEvents = []string{
+	EventOrderCreated,
+	EventOrderUpdated,
+	EventOrderStateChanged,
+	EventOrderMoved,
+	EventOrderDeleted,
+	EventOrderClaimed,
+	EventOrderReleased,
+	EventOrderSectorChanged,
+	EventOrderChecklistChanged,
+}
+
+// WebhookInput carries the editable fields of a webhook
+type WebhookInput struct {
+	URL         string
+	Descripcion *string
+	Eventos     []string
+	Estados     []string
+	// Activo turning true also clears the failure streak; nil leaves it
+	Activo *bool
+}
+
+// CreatedWebhook is a new webhook with its secret, which is only shown once
+type CreatedWebhook struct {
+	domain.Webhook
+	Secreto string `json:"secreto"`
+}
+
+// WebhookSettings tunes retries and deactivation
+type WebhookSettings struct {
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is given up
+	MaxAttempts int
+	// DisableAfter is how many failed attempts in a row deactivate a webhook
+	DisableAfter int
+}
+
+// webhookPayload is the JSON body of every delivery
+type webhookPayload struct {
+	ID     string          `json:"id"`
+	Evento string          `json:"evento"`
+	Fecha  time.Time       `json:"fecha"`
+	Datos  json.RawMessage `json:"datos"`
+}
+
+// WebhookService manages webhook subscriptions and delivers events to them.
+// As an EventSubscriber it queues a delivery for every subscribed webhook;
+// Dispatch does the sending.
+type WebhookService interface {
+	EventSubscriber
+
+	GetWebhooks(userID uint) ([]domain.Webhook, error)
+	CreateWebhook(userID uint, input WebhookInput) (*CreatedWebhook, error)
+	UpdateWebhook(webhookID, userID uint, input WebhookInput) (*domain.Webhook, error)
+	DeleteWebhook(webhookID, userID uint) error
+
+	GetDeliveries(webhookID, userID uint, limit int) ([]domain.WebhookDelivery, error)
+	// ReplayDelivery queues the delivery's payload again, as a new delivery
+	ReplayDelivery(webhookID, deliveryID, userID uint) (*domain.WebhookDelivery, error)
+
+	// Dispatch sends every delivery due at now, returning how many went through
+	Dispatch(now time.Time) (int, error)
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	userRepo    repository.UserRepository
+	workflow    *Workflow
+	client      *http.Client
+	settings    WebhookSettings
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, userRepo repository.UserRepository, workflow *Workflow, settings WebhookSettings) WebhookService {
+	if settings.MaxAttempts < 1 {
+		settings.MaxAttempts = 1
+	}
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		userRepo:    userRepo,
+		workflow:    workflow,
+		settings:    settings,
+		client: &http.Client{
+			Timeout: settings.Timeout,
+			// A redirect counts as a failure; the subscription should be fixed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (s *webhookService) GetWebhooks(userID uint) ([]domain.Webhook, error) {
+	if _, err := s.authorize(userID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.List()
+}
+
+func (s *webhookService) CreateWebhook(userID uint, input WebhookInput) (*CreatedWebhook, error) {
+	if _, err := s.authorize(userID); err != nil {
+		return nil, err
+	}
+	if err := s.validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	webhook := &domain.Webhook{
+		URL:              input.URL,
+		Descripcion:      input.Descripcion,
+		Secreto:          hex.EncodeToString(secret),
+		Eventos:          input.Eventos,
+		Estados:          input.Estados,
+		Activo:           input.Activo == nil || *input.Activo,
+		IDUsuarioCreador: userID,
+	}
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, err
+	}
+	return &CreatedWebhook{Webhook: *webhook, Secreto: webhook.Secreto}, nil
+}
+
+func (s *webhookService) UpdateWebhook(webhookID, userID uint, input WebhookInput) (*domain.Webhook, error) {
+	if _, err := s.authorize(userID); err != nil {
+		return nil, err
+	}
+	webhook, err := s.webhookRepo.GetByID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	webhook.URL = input.URL
+	webhook.Descripcion = input.Descripcion
+	webhook.Eventos = input.Eventos
+	webhook.Estados = input.Estados
+	if input.Activo != nil {
+		if *input.Activo && !webhook.Activo {
+			webhook.FallosConsecutivos = 0
+			webhook.FechaDesactivacion = nil
+			webhook.MotivoDesactivacion = nil
+		}
+		webhook.Activo = *input.Activo
+	}
+
+	if err := s.webhookRepo.Update(webhook); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.GetByID(webhookID)
+}
+
+func (s *webhookService) DeleteWebhook(webhookID, userID uint) error {
+	if _, err := s.authorize(userID); err != nil {
+		return err
+	}
+	if _, err := s.webhookRepo.GetByID(webhookID); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(webhookID)
+}
+
+func (s *webhookService) GetDeliveries(webhookID, userID uint, limit int) ([]domain.WebhookDelivery, error) {
+	if _, err := s.authorize(userID); err != nil {
+		return nil, err
+	}
+	if _, err := s.webhookRepo.GetByID(webhookID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.ListDeliveries(webhookID, pageSize(limit))
+}
+
+func (s *webhookService) ReplayDelivery(webhookID, deliveryID, userID uint) (*domain.WebhookDelivery, error) {
+	if _, err := s.authorize(userID); err != nil {
+		return nil, err
+	}
+	original, err := s.webhookRepo.GetDelivery(webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	replay := domain.WebhookDelivery{
+		IDWebhook:      original.IDWebhook,
+		IDEvento:       original.IDEvento,
+		Evento:         original.Evento,
+		Payload:        original.Payload,
+		Estado:         domain.WebhookPendiente,
+		ProximoIntento: &now,
+		ReenvioDe:      &original.ID,
+	}
+	deliveries := []domain.WebhookDelivery{replay}
+	if err := s.webhookRepo.CreateDeliveries(deliveries); err != nil {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// HandleEvent queues the event for every active webhook subscribed to it. The
+// delivery keeps the event's ID, so a redelivered event isn't queued twice.
+func (s *webhookService) HandleEvent(event Event) error {
+	msgType := event.Type
+	if event.UserID != 0 || !slices.Contains(WebhookEvents, msgType) {
+		return nil
+	}
+	webhooks, err := s.webhookRepo.ListActive()
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	// Events about an order carry its state, which Estados filters on
+	var subject struct {
+		Estado *string `json:"estado"`
+	}
+	json.Unmarshal(event.Data, &subject)
+
+	now := time.Now()
+	payload, err := json.Marshal(webhookPayload{ID: event.ID, Evento: msgType, Fecha: event.Time, Datos: event.Data})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []domain.WebhookDelivery
+	for _, webhook := range webhooks {
+		if len(webhook.Eventos) > 0 && !slices.Contains(webhook.Eventos, msgType) {
+			continue
+		}
+		if len(webhook.Estados) > 0 && (subject.Estado == nil || !slices.Contains(webhook.Estados, *subject.Estado)) {
+			continue
+		}
+		deliveries = append(deliveries, domain.WebhookDelivery{
+			IDWebhook:      webhook.ID,
+			IDEvento:       event.ID,
+			Evento:         msgType,
+			Payload:        string(payload),
+			Estado:         domain.WebhookPendiente,
+			ProximoIntento: &now,
+		})
+	}
+	return s.webhookRepo.CreateDeliveries(deliveries)
+}
+
+func (s *webhookService) Dispatch(now time.Time) (int, error) {
+	sent := 0
+	for {
+		deliveries, err := s.webhookRepo.LeaseDue(now, now.Add(webhookLease), webhookBatchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		webhooks := make(map[uint]*domain.Webhook)
+		var skipped []uint
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			webhook, ok := webhooks[delivery.IDWebhook]
+			if !ok {
+				if webhook, err = s.webhookRepo.GetByID(delivery.IDWebhook); err != nil {
+					log.Printf("Failed to load webhook %d: %v", delivery.IDWebhook, err)
+					continue
+				}
+				webhooks[delivery.IDWebhook] = webhook
+			}
+			if !webhook.Activo {
+				// Deactivated by an earlier delivery of this batch or by another
+				// dispatcher; the rest wait for it to be reactivated
+				skipped = append(skipped, delivery.ID)
+				continue
+			}
+			if s.attempt(webhook, delivery, now) {
+				sent++
+			}
+			if err := s.webhookRepo.UpdateAttempt(delivery); err != nil {
+				log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+			}
+		}
+		if err := s.webhookRepo.ReleaseLease(skipped, now); err != nil {
+			log.Printf("Failed to release %d webhook deliveries: %v", len(skipped), err)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// attempt posts the delivery once and updates its state. Every failed attempt
+// adds to the webhook's failure streak, which deactivates it once it reaches
+// DisableAfter; a success clears the streak.
+func (s *webhookService) attempt(webhook *domain.Webhook, delivery *domain.WebhookDelivery, now time.Time) bool {
+	code, err := s.post(webhook, delivery)
+	delivery.Intentos++
+	delivery.ProximoIntento = nil
+	if code != 0 {
+		delivery.UltimoCodigo = &code
+	}
+	if err == nil {
+		sentAt := time.Now()
+		delivery.Estado = domain.WebhookEnviado
+		delivery.FechaEnvio = &sentAt
+		if webhook.FallosConsecutivos > 0 {
+			if err := s.webhookRepo.RecordSuccess(webhook.ID); err != nil {
+				log.Printf("Failed to reset failures of webhook %d: %v", webhook.ID, err)
+			}
+			webhook.FallosConsecutivos = 0
+		}
+		return true
+	}
+
+	message := err.Error()
+	delivery.UltimoError = &message
+	if delivery.Intentos >= s.settings.MaxAttempts {
+		delivery.Estado = domain.WebhookFallido
+	} else {
+		next := now.Add(webhookRetryDelay(delivery.Intentos))
+		delivery.ProximoIntento = &next
+	}
+	log.Printf("Failed to deliver webhook %d delivery %d (attempt %d): %v", webhook.ID, delivery.ID, delivery.Intentos, err)
+
+	webhook.FallosConsecutivos++
+	reason := fmt.Sprintf("%d failed deliveries in a row; last: %s", s.settings.DisableAfter, message)
+	disabled, err := s.webhookRepo.RecordFailure(webhook.ID, s.settings.DisableAfter, reason, now)
+	if err != nil {
+		log.Printf("Failed to record failure of webhook %d: %v", webhook.ID, err)
+	} else if disabled {
+		webhook.Activo = false
+		webhook.FechaDesactivacion = &now
+		webhook.MotivoDesactivacion = &reason
+		log.Printf("Webhook %d deactivated after %d failed deliveries in a row", webhook.ID, s.settings.DisableAfter)
+	}
+	return false
+}
+
+// post signs and sends the payload, returning the response status
+func (s *webhookService) post(webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PlotCenter-Webhooks/1.0")
+	req.Header.Set(WebhookHeaderEvent, delivery.Evento)
+	req.Header.Set(WebhookHeaderEventID, delivery.IDEvento)
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderSignature, "sha256="+SignWebhook(webhook.Secreto, timestamp, []byte(delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorLen))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := resp.Status
+		if body := strings.TrimSpace(string(excerpt)); body != "" {
+			message += ": " + body
+		}
+		return resp.StatusCode, errors.New(message)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with
+// the secret, as sent in the signature header
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookService) validateInput(input *WebhookInput) error {
+	input.URL = strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(input.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(input.URL) > 500 {
+		return errors.New("url is too long")
+	}
+	if input.Descripcion != nil {
+		descripcion := strings.TrimSpace(*input.Descripcion)
+		input.Descripcion = &descripcion
+		if descripcion == "" {
+			input.Descripcion = nil
+		}
+	}
+
+	for _, evento := range input.Eventos {
+		if !slices.Contains(WebhookEvents, evento) {
+			return fmt.Errorf("unknown event %q", evento)
+		}
+	}
+	for _, estado := range input.Estados {
+		if !s.workflow.HasState(estado) {
+			return fmt.Errorf("unknown estado %q", estado)
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) authorize(userID uint) (*domain.User, error) {
+	return authorizeUser(s.userRepo, userID, authz.PermWebhookManage)
+}
+
+// webhookRetryDelay backs off 30s, 1m, 2m, ... after each failed attempt
+func webhookRetryDelay(attempts int) time.Duration {
+	if attempts > 8 {
+		return maxWebhookRetryDelay
+	}
+	return min(webhookFirstRetry<<(attempts-1), maxWebhookRetryDelay)
+}
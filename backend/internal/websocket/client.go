@@ -13,7 +13,10 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+// HandleWebSocket upgrades the request and registers the connection for the
+// user, so messages sent to that user reach it. Broadcasts only reach it when
+// rol, the role in the access token, holds their permission.
+func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request, userID uint, rol string) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
@@ -21,9 +24,11 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:    h,
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		userID: userID,
+		rol:    rol,
 	}
 
 	h.Register(client)
@@ -1,8 +1,11 @@
 package websocket
 
 import (
+	"encoding/json"
 	"log"
 	"sync"
+	"task-board/internal/authz"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -10,14 +13,29 @@ type Hub struct {
 	clients    map[*Client]bool
 	register   chan *Client
 	unregister chan *Client
-	broadcast  chan []byte
+	broadcast  chan broadcastMessage
+	direct     chan directMessage
 	mutex      sync.RWMutex
 }
 
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan []byte
+	userID uint   // 0 when the connection isn't tied to a user
+	rol    string // from the access token; empty for anonymous sessions
+}
+
+// broadcastMessage is a message for every connection whose role holds perm
+type broadcastMessage struct {
+	perm    authz.Permission
+	message []byte
+}
+
+// directMessage is a message for the connections of a single user
+type directMessage struct {
+	userID  uint
+	message []byte
 }
 
 type Message struct {
@@ -30,7 +48,8 @@ func NewHub() *Hub {
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan broadcastMessage),
+		direct:     make(chan directMessage),
 	}
 }
 
@@ -52,17 +71,36 @@ func (h *Hub) Run() {
 			h.mutex.Unlock()
 			log.Printf("Client disconnected. Total clients: %d", len(h.clients))
 
-		case message := <-h.broadcast:
-			h.mutex.RLock()
+		case broadcast := <-h.broadcast:
+			h.mutex.Lock()
 			for client := range h.clients {
+				// Anonymous connections have no role, so they never qualify
+				if !authz.Can(client.rol, broadcast.perm) {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- broadcast.message:
 				default:
 					close(client.send)
 					delete(h.clients, client)
 				}
 			}
-			h.mutex.RUnlock()
+			h.mutex.Unlock()
+
+		case direct := <-h.direct:
+			h.mutex.Lock()
+			for client := range h.clients {
+				if client.userID != direct.userID {
+					continue
+				}
+				select {
+				case client.send <- direct.message:
+				default:
+					close(client.send)
+					delete(h.clients, client)
+				}
+			}
+			h.mutex.Unlock()
 		}
 	}
 }
@@ -75,6 +113,29 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
-func (h *Hub) Broadcast(message []byte) {
-	h.broadcast <- message
+// Broadcast sends the message to every client whose role holds perm
+func (h *Hub) Broadcast(perm authz.Permission, message []byte) {
+	h.broadcast <- broadcastMessage{perm: perm, message: message}
+}
+
+// BroadcastMessage wraps data in a typed Message and sends it to every client
+// whose role holds perm
+func (h *Hub) BroadcastMessage(perm authz.Permission, msgType string, data interface{}) error {
+	message, err := json.Marshal(Message{Type: msgType, Data: data})
+	if err != nil {
+		return err
+	}
+	h.Broadcast(perm, message)
+	return nil
+}
+
+// SendToUser wraps data in a typed Message and sends it only to the
+// connections of the user
+func (h *Hub) SendToUser(userID uint, msgType string, data interface{}) error {
+	message, err := json.Marshal(Message{Type: msgType, Data: data})
+	if err != nil {
+		return err
+	}
+	h.direct <- directMessage{userID: userID, message: message}
+	return nil
 }
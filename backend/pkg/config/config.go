@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -22,6 +24,11 @@ type Config struct {
 	// JWT
 	JWTSecret string
 	JWTExpiry  time.Duration
+	RefreshTokenExpiry time.Duration
+
+	// Initial administracion user, created when there are no users
+	AdminNombre   string
+	AdminPassword string
 
 	// Server
 	Host string
@@ -29,6 +36,74 @@ type Config struct {
 
 	// CORS
 	CORSOrigin string
+
+	// Orders
+	OrderWorkflowFile string
+	OrderClaimTimeout  time.Duration
+	OrderNumberPattern string
+	ChecklistRequiredStates string
+
+	// Planning
+	PlanningComplexityHours string
+
+	// Time zone the shop works in
+	TimeZone string
+
+	// Alerts
+	AlertScanInterval    time.Duration
+	AlertStalledAfter    string
+	AlertStalledByState  string
+	AlertDeadlineWarning string
+	AlertDeadlineByState string
+
+	// Smart alerts
+	SmartAlertInterval       time.Duration
+	SmartAlertOperatorOrders int
+	SmartAlertQueueWindow    time.Duration
+	SmartAlertQueueGrowth    int
+	SmartAlertSlowdown       float64
+
+	// Attachments
+	AttachmentMaxSize int64
+	StorageDriver     string
+	StorageLocalDir   string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKey       string
+	S3SecretKey       string
+	S3PathStyle       bool
+
+	// Order links
+	LinkSchemes      string
+	LinkAllowedHosts string
+	LinkDeniedHosts  string
+	LinkFetchTitles  bool
+	LinkFetchTimeout time.Duration
+
+	// Email notifications
+	SMTPHost              string
+	SMTPPort              string
+	SMTPUsername          string
+	SMTPPassword          string
+	SMTPFrom              string
+	SMTPTLS               string
+	SMTPTimeout           time.Duration
+	EmailDispatchInterval time.Duration
+	EmailMaxAttempts      int
+	EmailDigestHour       int
+	AppURL                string
+
+	// Webhooks
+	WebhookTimeout          time.Duration
+	WebhookMaxAttempts      int
+	WebhookDisableAfter     int
+	WebhookDispatchInterval time.Duration
+
+	// Outbox of domain events
+	OutboxDispatchInterval time.Duration
+	OutboxMaxAttempts      int
+	OutboxRetention        time.Duration
 }
 
 func Load() *Config {
@@ -49,7 +124,12 @@ func Load() *Config {
 
 		// JWT
 		JWTSecret: getEnv("JWT_SECRET", "your-secret-key-here"),
-		JWTExpiry: parseDuration(getEnv("JWT_EXPIRY", "24h")),
+		JWTExpiry: parseDuration(getEnv("JWT_EXPIRY", "15m")),
+		RefreshTokenExpiry: getDuration("REFRESH_TOKEN_EXPIRY", 30*24*time.Hour),
+
+		// Initial administracion user
+		AdminNombre:   getEnv("ADMIN_NOMBRE", ""),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
 
 		// Server
 		Host: getEnv("HOST", "0.0.0.0"),
@@ -57,6 +137,74 @@ func Load() *Config {
 
 		// CORS
 		CORSOrigin: getEnv("CORS_ORIGIN", "http://localhost:3000"),
+
+		// Orders
+		OrderWorkflowFile: getEnv("ORDER_WORKFLOW_FILE", ""),
+		OrderClaimTimeout:  getDuration("ORDER_CLAIM_TIMEOUT", 2*time.Hour),
+		OrderNumberPattern: getEnv("ORDER_NUMBER_PATTERN", "OP-{YYYY}-{SEQ:5}"),
+		ChecklistRequiredStates: getEnv("CHECKLIST_REQUIRED_STATES", ""),
+
+		// Planning
+		PlanningComplexityHours: getEnv("PLANNING_COMPLEXITY_HOURS", "Baja=2,Media=4,Alta=8"),
+
+		// Time zone
+		TimeZone: getEnv("TIMEZONE", "America/Argentina/Buenos_Aires"),
+
+		// Alerts
+		AlertScanInterval:    getDuration("ALERT_SCAN_INTERVAL", 15*time.Minute),
+		AlertStalledAfter:    getEnv("ALERT_STALLED_AFTER", "3d"),
+		AlertStalledByState:  getEnv("ALERT_STALLED_BY_STATE", ""),
+		AlertDeadlineWarning: getEnv("ALERT_DEADLINE_WARNING", "24h"),
+		AlertDeadlineByState: getEnv("ALERT_DEADLINE_BY_STATE", ""),
+
+		// Smart alerts
+		SmartAlertInterval:       getDuration("SMART_ALERT_INTERVAL", 30*time.Minute),
+		SmartAlertOperatorOrders: getInt("SMART_ALERT_OPERATOR_MAX_ORDERS", 8),
+		SmartAlertQueueWindow:    getDuration("SMART_ALERT_QUEUE_WINDOW", 7*24*time.Hour),
+		SmartAlertQueueGrowth:    getInt("SMART_ALERT_QUEUE_GROWTH", 5),
+		SmartAlertSlowdown:       getFloat("SMART_ALERT_SLOWDOWN_FACTOR", 1.5),
+
+		// Attachments
+		AttachmentMaxSize: getSize("MAX_FILE_SIZE", 50<<20),
+		StorageDriver:     getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalDir:   getEnv("UPLOAD_DIR", "./uploads"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3AccessKey:       getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:       getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:       getBool("S3_PATH_STYLE", true),
+
+		// Order links
+		LinkSchemes:      getEnv("LINK_ALLOWED_SCHEMES", "http,https"),
+		LinkAllowedHosts: getEnv("LINK_ALLOWED_HOSTS", ""),
+		LinkDeniedHosts:  getEnv("LINK_DENIED_HOSTS", ""),
+		LinkFetchTitles:  getBool("LINK_FETCH_TITLES", false),
+		LinkFetchTimeout: getDuration("LINK_FETCH_TIMEOUT", 5*time.Second),
+
+		// Email notifications
+		SMTPHost:              getEnv("SMTP_HOST", ""),
+		SMTPPort:              getEnv("SMTP_PORT", "587"),
+		SMTPUsername:          getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:          getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:              getEnv("SMTP_FROM", ""),
+		SMTPTLS:               getEnv("SMTP_TLS", "starttls"),
+		SMTPTimeout:           getDuration("SMTP_TIMEOUT", 30*time.Second),
+		EmailDispatchInterval: getDuration("EMAIL_DISPATCH_INTERVAL", time.Minute),
+		EmailMaxAttempts:      getInt("EMAIL_MAX_ATTEMPTS", 5),
+		EmailDigestHour:       getInt("EMAIL_DIGEST_HOUR", 8),
+		AppURL:                getEnv("APP_URL", ""),
+
+		// Webhooks
+		WebhookTimeout:          getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:      getInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookDisableAfter:     getInt("WEBHOOK_DISABLE_AFTER", 20),
+		WebhookDispatchInterval: getDuration("WEBHOOK_DISPATCH_INTERVAL", 10*time.Second),
+
+		// Outbox of domain events
+		OutboxDispatchInterval: getDuration("OUTBOX_DISPATCH_INTERVAL", 2*time.Second),
+		OutboxMaxAttempts:      getInt("OUTBOX_MAX_ATTEMPTS", 12),
+		OutboxRetention:        getDuration("OUTBOX_RETENTION", 7*24*time.Hour),
 	}
 }
 
@@ -74,3 +222,56 @@ func parseDuration(s string) time.Duration {
 	}
 	return duration
 }
+
+// getSize reads a byte size such as "50MB", "512KB" or "1048576"
+func getSize(key string, defaultValue int64) int64 {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return defaultValue
+	}
+	return size * multiplier
+}
+
+func getInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return duration
+}
@@ -24,11 +24,44 @@ func Initialize(cfg *config.Config) (*gorm.DB, error) {
 		&domain.User{},
 		&domain.Board{},
 		&domain.Task{},
+		&domain.Material{},
+		&domain.Sector{},
+		&domain.Order{},
+		&domain.OrderMaterial{},
+		&domain.OrderSector{},
+		&domain.Attachment{},
+		&domain.MovementHistory{},
+		&domain.OrderTask{},
+		&domain.OrderComment{},
+		&domain.OrderLink{},
+		&domain.OrderNumberSequence{},
+		&domain.ReservedOrderNumber{},
+		&domain.RefreshToken{},
+		&domain.StockMovement{},
+		&domain.UserNotification{},
+		&domain.NotificationViewed{},
+		&domain.AlertSent{},
+		&domain.SmartAlert{},
+		&domain.NotificationPreference{},
+		&domain.QuietHours{},
+		&domain.EmailPreference{},
+		&domain.EmailDelivery{},
+		&domain.Webhook{},
+		&domain.WebhookDelivery{},
+		&domain.OutboxEvent{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Anonymous sessions used to get the taller role; they get none now
+	err = db.Model(&domain.User{}).
+		Where("nombre LIKE ? AND rol <> ?", domain.AnonymousNamePrefix+"%", domain.RolAnonimo).
+		Update("rol", domain.RolAnonimo).Error
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
@@ -0,0 +1,173 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"regexp"
+	"strconv"
+
+	// Decoders for the formats previews are made from
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels bounds the images that get decoded, so a small file declaring
+// huge dimensions can't exhaust memory
+const MaxPixels = 60_000_000
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels
+var ErrTooLarge = errors.New("image is too large to preview")
+
+// jpegQuality balances size and legibility of previews
+const jpegQuality = 82
+
+// Decode reads an image after checking its declared dimensions
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// Fit scales src down so it fits in a maxSide x maxSide box, averaging the
+// source pixels each target pixel covers. Smaller images are only flattened.
+// Transparency is flattened onto white, since previews are JPEG.
+func Fit(src image.Image, maxSide int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			dw, dh = maxSide, max(1, h*maxSide/w)
+		} else {
+			dw, dh = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := bounds.Min.Y + y*h/dh
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*h/dh)
+		for x := 0; x < dw; x++ {
+			x0 := bounds.Min.X + x*w/dw
+			x1 := max(x0+1, bounds.Min.X+(x+1)*w/dw)
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					// Premultiplied colors over white: c + (1 - a)
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					b += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG encodes a preview
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var mediaBoxPattern = regexp.MustCompile(`/MediaBox\s*\[\s*(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s*\]`)
+
+// PDFPageRatio looks for the first /MediaBox in the start of a PDF and returns
+// its width / height. It falls back to A4 portrait.
+func PDFPageRatio(head []byte) float64 {
+	const a4 = 210.0 / 297.0
+	match := mediaBoxPattern.FindSubmatch(head)
+	if match == nil {
+		return a4
+	}
+	var box [4]float64
+	for i := range box {
+		v, err := strconv.ParseFloat(string(match[i+1]), 64)
+		if err != nil {
+			return a4
+		}
+		box[i] = v
+	}
+	w, h := box[2]-box[0], box[3]-box[1]
+	if w <= 0 || h <= 0 || w/h > 10 || h/w > 10 {
+		return a4
+	}
+	return w / h
+}
+
+// PDFPlaceholder draws a stand-in for the first page of a PDF: a blank page
+// with the document's proportions, a folded corner and a red PDF band
+func PDFPlaceholder(ratio float64, maxSide int) *image.RGBA {
+	w, h := maxSide, maxSide
+	if ratio >= 1 {
+		h = max(1, int(float64(maxSide)/ratio))
+	} else {
+		w = max(1, int(float64(maxSide)*ratio))
+	}
+
+	var (
+		background = color.RGBA{0xf3, 0xf4, 0xf6, 0xff}
+		page       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+		border     = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+		fold       = color.RGBA{0xd1, 0xd5, 0xdb, 0xff}
+		band       = color.RGBA{0xdc, 0x26, 0x26, 0xff}
+		line       = color.RGBA{0xe5, 0xe7, 0xeb, 0xff}
+	)
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	margin := max(1, min(w, h)/20)
+	sheet := image.Rect(margin, margin, w-margin, h-margin)
+	draw.Draw(img, sheet, &image.Uniform{border}, image.Point{}, draw.Src)
+	draw.Draw(img, sheet.Inset(1), &image.Uniform{page}, image.Point{}, draw.Src)
+
+	// Folded top-right corner
+	corner := sheet.Dx() / 5
+	for cy := 0; cy < corner; cy++ {
+		for cx := 0; cx < corner; cx++ {
+			c := fold
+			if cx > cy {
+				c = background
+			}
+			img.Set(sheet.Max.X-corner+cx, sheet.Min.Y+cy, c)
+		}
+	}
+
+	// Text lines and the PDF band
+	inner := sheet.Inset(sheet.Dx() / 8)
+	lineHeight := max(1, inner.Dy()/40)
+	for y := inner.Min.Y + corner; y+lineHeight < inner.Max.Y-inner.Dy()/4; y += lineHeight * 3 {
+		draw.Draw(img, image.Rect(inner.Min.X, y, inner.Max.X, y+lineHeight), &image.Uniform{line}, image.Point{}, draw.Src)
+	}
+	bandTop := sheet.Max.Y - sheet.Dy()/5
+	draw.Draw(img, image.Rect(sheet.Min.X, bandTop, sheet.Min.X+sheet.Dx()*3/5, bandTop+sheet.Dy()/10), &image.Uniform{band}, image.Point{}, draw.Src)
+
+	return img
+}
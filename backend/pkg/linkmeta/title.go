@@ -0,0 +1,155 @@
+package linkmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxRedirects bounds the redirects followed to reach a page
+	maxRedirects = 3
+	// maxHeadLen is how much of a page is searched for its title
+	maxHeadLen = 256 << 10
+	// maxTitleLen bounds a captured title, in characters
+	maxTitleLen = 255
+)
+
+// ErrBlockedAddress is returned for hosts that resolve to loopback, private or
+// otherwise internal addresses
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// Fetcher reads the <title> of public web pages. Every redirect goes through
+// Check, and connections to internal addresses are refused, so a link can't be
+// used to probe the server's network.
+type Fetcher struct {
+	client *http.Client
+	// allowed decides which addresses can be dialed
+	allowed func(netip.AddrPort) bool
+}
+
+// NewFetcher returns a Fetcher that gives up after timeout. check validates
+// every URL on the way, redirects included.
+func NewFetcher(timeout time.Duration, check func(*url.URL) error) *Fetcher {
+	f := &Fetcher{
+		allowed: func(addrPort netip.AddrPort) bool { return isPublic(addrPort.Addr()) },
+	}
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !f.allowed(addrPort) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // a proxy would make the dial-time check meaningless
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Minute,
+	}
+	f.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return check(req.URL)
+		},
+	}
+	return f
+}
+
+// Title returns the title of the HTML page at rawURL. Pages that aren't HTML
+// or have no title give an empty string.
+func (f *Fetcher) Title(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "TaskBoard-LinkPreview/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(resp.Body, maxHeadLen))
+	if err != nil {
+		return "", err
+	}
+	return extractTitle(head), nil
+}
+
+// extractTitle returns the unescaped, whitespace-collapsed page title
+func extractTitle(page []byte) string {
+	match := titlePattern.FindSubmatch(page)
+	if match == nil || !utf8.Valid(match[1]) {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " ")
+	if utf8.RuneCountInString(title) > maxTitleLen {
+		title = string([]rune(title)[:maxTitleLen-1]) + "…"
+	}
+	return title
+}
+
+// nonPublicPrefixes are unicast ranges that netip doesn't consider private but
+// that never lead to a public web server
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, shared by many private networks
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	// IPv6 ranges that embed an IPv4 address, which could be an internal one
+	netip.MustParsePrefix("::/96"),          // IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4
+}
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
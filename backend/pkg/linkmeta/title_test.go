@@ -0,0 +1,200 @@
+package linkmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"151.101.1.69", true},
+		{"2001:4860:4860::8888", true},
+		{"::ffff:8.8.8.8", true},
+
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.10", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false}, // CGNAT
+		{"100.127.255.254", false},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::7f00:1", false}, // IPv4-compatible 127.0.0.1
+		{"64:ff9b::a00:1", false},
+		{"2002:7f00:1::1", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"ff02::1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestExtractTitle(t *testing.T) {
+	long := strings.Repeat("á", 300)
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{"plain", "<html><head><title>Plano OP-12</title></head></html>", "Plano OP-12"},
+		{"attributes and case", `<TITLE lang="es">Presupuesto</TITLE>`, "Presupuesto"},
+		{"entities", "<title>Tom &amp; Jerry &#8211; &quot;final&quot;</title>", `Tom & Jerry – "final"`},
+		{"whitespace", "<title>\n\t Carpeta   compartida \n</title>", "Carpeta compartida"},
+		{"first title", "<title>Uno</title><svg><title>Dos</title></svg>", "Uno"},
+		{"missing", "<html><body>Sin título</body></html>", ""},
+		{"unclosed", "<title>Sin cerrar", ""},
+		{"invalid utf-8", "<title>\xff\xfe</title>", ""},
+		{"truncated", "<title>" + long + "</title>", strings.Repeat("á", maxTitleLen-1) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractTitle([]byte(tt.page)); got != tt.want {
+				t.Errorf("extractTitle = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+var errDenied = errors.New("denied by the link policy")
+
+// denyHost is a check that rejects a single host
+func denyHost(host string) func(*url.URL) error {
+	return func(u *url.URL) error {
+		if u.Hostname() == host {
+			return fmt.Errorf("%w: %s", errDenied, host)
+		}
+		return nil
+	}
+}
+
+// countingServer starts a server and counts the requests that reach it
+func countingServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func servePage(title string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", title)
+	}
+}
+
+func TestFetcherRefusesInternalAddresses(t *testing.T) {
+	server, hits := countingServer(t, servePage("Interno"))
+	f := NewFetcher(time.Second, denyHost("none"))
+
+	for _, target := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := f.Title(context.Background(), target); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Title(%s) = %v, want ErrBlockedAddress", target, err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("the internal server got %d requests", n)
+	}
+}
+
+func TestFetcherRedirects(t *testing.T) {
+	// internal plays a host on the server's network: it can't be dialed
+	internal, internalHits := countingServer(t, servePage("Interno"))
+
+	public, _ := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			servePage("Público")(w, r)
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/to-internal":
+			http.Redirect(w, r, internal.URL+"/", http.StatusFound)
+		case "/to-denied":
+			http.Redirect(w, r, "http://intranet.example/", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.7"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	f := NewFetcher(time.Second, denyHost("intranet.example"))
+	// Only the public server's address can be dialed, as if it were on the internet
+	publicAddr := netip.MustParseAddrPort(public.Listener.Addr().String())
+	f.allowed = func(addrPort netip.AddrPort) bool { return addrPort == publicAddr }
+
+	t.Run("followed", func(t *testing.T) {
+		title, err := f.Title(context.Background(), public.URL+"/moved")
+		if err != nil || title != "Público" {
+			t.Errorf("Title = %q, %v; want the redirected page's title", title, err)
+		}
+	})
+
+	t.Run("to an internal address", func(t *testing.T) {
+		if _, err := f.Title(context.Background(), public.URL+"/to-internal"); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Title = %v, want ErrBlockedAddress", err)
+		}
+		if n := internalHits.Load(); n != 0 {
+			t.Errorf("the internal server got %d requests", n)
+		}
+	})
+
+	t.Run("to a denied host", func(t *testing.T) {
+		if _, err := f.Title(context.Background(), public.URL+"/to-denied"); !errors.Is(err, errDenied) {
+			t.Errorf("Title = %v, want the policy's error", err)
+		}
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		if _, err := f.Title(context.Background(), public.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "too many redirects") {
+			t.Errorf("Title = %v, want too many redirects", err)
+		}
+	})
+
+	t.Run("not html", func(t *testing.T) {
+		if title, err := f.Title(context.Background(), public.URL+"/pdf"); err != nil || title != "" {
+			t.Errorf("Title = %q, %v; want no title and no error", title, err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := f.Title(context.Background(), public.URL+"/missing"); err == nil {
+			t.Error("Title of a 404 page succeeded")
+		}
+	})
+}
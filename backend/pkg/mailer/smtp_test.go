@@ -0,0 +1,597 @@
+package mailer_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"task-board/internal/domain"
+	"task-board/internal/repository"
+	"task-board/internal/service"
+	"task-board/pkg/mailer"
+	"testing"
+	"time"
+)
+
+// session is what the fake server got over one connection
+type session struct {
+	tls  bool
+	auth string // user:password of the last AUTH PLAIN
+	from string
+	to   []string
+	data []byte
+}
+
+type serverOptions struct {
+	// implicitTLS serves TLS from the first byte; startTLS offers the upgrade
+	implicitTLS bool
+	startTLS    bool
+	// username and password are the credentials AUTH PLAIN accepts
+	username string
+	password string
+	// rejectRcpt maps recipients to the reply refusing them
+	rejectRcpt map[string]string
+}
+
+// fakeServer is a minimal SMTP server on a loopback port
+type fakeServer struct {
+	options   serverOptions
+	listener  net.Listener
+	tlsConfig *tls.Config
+	roots     *x509.CertPool
+	sessions  chan session
+}
+
+func startServer(t *testing.T, options serverOptions) *fakeServer {
+	t.Helper()
+	certificate, roots := newCertificate(t)
+	server := &fakeServer{
+		options:   options,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{certificate}},
+		roots:     roots,
+		sessions:  make(chan session, 4),
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if options.implicitTLS {
+		listener = tls.NewListener(listener, server.tlsConfig)
+	}
+	server.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+	return server
+}
+
+// config points a mailer at the server, trusting its certificate
+func (s *fakeServer) config(mode string) mailer.Config {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return mailer.Config{
+		Host:    "127.0.0.1",
+		Port:    port,
+		From:    "Plot Center <notificaciones@plotcenter.test>",
+		TLS:     mode,
+		Timeout: 5 * time.Second,
+		RootCAs: s.roots,
+	}
+}
+
+// next waits for the server to finish the next connection
+func (s *fakeServer) next(t *testing.T) session {
+	t.Helper()
+	select {
+	case sess := <-s.sessions:
+		return sess
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server got no connection")
+		return session{}
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var sess session
+	_, sess.tls = conn.(*tls.Conn)
+	text := textproto.NewConn(conn)
+	defer func() {
+		text.Close()
+		s.sessions <- sess
+	}()
+
+	reply := func(line string) {
+		text.PrintfLine("%s", line)
+	}
+	reply("220 fake.test ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			extensions := []string{"fake.test", "8BITMIME", "AUTH PLAIN"}
+			if s.options.startTLS && !sess.tls {
+				extensions = append(extensions, "STARTTLS")
+			}
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				reply("250" + separator + extension)
+			}
+		case "STARTTLS":
+			reply("220 2.0.0 Ready to start TLS")
+			upgraded := tls.Server(conn, s.tlsConfig)
+			if err := upgraded.Handshake(); err != nil {
+				return
+			}
+			// The client starts over once the connection is secure
+			text = textproto.NewConn(upgraded)
+			sess = session{tls: true}
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(decoded), "\x00")
+			if mechanism != "PLAIN" || err != nil || len(parts) != 3 {
+				reply("504 5.5.4 Unrecognized authentication type")
+				continue
+			}
+			sess.auth = parts[1] + ":" + parts[2]
+			if parts[1] != s.options.username || parts[2] != s.options.password {
+				reply("535 5.7.8 Authentication credentials invalid")
+				continue
+			}
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			sess.from = envelopeAddress(arg, "FROM:")
+			reply("250 2.1.0 OK")
+		case "RCPT":
+			to := envelopeAddress(arg, "TO:")
+			if refusal, ok := s.options.rejectRcpt[to]; ok {
+				reply(refusal)
+				continue
+			}
+			sess.to = append(sess.to, to)
+			reply("250 2.1.5 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			if sess.data, err = text.ReadDotBytes(); err != nil {
+				return
+			}
+			reply("250 2.0.0 Queued")
+		case "RSET", "NOOP":
+			reply("250 2.0.0 OK")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("502 5.5.2 Command not recognized")
+		}
+	}
+}
+
+// envelopeAddress takes the address out of "FROM:<a@b> BODY=8BITMIME"
+func envelopeAddress(arg, prefix string) string {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return ""
+	}
+	address, _, _ := strings.Cut(arg[len(prefix):], " ")
+	return strings.Trim(address, "<>")
+}
+
+// newCertificate makes a self-signed certificate for 127.0.0.1 and a pool
+// that trusts it
+func newCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
+
+func newMailer(t *testing.T, config mailer.Config) *mailer.Mailer {
+	t.Helper()
+	m, err := mailer.New(config)
+	if err != nil {
+		t.Fatalf("mailer.New: %v", err)
+	}
+	return m
+}
+
+// checkMessage asserts on the envelope, the headers and the decoded body
+func checkMessage(t *testing.T, sess session, msg mailer.Message) {
+	t.Helper()
+	if sess.from != "notificaciones@plotcenter.test" {
+		t.Errorf("MAIL FROM = %q", sess.from)
+	}
+	if !slices.Equal(sess.to, []string{msg.To}) {
+		t.Errorf("RCPT TO = %q, want %q", sess.to, msg.To)
+	}
+
+	for _, line := range strings.Split(string(sess.data), "\n") {
+		if len(line) > 78 {
+			t.Errorf("line longer than 78 characters: %q", line)
+		}
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(sess.data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Plot Center" || from[0].Address != "notificaciones@plotcenter.test" {
+		t.Errorf("From = %q (%v)", parsed.Header.Get("From"), err)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Address != msg.To {
+		t.Errorf("To = %q (%v)", parsed.Header.Get("To"), err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@plotcenter.test>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+	for name, want := range map[string]string{
+		"MIME-Version":              "1.0",
+		"Content-Type":              "text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	} {
+		if got := parsed.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if string(body) != msg.Body {
+		t.Errorf("body = %q, want %q", body, msg.Body)
+	}
+}
+
+var testMessage = mailer.Message{
+	To:      "operario@plotcenter.test",
+	Subject: "Orden OP-0042 lista para instalación",
+	Body: "Hola Ana,\n\nLa orden de señalética para Café Río pasó a Instalaciones. " +
+		"Esta línea es larga a propósito, para que el cuerpo se parta en varias líneas codificadas.\n" +
+		"= signos y acentos: ñ á é í ó ú\n",
+}
+
+func TestSendPlain(t *testing.T) {
+	server := startServer(t, serverOptions{})
+	m := newMailer(t, server.config(mailer.TLSNone))
+
+	if err := m.Send(testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sess := server.next(t)
+	if sess.tls {
+		t.Error("plain mode used TLS")
+	}
+	if sess.auth != "" {
+		t.Errorf("authenticated as %q without credentials", sess.auth)
+	}
+	checkMessage(t, sess, testMessage)
+}
+
+func TestSendStartTLS(t *testing.T) {
+	server := startServer(t, serverOptions{startTLS: true, username: "avisos", password: "s3creto"})
+	config := server.config(mailer.TLSStartTLS)
+	config.Username, config.Password = "avisos", "s3creto"
+	m := newMailer(t, config)
+
+	if err := m.Send(testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sess := server.next(t)
+	if !sess.tls {
+		t.Error("the message was sent before STARTTLS")
+	}
+	if sess.auth != "avisos:s3creto" {
+		t.Errorf("AUTH PLAIN = %q", sess.auth)
+	}
+	checkMessage(t, sess, testMessage)
+}
+
+func TestSendStartTLSRequired(t *testing.T) {
+	server := startServer(t, serverOptions{})
+	config := server.config(mailer.TLSStartTLS)
+	config.Username, config.Password = "avisos", "s3creto"
+	m := newMailer(t, config)
+
+	err := m.Send(testMessage)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send = %v, want a STARTTLS error", err)
+	}
+	if mailer.IsPermanent(err) {
+		t.Error("a missing STARTTLS is a server problem, not a refused message")
+	}
+	if sess := server.next(t); sess.auth != "" || sess.from != "" || sess.data != nil {
+		t.Errorf("sent in the clear: %+v", sess)
+	}
+}
+
+func TestSendVerifiesCertificate(t *testing.T) {
+	server := startServer(t, serverOptions{startTLS: true})
+	config := server.config(mailer.TLSStartTLS)
+	config.RootCAs = x509.NewCertPool()
+	m := newMailer(t, config)
+
+	var unknown x509.UnknownAuthorityError
+	if err := m.Send(testMessage); !errors.As(err, &unknown) {
+		t.Fatalf("Send = %v, want an unknown authority error", err)
+	}
+	if sess := server.next(t); sess.from != "" {
+		t.Errorf("sent to an untrusted server: %+v", sess)
+	}
+}
+
+func TestSendImplicitTLS(t *testing.T) {
+	server := startServer(t, serverOptions{implicitTLS: true, username: "avisos", password: "s3creto"})
+	config := server.config(mailer.TLSImplicit)
+	config.Username, config.Password = "avisos", "s3creto"
+	m := newMailer(t, config)
+
+	if err := m.Send(testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sess := server.next(t)
+	if !sess.tls {
+		t.Error("implicit mode didn't use TLS")
+	}
+	if sess.auth != "avisos:s3creto" {
+		t.Errorf("AUTH PLAIN = %q", sess.auth)
+	}
+	checkMessage(t, sess, testMessage)
+}
+
+func TestSendAuthRejected(t *testing.T) {
+	server := startServer(t, serverOptions{startTLS: true, username: "avisos", password: "s3creto"})
+	config := server.config(mailer.TLSStartTLS)
+	config.Username, config.Password = "avisos", "otra"
+	m := newMailer(t, config)
+
+	err := m.Send(testMessage)
+	if err == nil {
+		t.Fatal("Send succeeded with the wrong password")
+	}
+	if !mailer.IsPermanent(err) {
+		t.Errorf("IsPermanent(%v) = false", err)
+	}
+	if sess := server.next(t); sess.from != "" {
+		t.Errorf("sent without authenticating: %+v", sess)
+	}
+}
+
+func TestSendRecipientRefused(t *testing.T) {
+	server := startServer(t, serverOptions{rejectRcpt: map[string]string{
+		"nadie@plotcenter.test": "550 5.1.1 No such user",
+		"lleno@plotcenter.test": "452 4.2.2 Mailbox full",
+	}})
+	m := newMailer(t, server.config(mailer.TLSNone))
+
+	for to, permanent := range map[string]bool{"nadie@plotcenter.test": true, "lleno@plotcenter.test": false} {
+		msg := testMessage
+		msg.To = to
+		err := m.Send(msg)
+		if err == nil {
+			t.Fatalf("Send to %s succeeded", to)
+		}
+		if mailer.IsPermanent(err) != permanent {
+			t.Errorf("IsPermanent(%v) = %v, want %v", err, !permanent, permanent)
+		}
+		if sess := server.next(t); sess.data != nil {
+			t.Errorf("sent the message to a refused recipient: %+v", sess)
+		}
+	}
+
+	msg := testMessage
+	msg.To = "no es una dirección"
+	if err := m.Send(msg); !mailer.IsPermanent(err) {
+		t.Errorf("Send to an invalid address = %v, want a permanent error", err)
+	}
+}
+
+// fakeEmailRepository keeps the email queue in memory; the methods a test
+// doesn't expect are left to the nil interface and panic
+type fakeEmailRepository struct {
+	repository.EmailRepository
+	candidates []repository.DigestCandidate
+	waiting    map[uint][]domain.UserNotification
+	deliveries []domain.EmailDelivery
+	// digested maps each digest delivery to the notifications it carries
+	digested map[uint][]uint
+}
+
+func (r *fakeEmailRepository) ListDigestCandidates(modes []string) ([]repository.DigestCandidate, error) {
+	var candidates []repository.DigestCandidate
+	for _, candidate := range r.candidates {
+		if slices.Contains(modes, candidate.Modo) && len(r.waiting[candidate.IDUsuario]) > 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates, nil
+}
+
+func (r *fakeEmailRepository) ListWaiting(userID uint) ([]domain.UserNotification, error) {
+	return r.waiting[userID], nil
+}
+
+func (r *fakeEmailRepository) QueueDigest(delivery *domain.EmailDelivery, notificationIDs []uint, now time.Time) error {
+	delivery.ID = uint(len(r.deliveries) + 1)
+	r.deliveries = append(r.deliveries, *delivery)
+	r.digested[delivery.ID] = notificationIDs
+	delete(r.waiting, delivery.IDUsuario)
+	return nil
+}
+
+func (r *fakeEmailRepository) LeaseDue(now, leaseUntil time.Time, limit int) ([]domain.EmailDelivery, error) {
+	var due []domain.EmailDelivery
+	for i := range r.deliveries {
+		delivery := &r.deliveries[i]
+		if len(due) < limit && delivery.Estado == domain.EnvioPendiente && !delivery.ProximoIntento.After(now) {
+			delivery.ProximoIntento = &leaseUntil
+			due = append(due, *delivery)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeEmailRepository) UpdateAttempt(delivery *domain.EmailDelivery) error {
+	r.deliveries[delivery.ID-1] = *delivery
+	return nil
+}
+
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[uint]*domain.User
+}
+
+func (r fakeUserRepository) GetByID(id uint) (*domain.User, error) {
+	return r.users[id], nil
+}
+
+type fakePreferenceRepository struct {
+	repository.NotificationPreferenceRepository
+}
+
+func (fakePreferenceRepository) ListQuietHours(userIDs []uint) ([]domain.QuietHours, error) {
+	return nil, nil
+}
+
+func TestDigestDispatch(t *testing.T) {
+	server := startServer(t, serverOptions{})
+	m := newMailer(t, server.config(mailer.TLSNone))
+
+	ana, beto := "ana@plotcenter.test", "beto@plotcenter.test"
+	orderID := uint(42)
+	order := &domain.Order{ID: orderID, NumeroOP: "OP-0042", Cliente: "Café Río"}
+	description := "Pasó de Imprenta a Instalaciones"
+	yesterday := time.Date(2026, 3, 9, 15, 30, 0, 0, time.UTC)
+	repo := &fakeEmailRepository{
+		candidates: []repository.DigestCandidate{
+			{IDUsuario: 1, Modo: domain.ModoEmailDiario, Oldest: yesterday},
+			// Still inside today's slot, so it waits for tomorrow's digest
+			{IDUsuario: 2, Modo: domain.ModoEmailDiario, Oldest: time.Date(2026, 3, 10, 8, 30, 0, 0, time.UTC)},
+		},
+		waiting: map[uint][]domain.UserNotification{
+			1: {
+				{ID: 11, Title: "Orden asignada", OrdenID: &orderID, Orden: order, CreatedAt: yesterday},
+				{ID: 12, Title: "Cambio de estado", Description: &description, OrdenID: &orderID, Orden: order, CreatedAt: yesterday.Add(time.Hour)},
+				{ID: 13, Title: "Stock bajo: Vinilo blanco", CreatedAt: yesterday.Add(2 * time.Hour)},
+			},
+			2: {{ID: 21, Title: "Orden asignada", CreatedAt: time.Date(2026, 3, 10, 8, 30, 0, 0, time.UTC)}},
+		},
+		digested: make(map[uint][]uint),
+	}
+	users := fakeUserRepository{users: map[uint]*domain.User{
+		1: {ID: 1, Nombre: "Ana", Email: &ana},
+		2: {ID: 2, Nombre: "Beto", Email: &beto},
+	}}
+	emails := service.NewEmailService(repo, nil, fakePreferenceRepository{}, users, m, service.EmailSettings{
+		MaxAttempts: 3,
+		DigestHour:  8,
+		AppURL:      "https://tablero.plotcenter.test",
+	}, time.UTC)
+
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	sent, err := emails.Dispatch(now)
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if sent != 1 {
+		t.Fatalf("Dispatch sent %d emails, want 1", sent)
+	}
+	if len(repo.deliveries) != 1 || !slices.Equal(repo.digested[1], []uint{11, 12, 13}) {
+		t.Fatalf("queued %+v carrying %v, want one digest with notifications 11, 12 and 13", repo.deliveries, repo.digested)
+	}
+	if delivery := repo.deliveries[0]; delivery.Tipo != domain.EnvioResumen || delivery.Estado != domain.EnvioEnviado || delivery.Intentos != 1 {
+		t.Errorf("delivery = %+v, want a sent digest", delivery)
+	}
+	if len(repo.waiting[2]) != 1 {
+		t.Error("Beto's notification went out before the digest slot")
+	}
+
+	sess := server.next(t)
+	checkMessage(t, sess, mailer.Message{
+		To:      ana,
+		Subject: "Resumen de notificaciones: 3 novedades",
+		Body: `Hola Ana,
+
+Tenés 3 novedades del día.
+
+Orden OP-0042 - Café Río
+  · 09/03 15:30  Orden asignada
+  · 09/03 16:30  Cambio de estado: Pasó de Imprenta a Instalaciones
+
+General
+  · 09/03 17:30  Stock bajo: Vinilo blanco
+
+Abrí el tablero: https://tablero.plotcenter.test
+
+--
+Plot Center
+Recibís este correo por tus preferencias de notificación; podés cambiarlas desde el tablero.
+`,
+	})
+
+	// Everything due went out in the first pass
+	if sent, err := emails.Dispatch(now.Add(time.Minute)); err != nil || sent != 0 {
+		t.Errorf("second Dispatch = %d, %v; want nothing sent", sent, err)
+	}
+}
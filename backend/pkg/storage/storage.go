@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"task-board/pkg/config"
+)
+
+// ErrNotFound is returned when no object is stored under the key
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps file contents under slash-separated keys such as "orders/12/ab3f.pdf"
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing any previous object
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object for reading; the caller closes it
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the object; deleting a missing key is not an error
+	Delete(key string) error
+}
+
+// New builds the storage selected by STORAGE_DRIVER ("local" or "s3")
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return NewLocalStorage(cfg.StorageLocalDir)
+	case "s3":
+		return NewS3Storage(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// validateKey rejects keys that could escape the storage root
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." || segment == "." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}